@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// seniority of an adjuster, which decides the claims routed to them, see AutoRouteClaim
+const (
+	AdjusterTierJunior = "junior"
+	AdjusterTierSenior = "senior"
+)
+
+// STRUCTURE FOR A CLAIMS ADJUSTER
+type Adjuster struct {
+	AdjusterID       string `json:"adjusterID"`
+	Name             string `json:"name"`
+	MSPID            string `json:"mspID"`
+	TeamID           string `json:"teamID"`
+	Tier             string `json:"tier,omitempty"`   // junior or senior, empty for adjusters only assigned by hand
+	ActiveClaimCount int    `json:"activeClaimCount"` // claims currently assigned to the adjuster
+}
+
+// //////////////////////////////
+// REGISTER A CLAIMS ADJUSTER //
+// //////////////////////////////
+func (c *HealthInsurance) RegisterAdjuster(ctx contractapi.TransactionContextInterface, adj Adjuster) error {
+	// only insurers can register adjusters
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if adj.AdjusterID == "" {
+		return NewValidationError("adjusterID", "adjuster ID must not be empty")
+	}
+	if adj.Tier != "" && adj.Tier != AdjusterTierJunior && adj.Tier != AdjusterTierSenior {
+		return NewValidationError("tier", fmt.Sprintf("invalid adjuster tier %q: must be %s or %s", adj.Tier, AdjusterTierJunior, AdjusterTierSenior))
+	}
+
+	existing, err := getAdjuster(ctx, adj.AdjusterID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return NewConflictError(fmt.Sprintf("adjuster %s already exists", adj.AdjusterID))
+	}
+
+	// a new adjuster starts without any assigned claims
+	adj.ActiveClaimCount = 0
+
+	return putAdjuster(ctx, &adj)
+}
+
+// ///////////////////////////////////////////////////
+// ASSIGN A CLAIM TO AN ADJUSTER BY CLAIM ID ALONE //
+// ///////////////////////////////////////////////////
+func (c *HealthInsurance) AssignClaim(ctx contractapi.TransactionContextInterface, claimID string, adjusterID string) error {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	return c.AssignClaimToAdjuster(ctx, claim.PolicyID, claimID, adjusterID)
+}
+
+// /////////////////////////////////////////
+// ASSIGN A CLAIM TO A SPECIFIC ADJUSTER //
+// /////////////////////////////////////////
+func (c *HealthInsurance) AssignClaimToAdjuster(ctx contractapi.TransactionContextInterface, policyID string, claimID string, adjusterID string) error {
+	// only insurers can distribute claims between adjusters
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	adjuster, err := getAdjuster(ctx, adjusterID)
+	if err != nil {
+		return err
+	}
+	if adjuster == nil {
+		return NewNotFoundError("adjuster", adjusterID)
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+	if claim.PolicyID != policyID {
+		return NewValidationError("claimID", fmt.Sprintf("claim %s does not belong to policy %s", claimID, policyID))
+	}
+
+	if err := assignClaim(ctx, claim, adjuster); err != nil {
+		return err
+	}
+	if err := putClaim(ctx, claim); err != nil {
+		return err
+	}
+
+	// notify off-chain listeners of the assignment
+	eventJSON, err := json.Marshal(map[string]string{
+		"policyID":   policyID,
+		"claimID":    claimID,
+		"adjusterID": adjusterID,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("ClaimAssigned", eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////////////////////////
+// HAND A CLAIM TO AN ADJUSTER, MOVING THE WORKLOAD, THE CALLER STORES IT //
+// //////////////////////////////////////////////////////////////////////////
+func assignClaim(ctx contractapi.TransactionContextInterface, claim *Claim, adjuster *Adjuster) error {
+	if claim.AssignedAdjusterID == adjuster.AdjusterID {
+		return NewConflictError(fmt.Sprintf("claim %s is already assigned to adjuster %s", claim.ClaimID, adjuster.AdjusterID))
+	}
+
+	// release the claim from the previously assigned adjuster, if any
+	if claim.AssignedAdjusterID != "" {
+		previous, err := getAdjuster(ctx, claim.AssignedAdjusterID)
+		if err != nil {
+			return err
+		}
+		if previous != nil && previous.ActiveClaimCount > 0 {
+			previous.ActiveClaimCount--
+			if err := putAdjuster(ctx, previous); err != nil {
+				return err
+			}
+		}
+	}
+
+	adjuster.ActiveClaimCount++
+	if err := putAdjuster(ctx, adjuster); err != nil {
+		return err
+	}
+
+	// assignment starts the review, other open claims keep their status so a duplicate flag stays visible
+	if claim.Status == ClaimStatusSubmitted {
+		if err := transitionClaim(claim, ClaimStatusUnderReview); err != nil {
+			return err
+		}
+	} else if !isOpenClaimStatus(claim.Status) {
+		return NewStateError(fmt.Sprintf("cannot assign claim %s, current status is %q", claim.ClaimID, claim.Status))
+	}
+
+	// a reassignment keeps the time the review first started
+	if claim.ReviewStartedAt == "" {
+		txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+		if err != nil {
+			return NewLedgerError("get transaction timestamp", err)
+		}
+		claim.ReviewStartedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+	}
+
+	claim.AssignedAdjusterID = adjuster.AdjusterID
+	return nil
+}
+
+// //////////////////////////////////////////////////////////////
+// RETRIEVE THE CLAIMS ASSIGNED TO AN ADJUSTER (COUCHDB ONLY) //
+// //////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByAssignee(ctx contractapi.TransactionContextInterface, adjusterID string) ([]*Claim, error) {
+	// insurers see every worklist, an adjuster only their own
+	if err := assertRole(ctx, "insurer"); err != nil {
+		callerAdjusterID, found, attrErr := ctx.GetClientIdentity().GetAttributeValue("adjusterID")
+		if attrErr != nil {
+			return nil, NewLedgerError("get client adjusterID attribute", attrErr)
+		}
+		if !found || callerAdjusterID != adjusterID {
+			return nil, err
+		}
+	}
+
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]string{
+			"docType":            "claim",
+			"assignedAdjusterID": adjusterID,
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build claim query", err)
+	}
+
+	iterator, err := ctx.GetStub().GetQueryResult(string(query))
+	if err != nil {
+		return nil, NewLedgerError("query claims", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		// the query matched the public record, the claim itself is in the private collection
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		if claim == nil {
+			continue
+		}
+		claims = append(claims, claim)
+	}
+
+	// oldest submissions first, ties broken by ID so the order is deterministic
+	sort.SliceStable(claims, func(i, j int) bool {
+		if claims[i].Timestamp != claims[j].Timestamp {
+			return claims[i].Timestamp < claims[j].Timestamp
+		}
+		return claims[i].ClaimID < claims[j].ClaimID
+	})
+
+	return c.projectClaims(ctx, claims)
+}
+
+// /////////////////////////////////////////////////////////////
+// RETRIEVE ALL ADJUSTERS, LEAST LOADED FIRST, FOR BALANCING //
+// /////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetAdjusterWorkload(ctx contractapi.TransactionContextInterface) ([]Adjuster, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("adjuster", []string{})
+	if err != nil {
+		return nil, NewLedgerError("read adjusters from world state", err)
+	}
+	defer iterator.Close()
+
+	adjusters := []Adjuster{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate adjusters", err)
+		}
+
+		var adjuster Adjuster
+		if err := json.Unmarshal(result.Value, &adjuster); err != nil {
+			return nil, NewLedgerError("unmarshal adjuster", err)
+		}
+		adjusters = append(adjusters, adjuster)
+	}
+
+	sortByWorkload(adjusters)
+
+	return adjusters, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////
+// ORDER ADJUSTERS LEAST LOADED FIRST, TIES BROKEN BY ID SO IT IS STABLE //
+// /////////////////////////////////////////////////////////////////////////
+func sortByWorkload(adjusters []Adjuster) {
+	sort.SliceStable(adjusters, func(i, j int) bool {
+		if adjusters[i].ActiveClaimCount != adjusters[j].ActiveClaimCount {
+			return adjusters[i].ActiveClaimCount < adjusters[j].ActiveClaimCount
+		}
+		return adjusters[i].AdjusterID < adjusters[j].AdjusterID
+	})
+}
+
+// ///////////////////////////////////////////////////////////////
+// ENSURE AN ADJUSTER ONLY CHANGES THE CLAIMS ASSIGNED TO THEM //
+// ///////////////////////////////////////////////////////////////
+func assertClaimAssignee(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	// adjusters are identified by the adjusterID attribute of their certificate
+	adjusterID, found, err := ctx.GetClientIdentity().GetAttributeValue("adjusterID")
+	if err != nil {
+		return NewLedgerError("get client adjusterID attribute", err)
+	}
+
+	// an unassigned claim can be handled by any insurer who is not an adjuster
+	if claim.AssignedAdjusterID == "" {
+		if found {
+			return NewUnauthorizedError(fmt.Sprintf("claim %s is not assigned to adjuster %s", claim.ClaimID, adjusterID))
+		}
+		return nil
+	}
+
+	if !found || adjusterID != claim.AssignedAdjusterID {
+		return NewUnauthorizedError(fmt.Sprintf("claim %s is assigned to adjuster %q, only that adjuster may change it", claim.ClaimID, claim.AssignedAdjusterID))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////
+// TAKE A CLOSED CLAIM OFF ITS ADJUSTER'S WORKLOAD //
+// ///////////////////////////////////////////////////
+func releaseAssignedAdjuster(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	if claim.AssignedAdjusterID == "" {
+		return nil
+	}
+
+	adjuster, err := getAdjuster(ctx, claim.AssignedAdjusterID)
+	if err != nil {
+		return err
+	}
+	if adjuster == nil || adjuster.ActiveClaimCount == 0 {
+		return nil
+	}
+
+	adjuster.ActiveClaimCount--
+	return putAdjuster(ctx, adjuster)
+}
+
+// //////////////////////////////////////////////
+// READ AN ADJUSTER, NIL IF IT DOES NOT EXIST //
+// //////////////////////////////////////////////
+func getAdjuster(ctx contractapi.TransactionContextInterface, adjusterID string) (*Adjuster, error) {
+	adjusterKey, err := ctx.GetStub().CreateCompositeKey("adjuster", []string{adjusterID})
+	if err != nil {
+		return nil, NewLedgerError("create adjuster key", err)
+	}
+
+	adjusterJSON, err := ctx.GetStub().GetState(adjusterKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if adjusterJSON == nil {
+		return nil, nil
+	}
+
+	var adjuster Adjuster
+	if err := json.Unmarshal(adjusterJSON, &adjuster); err != nil {
+		return nil, NewLedgerError("unmarshal adjuster", err)
+	}
+
+	return &adjuster, nil
+}
+
+// ////////////////////////////////////////
+// STORE AN ADJUSTER IN THE WORLD STATE //
+// ////////////////////////////////////////
+func putAdjuster(ctx contractapi.TransactionContextInterface, adjuster *Adjuster) error {
+	adjusterKey, err := ctx.GetStub().CreateCompositeKey("adjuster", []string{adjuster.AdjusterID})
+	if err != nil {
+		return NewLedgerError("create adjuster key", err)
+	}
+
+	adjusterJSON, err := json.Marshal(adjuster)
+	if err != nil {
+		return NewLedgerError("marshal adjuster", err)
+	}
+
+	if err := ctx.GetStub().PutState(adjusterKey, adjusterJSON); err != nil {
+		return NewLedgerError("store adjuster", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// upper bounds in days of the aging buckets used when none are given
+var defaultAgingThresholds = []int{7, 15, 30}
+
+// STRUCTURE FOR THE OPEN CLAIMS OF ONE AGE RANGE
+type AgingBucket struct {
+	Label    string   `json:"label"`   // e.g. 0-7 or 30+
+	MinDays  int      `json:"minDays"` // days since submission, inclusive
+	MaxDays  int      `json:"maxDays"` // inclusive, -1 for the open-ended last bucket
+	Count    int      `json:"count"`
+	Amount   int      `json:"amount"` // insured amount claimed
+	ClaimIDs []string `json:"claimIDs"`
+}
+
+// STRUCTURE FOR ONE PAGE OF THE CLAIM AGING REPORT
+type AgedClaimsReport struct {
+	AsOf     string        `json:"asOf"` // RFC3339, UTC, ages are counted to this time
+	Buckets  []AgingBucket `json:"buckets"`
+	Bookmark string        `json:"bookmark"` // pass back in to fetch the next page
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// BUCKET OPEN CLAIMS BY DAYS SINCE SUBMISSION, ONE PAGE OF CLAIMS AT A TIME //
+// /////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetAgedClaims(ctx contractapi.TransactionContextInterface, thresholdsJSON string, pageSize int32, bookmark string) (*AgedClaimsReport, error) {
+	// only insurers track their own turnaround
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, err
+	}
+
+	// never fetch an unbounded amount of data in a single call
+	if pageSize <= 0 || pageSize > config.MaxPageSize {
+		return nil, NewValidationError("pageSize", fmt.Sprintf("invalid page size %d: must be between 1 and %d", pageSize, config.MaxPageSize))
+	}
+
+	thresholds, err := parseAgingThresholds(thresholdsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	report := &AgedClaimsReport{
+		AsOf:    now.Format(time.RFC3339),
+		Buckets: newAgingBuckets(thresholds),
+	}
+
+	// a page holds every kind of claim record, so it may list fewer open claims than its size
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("claim", []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, NewLedgerError("read claim records from world state", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		// the page lists the public records, the claim itself is in the private collection
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		if claim == nil {
+			continue
+		}
+
+		if !isOpenClaimStatus(claim.Status) {
+			continue
+		}
+
+		submittedAt, ok := claimSubmittedAt(claim)
+		if !ok {
+			continue
+		}
+
+		days := int(now.Sub(submittedAt).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+
+		bucket := &report.Buckets[len(report.Buckets)-1]
+		for i := range report.Buckets[:len(report.Buckets)-1] {
+			if days <= report.Buckets[i].MaxDays {
+				bucket = &report.Buckets[i]
+				break
+			}
+		}
+		bucket.Count++
+		if bucket.Amount, err = addAmounts("amount", bucket.Amount, claim.ClaimAmount); err != nil {
+			return nil, err
+		}
+		bucket.ClaimIDs = append(bucket.ClaimIDs, claim.ClaimID)
+	}
+
+	report.Bookmark = metadata.GetBookmark()
+
+	return report, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////
+// PARSE THE UPPER BOUNDS OF THE AGING BUCKETS, THE DEFAULTS WHEN NOT GIVEN //
+// ////////////////////////////////////////////////////////////////////////////
+func parseAgingThresholds(thresholdsJSON string) ([]int, error) {
+	if strings.TrimSpace(thresholdsJSON) == "" {
+		return defaultAgingThresholds, nil
+	}
+
+	var thresholds []int
+	if err := json.Unmarshal([]byte(thresholdsJSON), &thresholds); err != nil {
+		return nil, NewValidationError("thresholds", fmt.Sprintf("invalid thresholds, expected a JSON array of day counts: %v", err))
+	}
+	if len(thresholds) == 0 {
+		return defaultAgingThresholds, nil
+	}
+
+	for i, threshold := range thresholds {
+		if threshold < 0 || (i > 0 && threshold <= thresholds[i-1]) {
+			return nil, NewValidationError("thresholds", "thresholds must not be negative and must increase")
+		}
+	}
+
+	return thresholds, nil
+}
+
+// ////////////////////////////////////////////////////////////////////
+// EMPTY BUCKETS UP TO EACH THRESHOLD, AND ONE FOR EVERYTHING OLDER //
+// ////////////////////////////////////////////////////////////////////
+func newAgingBuckets(thresholds []int) []AgingBucket {
+	buckets := []AgingBucket{}
+	minDays := 0
+	for _, threshold := range thresholds {
+		buckets = append(buckets, AgingBucket{
+			Label:    fmt.Sprintf("%d-%d", minDays, threshold),
+			MinDays:  minDays,
+			MaxDays:  threshold,
+			ClaimIDs: []string{},
+		})
+		minDays = threshold + 1
+	}
+
+	return append(buckets, AgingBucket{
+		Label:    fmt.Sprintf("%d+", minDays-1),
+		MinDays:  minDays,
+		MaxDays:  -1,
+		ClaimIDs: []string{},
+	})
+}
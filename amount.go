@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// every money value on the ledger and in transaction arguments is a whole number of the
+// network's amount unit: minor units (paisa, cents) of the network currency, or whole
+// units on networks whose amounts were stored before minor units, see amountUnit
+
+// units the configuration can keep amounts in
+const (
+	AmountUnitMinor = "minor"
+	AmountUnitWhole = "whole"
+)
+
+// largest single amount accepted, small enough that an amount times a percentage or a day count stays within int64
+const maxAmount = 1_000_000_000_000_000
+
+// digits after the decimal point of the currencies the network can be configured with
+var currencyMinorDigits = map[string]int{
+	"INR": 2,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"AED": 2,
+	"SGD": 2,
+	"JPY": 0,
+	"KWD": 3,
+}
+
+// digits, an optional fraction and an optional currency code, e.g. 1234.56 INR
+var amountPattern = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]+))?(?: ([A-Z]{3}))?$`)
+
+// /////////////////////////////////////////////////////////////////////////
+// CONVERT A DECIMAL AMOUNT SUCH AS 1234.56 INTO THE NETWORK AMOUNT UNIT //
+// /////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ParseAmount(ctx contractapi.TransactionContextInterface, value string) (int, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseAmount("value", value, config.Currency, amountDigits(config))
+}
+
+// //////////////////////////////////////////////////////////////////////
+// FORMAT AN AMOUNT IN THE NETWORK AMOUNT UNIT AS A DECIMAL WITH CODE //
+// //////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) FormatAmount(ctx contractapi.TransactionContextInterface, amount int) (string, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkAmount("amount", amount); err != nil {
+		return "", err
+	}
+
+	return formatAmount(amount, config.Currency, amountDigits(config)), nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////////
+// PARSE A DECIMAL AMOUNT, REJECTING SIGNS, EXPONENTS, SEPARATORS AND ROUNDING //
+// ///////////////////////////////////////////////////////////////////////////////
+func parseAmount(field string, value string, currency string, digits int) (int, error) {
+	match := amountPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid amount %q: expected digits with an optional fraction and currency code, e.g. 1234.50 %s", value, currency))
+	}
+	if match[3] != "" && match[3] != currency {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid amount %q: currency must be %s", value, currency))
+	}
+
+	// a fraction finer than the amount unit would have to be rounded, so it is refused
+	fraction := match[2]
+	if len(fraction) > digits {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid amount %q: %s amounts have %d decimal places", value, currency, digits))
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	minor, err := strconv.ParseInt(match[1]+fraction, 10, 64)
+	if err != nil || minor > maxAmount {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid amount %q: exceeds the largest accepted amount", value))
+	}
+
+	return int(minor), nil
+}
+
+// ///////////////////////////////////////////////////
+// FORMAT AN AMOUNT AS A DECIMAL, E.G. 1234.50 INR //
+// ///////////////////////////////////////////////////
+func formatAmount(amount int, currency string, digits int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	whole := strconv.Itoa(amount)
+	if digits == 0 {
+		return sign + whole + " " + currency
+	}
+	if len(whole) <= digits {
+		whole = strings.Repeat("0", digits-len(whole)+1) + whole
+	}
+
+	return sign + whole[:len(whole)-digits] + "." + whole[len(whole)-digits:] + " " + currency
+}
+
+// /////////////////////////////////////////////////////////////
+// DECIMAL PLACES OF AN AMOUNT IN THE CONFIGURED AMOUNT UNIT //
+// /////////////////////////////////////////////////////////////
+func amountDigits(config *ChaincodeConfig) int {
+	if config.AmountUnit == AmountUnitWhole {
+		return 0
+	}
+
+	return currencyMinorDigits[config.Currency]
+}
+
+// //////////////////////////////////////////////////////////////
+// CHECK AN AMOUNT ARGUMENT IS NOT NEGATIVE AND NOT TOO LARGE //
+// //////////////////////////////////////////////////////////////
+func checkAmount(field string, value int) error {
+	if value < 0 || value > maxAmount {
+		return NewValidationError(field, fmt.Sprintf("invalid amount %d: must be between 0 and %d", value, maxAmount))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////
+// ADD TWO AMOUNTS, FAILING ON AN OVERFLOW //
+// ///////////////////////////////////////////
+func addAmounts(field string, a int, b int) (int, error) {
+	if (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b) {
+		return 0, NewValidationError(field, fmt.Sprintf("amount overflow adding %d to %d", b, a))
+	}
+
+	return a + b, nil
+}
+
+// /////////////////////////////////////////////////////////////
+// MULTIPLY AN AMOUNT BY A WHOLE FACTOR, FAILING ON OVERFLOW //
+// /////////////////////////////////////////////////////////////
+func multiplyAmount(field string, amount int, factor int) (int, error) {
+	if amount == 0 || factor == 0 {
+		return 0, nil
+	}
+
+	product := amount * factor
+	if product/factor != amount || (amount == -1 && factor == math.MinInt) || (factor == -1 && amount == math.MinInt) {
+		return 0, NewValidationError(field, fmt.Sprintf("amount overflow multiplying %d by %d", amount, factor))
+	}
+
+	return product, nil
+}
+
+// //////////////////////////////////////////////////////////////////
+// CONVERT A TOKEN QUANTITY INTO AMOUNT UNITS AT A PER-TOKEN RATE //
+// //////////////////////////////////////////////////////////////////
+func convertAmount(field string, quantity float64, rate int) (int, error) {
+	// NaN fails every comparison, so it is refused along with zero and negatives
+	if !(quantity > 0) || math.IsInf(quantity, 0) {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid token amount %v: must be a finite number greater than zero", quantity))
+	}
+
+	// float64 arithmetic is IEEE 754 on every peer, so all of them round to the same amount
+	converted := math.Round(quantity * float64(rate))
+	if converted < 1 || converted > maxAmount {
+		return 0, NewValidationError(field, fmt.Sprintf("invalid token amount %v: converts to %.0f, must be between 1 and %d", quantity, converted, maxAmount))
+	}
+
+	return int(converted), nil
+}
+
+// //////////////////////////////////////////////////////////////////////////
+// AN AMOUNT TIMES A FRACTION AT MOST ONE, WITHOUT OVERFLOWING ON THE WAY //
+// //////////////////////////////////////////////////////////////////////////
+func scaleAmount(amount int, numerator int, denominator int) int {
+	// the product is taken in 128 bits, the quotient is at most the amount so it fits again
+	hi, lo := bits.Mul64(uint64(amount), uint64(numerator))
+	quotient, _ := bits.Div64(hi, lo, uint64(denominator))
+
+	return int(quotient)
+}
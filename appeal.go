@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A POLICYHOLDER'S APPEAL AGAINST A CLAIM REJECTION
+type Appeal struct {
+	ObjectType          string   `json:"docType"`
+	AppealID            string   `json:"appealID"`
+	ClaimID             string   `json:"claimID"`
+	PolicyID            string   `json:"policyID"`
+	AppealReason        string   `json:"appealReason"`
+	SupportingDocHashes []string `json:"supportingDocHashes"` // hex-encoded SHA-256 hashes of the new evidence
+	Status              string   `json:"status"`              // open/upheld/dismissed
+	ResolutionNotes     string   `json:"resolutionNotes,omitempty"`
+	Timestamp           string   `json:"timestamp"` // RFC3339, when the appeal was lodged
+}
+
+// ///////////////////////////////////////////////////////////////
+// APPEAL A REJECTED CLAIM WITHIN THE CONFIGURED APPEAL WINDOW //
+// ///////////////////////////////////////////////////////////////
+func (c *HealthInsurance) AppealClaim(ctx contractapi.TransactionContextInterface, claimID string, appealReason string, supportingDocHashesJSON string) error {
+	if strings.TrimSpace(appealReason) == "" {
+		return NewValidationError("appealReason", "appeal reason must not be empty")
+	}
+
+	supportingDocHashes, err := parseDocumentHashes(supportingDocHashesJSON)
+	if err != nil {
+		return err
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	// only the policy owner can appeal a decision on their claim
+	if err := assertPolicyOwner(ctx, policy); err != nil {
+		return err
+	}
+
+	if err := validateClaimTransition(claim, ClaimStatusAppealPending); err != nil {
+		return err
+	}
+
+	// a claim gets a single appeal
+	appealIndexKey, err := ctx.GetStub().CreateCompositeKey("claimappeal", []string{claimID})
+	if err != nil {
+		return NewLedgerError("create appeal index key", err)
+	}
+
+	existingAppealID, err := ctx.GetStub().GetState(appealIndexKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+	if existingAppealID != nil {
+		return NewConflictError(fmt.Sprintf("claim %s has already been appealed in appeal %s", claimID, string(existingAppealID)))
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// claims rejected before the rejection time was recorded cannot be placed in a window
+	if claim.RejectedAt == "" {
+		return NewStateError(fmt.Sprintf("claim %s has no recorded rejection time, raise a dispute instead", claimID))
+	}
+	rejectedAt, err := time.Parse(time.RFC3339, claim.RejectedAt)
+	if err != nil {
+		return NewLedgerError("parse rejection time", err)
+	}
+
+	deadline := rejectedAt.AddDate(0, 0, config.AppealWindowDays)
+	if now.After(deadline) {
+		return NewStateError(fmt.Sprintf("the appeal window for claim %s closed on %s", claimID, deadline.Format(time.RFC3339)))
+	}
+
+	appeal := &Appeal{
+		ObjectType:          "appeal",
+		AppealID:            ctx.GetStub().GetTxID(),
+		ClaimID:             claimID,
+		PolicyID:            claim.PolicyID,
+		AppealReason:        appealReason,
+		SupportingDocHashes: supportingDocHashes,
+		Status:              "open",
+		Timestamp:           now.Format(time.RFC3339),
+	}
+
+	if err := putAppeal(ctx, appeal); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(appealIndexKey, []byte(appeal.AppealID)); err != nil {
+		return NewLedgerError("store appeal index", err)
+	}
+
+	if err := transitionClaim(claim, ClaimStatusAppealPending); err != nil {
+		return err
+	}
+	if err := putClaim(ctx, claim); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "ClaimAppealed", claim.PolicyID, claimID)
+}
+
+// ////////////////////////////////////
+// UPHOLD OR DISMISS AN OPEN APPEAL //
+// ////////////////////////////////////
+func (c *HealthInsurance) ResolveAppeal(ctx contractapi.TransactionContextInterface, appealID string, resolution string, notes string) error {
+	// only insurers can review appealed claims
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if resolution != "upheld" && resolution != "dismissed" {
+		return NewValidationError("resolution", fmt.Sprintf("invalid resolution %q: must be upheld or dismissed", resolution))
+	}
+
+	appeal, err := getAppeal(ctx, appealID)
+	if err != nil {
+		return err
+	}
+	if appeal == nil {
+		return NewNotFoundError("appeal", appealID)
+	}
+
+	if appeal.Status != "open" {
+		return NewStateError(fmt.Sprintf("appeal %s has already been resolved as %s", appealID, appeal.Status))
+	}
+
+	claim, err := getClaim(ctx, appeal.ClaimID)
+	if err != nil {
+		return err
+	}
+
+	// an upheld appeal overturns the rejection, a dismissed one restores it
+	if resolution == "upheld" {
+		if err := c.overturnRejection(ctx, claim); err != nil {
+			return err
+		}
+	} else {
+		if err := transitionClaim(claim, ClaimStatusRejected); err != nil {
+			return err
+		}
+		if err := putClaim(ctx, claim); err != nil {
+			return err
+		}
+	}
+
+	appeal.Status = resolution
+	appeal.ResolutionNotes = notes
+	if err := putAppeal(ctx, appeal); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "AppealResolved", appeal.PolicyID, appeal.ClaimID)
+}
+
+// ////////////////////////////////////////////
+// READ AN APPEAL, NIL IF IT DOES NOT EXIST //
+// ////////////////////////////////////////////
+func getAppeal(ctx contractapi.TransactionContextInterface, appealID string) (*Appeal, error) {
+	appealKey, err := ctx.GetStub().CreateCompositeKey("appeal", []string{appealID})
+	if err != nil {
+		return nil, NewLedgerError("create appeal key", err)
+	}
+
+	appealJSON, err := ctx.GetStub().GetState(appealKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if appealJSON == nil {
+		return nil, nil
+	}
+
+	var appeal Appeal
+	if err := json.Unmarshal(appealJSON, &appeal); err != nil {
+		return nil, NewLedgerError("unmarshal appeal", err)
+	}
+
+	return &appeal, nil
+}
+
+// //////////////////////////////////////
+// STORE AN APPEAL IN THE WORLD STATE //
+// //////////////////////////////////////
+func putAppeal(ctx contractapi.TransactionContextInterface, appeal *Appeal) error {
+	appealKey, err := ctx.GetStub().CreateCompositeKey("appeal", []string{appeal.AppealID})
+	if err != nil {
+		return NewLedgerError("create appeal key", err)
+	}
+
+	appealJSON, err := json.Marshal(appeal)
+	if err != nil {
+		return NewLedgerError("marshal appeal", err)
+	}
+
+	if err := ctx.GetStub().PutState(appealKey, appealJSON); err != nil {
+		return NewLedgerError("store appeal", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE INPUT OF A SINGLE POLICY, MIRRORING CreatePolicy
+type PolicyInput struct {
+	PolicyID               string             `json:"policyID"`
+	SumAssured             int                `json:"sumAssured"`
+	PersonName             string             `json:"personName"`
+	DateOfBirth            string             `json:"dateOfBirth"`
+	Gender                 string             `json:"gender"`
+	StartDate              string             `json:"startDate"`
+	EndDate                string             `json:"endDate"`
+	CoPay                  int                `json:"coPay"`
+	PreAuthThreshold       int                `json:"preAuthThreshold"`
+	WaitingPeriodDays      int                `json:"waitingPeriodDays"`
+	PreExistingWaitingDays int                `json:"preExistingWaitingDays"`
+	Coverages              []CoverageCategory `json:"coverages"`
+	Benefits               []string           `json:"benefits"`
+	Exclusions             []CoverageCategory `json:"exclusions"`
+	SubLimits              []SubLimit         `json:"subLimits"`
+	RoomRentLimit          int                `json:"roomRentLimit"`
+	Deductible             int                `json:"deductible"`
+	CoInsurers             []CoInsurer        `json:"coInsurers"`
+	MedicalConditions      string             `json:"medicalConditions"`
+	Draft                  bool               `json:"draft,omitempty"` // issue as DRAFT, to be put in force by ActivatePolicy
+}
+
+// STRUCTURE FOR A BATCH ENTRY THAT COULD NOT BE CREATED
+type BatchError struct {
+	Index    int    `json:"index"` // position of the entry in the submitted array
+	PolicyID string `json:"policyID"`
+	Error    string `json:"error"`
+}
+
+// STRUCTURE FOR THE OUTCOME OF A LENIENT BATCH CREATION
+type BatchCreateResult struct {
+	CreatedPolicyIDs []string     `json:"createdPolicyIDs"`
+	Errors           []BatchError `json:"errors"`
+}
+
+// //////////////////////////////////////////////////////////
+// CREATE A BATCH OF POLICIES, ALL OR NOTHING, FOR GROUPS //
+// //////////////////////////////////////////////////////////
+func (c *HealthInsurance) BatchCreatePolicies(ctx contractapi.TransactionContextInterface, policiesJSON string) ([]string, error) {
+	inputs, err := parsePolicyInputs(policiesJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// returning an error aborts the transaction, so no policy of a failed batch is committed
+	created := []string{}
+	seen := map[string]bool{}
+	stats := newNetworkStats()
+	for i, input := range inputs {
+		if err := c.createBatchEntry(ctx, input, seen, stats); err != nil {
+			return nil, batchEntryError(i, input.PolicyID, err)
+		}
+		created = append(created, input.PolicyID)
+	}
+
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return nil, err
+	}
+
+	if err := setChaincodeEvent(ctx, "PoliciesBatchCreated", "", ""); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// ////////////////////////////////////////////////////////////
+// CREATE A BATCH OF POLICIES, SKIPPING ANY INVALID ENTRIES //
+// ////////////////////////////////////////////////////////////
+func (c *HealthInsurance) BatchCreatePoliciesLenient(ctx contractapi.TransactionContextInterface, policiesJSON string) (*BatchCreateResult, error) {
+	inputs, err := parsePolicyInputs(policiesJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchCreateResult{
+		CreatedPolicyIDs: []string{},
+		Errors:           []BatchError{},
+	}
+	seen := map[string]bool{}
+	stats := newNetworkStats()
+	for i, input := range inputs {
+		if err := c.createBatchEntry(ctx, input, seen, stats); err != nil {
+			result.Errors = append(result.Errors, BatchError{
+				Index:    i,
+				PolicyID: input.PolicyID,
+				Error:    errorMessage(err),
+			})
+			continue
+		}
+		result.CreatedPolicyIDs = append(result.CreatedPolicyIDs, input.PolicyID)
+	}
+
+	if len(result.CreatedPolicyIDs) > 0 {
+		if err := applyNetworkStats(ctx, stats); err != nil {
+			return nil, err
+		}
+
+		if err := setChaincodeEvent(ctx, "PoliciesBatchCreated", "", ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ///////////////////////////////////////////////////
+// CREATE ONE POLICY OF A BATCH, REJECTING REPEATS //
+// ///////////////////////////////////////////////////
+func (c *HealthInsurance) createBatchEntry(ctx contractapi.TransactionContextInterface, input *PolicyInput, seen map[string]bool, stats *NetworkStats) error {
+	// writes of this transaction are not visible to GetState, so repeats within the batch are tracked here
+	if seen[input.PolicyID] {
+		return NewConflictError(fmt.Sprintf("policy %s appears more than once in the batch", input.PolicyID))
+	}
+
+	// validate before writing, so a skipped entry leaves nothing behind
+	if err := c.createPolicy(ctx, input, stats); err != nil {
+		return err
+	}
+
+	seen[input.PolicyID] = true
+	return nil
+}
+
+// ////////////////////////////////////////////////////
+// TAG THE ERROR OF A BATCH ENTRY WITH ITS POSITION //
+// ////////////////////////////////////////////////////
+func batchEntryError(index int, policyID string, err error) error {
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) {
+		return err
+	}
+
+	// keep the code of the failing check, so clients can still switch on it
+	tagged := *contractErr
+	tagged.Message = fmt.Sprintf("batch aborted, entry %d (policy %s) failed: %s", index, policyID, contractErr.Message)
+	tagged.Details = map[string]string{"batchIndex": strconv.Itoa(index), "policyID": policyID}
+	for key, value := range contractErr.Details {
+		tagged.Details[key] = value
+	}
+
+	return &tagged
+}
+
+// /////////////////////////////////////////////////
+// PARSE A JSON-ENCODED ARRAY OF POLICY PAYLOADS //
+// /////////////////////////////////////////////////
+func parsePolicyInputs(policiesJSON string) ([]*PolicyInput, error) {
+	var inputs []*PolicyInput
+	if err := json.Unmarshal([]byte(policiesJSON), &inputs); err != nil {
+		return nil, NewValidationError("policies", fmt.Sprintf("invalid policies, expected a JSON array of policy objects: %v", err))
+	}
+
+	if len(inputs) == 0 {
+		return nil, NewValidationError("policies", "batch must contain at least one policy")
+	}
+
+	for i, input := range inputs {
+		if input == nil {
+			return nil, NewValidationError("policies", fmt.Sprintf("batch entry %d is null", i))
+		}
+
+		// absent lists are stored as empty lists, like CreatePolicy does
+		if input.Coverages == nil {
+			input.Coverages = []CoverageCategory{}
+		}
+		if input.Benefits == nil {
+			input.Benefits = []string{}
+		}
+		if input.Exclusions == nil {
+			input.Exclusions = []CoverageCategory{}
+		}
+		if input.SubLimits == nil {
+			input.SubLimits = []SubLimit{}
+		}
+		if input.CoInsurers == nil {
+			input.CoInsurers = []CoInsurer{}
+		}
+	}
+
+	return inputs, nil
+}
+
+// STRUCTURE FOR THE INPUT OF A SINGLE CLAIM, MIRRORING SubmitClaim
+type ClaimInput struct {
+	PolicyID        string          `json:"policyID"`
+	ClaimAmount     int             `json:"claimAmount"`
+	DiagnosisCodes  json.RawMessage `json:"diagnosisCodes"`
+	CoverageType    string          `json:"coverageType"`
+	HospitalName    string          `json:"hospitalName"`
+	DateOfAdmission string          `json:"dateOfAdmission"`
+	DateOfDischarge string          `json:"dateOfDischarge"`
+	TreatmentDate   string          `json:"treatmentDate"`
+	Documents       json.RawMessage `json:"documents"`
+	PreAuthID       string          `json:"preAuthID"`
+	ClaimType       string          `json:"claimType"` // only cashless claims can be batched
+	IntimationID    string          `json:"intimationID"`
+	Procedures      json.RawMessage `json:"procedures"`
+	LineItems       json.RawMessage `json:"lineItems"`
+}
+
+// STRUCTURE FOR THE OUTCOME OF ONE CLAIM OF A BATCH
+type ClaimBatchEntry struct {
+	Index    int    `json:"index"` // position of the entry in the submitted array
+	PolicyID string `json:"policyID"`
+	ClaimID  string `json:"claimID,omitempty"` // set when the claim was stored
+	Error    string `json:"error,omitempty"`   // set when it was skipped
+
+	// set when the claim was routed on submission, see AutoAssignClaims
+	AssignedAdjusterID string `json:"assignedAdjusterID,omitempty"`
+}
+
+// STRUCTURE FOR THE OUTCOME OF A BATCH OF CLAIMS
+type ClaimBatchResult struct {
+	Submitted int               `json:"submitted"`
+	Results   []ClaimBatchEntry `json:"results"`
+}
+
+// //////////////////////////////////////////////////////////////////////////
+// SUBMIT A HOSPITAL'S BATCH OF CASHLESS CLAIMS, SKIPPING INVALID ENTRIES //
+// //////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) SubmitClaimsBatch(ctx contractapi.TransactionContextInterface, claimsJSON string) (*ClaimBatchResult, error) {
+	// batches are sent by network hospitals at the end of the day
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := assertMSP(ctx, config.AllowedHospitalMSP); err != nil {
+		return nil, err
+	}
+
+	inputs, err := parseClaimInputs(claimsJSON, config.MaxClaimsPerBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	// adjuster workloads are tracked across the entries, so the batch is spread over them
+	router, err := c.newClaimRouter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ClaimBatchResult{Results: []ClaimBatchEntry{}}
+	seen := map[string]bool{}
+	txID := ctx.GetStub().GetTxID()
+	for i, input := range inputs {
+		entry := ClaimBatchEntry{Index: i, PolicyID: input.PolicyID}
+
+		// claims of one transaction share its ID, so each gets its position appended
+		claimID := txID + "-" + strconv.Itoa(i)
+		claim, err := c.fileBatchClaim(ctx, config, claimID, input, seen)
+		if err != nil {
+			entry.Error = errorMessage(err)
+			result.Results = append(result.Results, entry)
+			continue
+		}
+		entry.ClaimID = claimID
+		result.Submitted++
+
+		// the claim is already stored, so a failure to route it aborts the batch rather than leave it half done
+		adjuster, _, err := router.routeNewClaim(ctx, claim)
+		if err != nil {
+			return nil, err
+		}
+		if adjuster != nil {
+			entry.AssignedAdjusterID = adjuster.AdjusterID
+		}
+		result.Results = append(result.Results, entry)
+	}
+
+	if result.Submitted > 0 {
+		if err := setChaincodeEvent(ctx, "ClaimsBatchSubmitted", "", ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// /////////////////////////////////////////////////////////////
+// FILE ONE CLAIM OF A BATCH, ONE CLAIM PER POLICY PER BATCH //
+// /////////////////////////////////////////////////////////////
+func (c *HealthInsurance) fileBatchClaim(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig, claimID string, input *ClaimInput, seen map[string]bool) (*Claim, error) {
+	// writes of this transaction are not visible to GetState, so a second claim would miss the first one's
+	// counters, exceptions and duplicate check
+	if seen[input.PolicyID] {
+		return nil, NewConflictError(fmt.Sprintf("policy %s appears more than once in the batch, submit its other claims separately", input.PolicyID))
+	}
+
+	// bank details come from a single transient field, so reimbursements cannot be batched
+	if !strings.EqualFold(strings.TrimSpace(input.ClaimType), ClaimTypeCashless) {
+		return nil, NewValidationError("claimType", fmt.Sprintf("only %s claims can be submitted in a batch", ClaimTypeCashless))
+	}
+
+	if err := checkHighValueThreshold(config, input.ClaimAmount); err != nil {
+		return nil, err
+	}
+
+	// the claim is only written once every check passed, so a skipped entry leaves nothing behind
+	claim, err := c.fileClaim(ctx, claimID, input.PolicyID, input.ClaimAmount, rawJSON(input.DiagnosisCodes), input.CoverageType, input.HospitalName, input.DateOfAdmission, input.DateOfDischarge, input.TreatmentDate, rawJSON(input.Documents), input.PreAuthID, input.ClaimType, "", input.IntimationID, rawJSON(input.Procedures), rawJSON(input.LineItems))
+	if err != nil {
+		return nil, err
+	}
+
+	seen[input.PolicyID] = true
+	return claim, nil
+}
+
+// ////////////////////////////////////////////////
+// PARSE A JSON-ENCODED ARRAY OF CLAIM PAYLOADS //
+// ////////////////////////////////////////////////
+func parseClaimInputs(claimsJSON string, maxClaims int) ([]*ClaimInput, error) {
+	var inputs []*ClaimInput
+	if err := json.Unmarshal([]byte(claimsJSON), &inputs); err != nil {
+		return nil, NewValidationError("claims", fmt.Sprintf("invalid claims, expected a JSON array of claim objects: %v", err))
+	}
+
+	if len(inputs) == 0 {
+		return nil, NewValidationError("claims", "batch must contain at least one claim")
+	}
+	if len(inputs) > maxClaims {
+		return nil, NewValidationError("claims", fmt.Sprintf("batch of %d claims exceeds the maximum of %d", len(inputs), maxClaims))
+	}
+
+	for i, input := range inputs {
+		if input == nil {
+			return nil, NewValidationError("claims", fmt.Sprintf("batch entry %d is null", i))
+		}
+	}
+
+	return inputs, nil
+}
+
+// /////////////////////////////////////////////////////////
+// A NESTED JSON VALUE AS THE STRING SubmitClaim EXPECTS //
+// /////////////////////////////////////////////////////////
+func rawJSON(value json.RawMessage) string {
+	// an absent or null value reads as not given
+	if len(value) == 0 || string(value) == "null" {
+		return ""
+	}
+
+	return string(value)
+}
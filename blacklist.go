@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A HOSPITAL THE INSURER NO LONGER ACCEPTS CLAIMS FROM
+type BlacklistEntry struct {
+	ObjectType    string `json:"docType"`
+	HospitalID    string `json:"hospitalID"`
+	HospitalName  string `json:"hospitalName"`
+	Reason        string `json:"reason"`
+	EffectiveFrom string `json:"effectiveFrom"`           // YYYY-MM-DD, first day of admission the blacklist applies to
+	EffectiveTo   string `json:"effectiveTo,omitempty"`   // YYYY-MM-DD, last day it applies to, empty while open-ended
+	BlacklistedBy string `json:"blacklistedBy"`           // client ID of the insurer admin
+	BlacklistedAt string `json:"blacklistedAt"`           // RFC3339, UTC
+	Active        bool   `json:"active"`                  // false once removed from the blacklist
+	RemovedBy     string `json:"removedBy,omitempty"`     // client ID of the insurer admin
+	RemovedAt     string `json:"removedAt,omitempty"`     // RFC3339, UTC
+	RemovalReason string `json:"removalReason,omitempty"` // why the hospital was taken off the blacklist
+}
+
+// ////////////////////////////////////////////////////////////////
+// STOP ACCEPTING CLAIMS AND PRE-AUTHORIZATIONS FROM A HOSPITAL //
+// ////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) BlacklistHospital(ctx contractapi.TransactionContextInterface, hospitalID string, hospitalName string, reason string, effectiveFrom string, effectiveTo string) error {
+	// the blacklist is managed by administrators of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(hospitalID) == "" || strings.TrimSpace(hospitalName) == "" {
+		return NewValidationError("hospitalID", "hospital ID and name must not be empty")
+	}
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "blacklist reason must not be empty")
+	}
+
+	from, err := parsePolicyDate("effectiveFrom", effectiveFrom)
+	if err != nil {
+		return err
+	}
+	to := ""
+	if strings.TrimSpace(effectiveTo) != "" {
+		until, err := parsePolicyDate("effectiveTo", effectiveTo)
+		if err != nil {
+			return err
+		}
+		if until.Before(from) {
+			return NewValidationError("effectiveTo", fmt.Sprintf("effective to %s is before effective from %s", effectiveTo, effectiveFrom))
+		}
+		to = until.Format("2006-01-02")
+	}
+
+	existing, err := getBlacklistEntry(ctx, hospitalID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Active {
+		return NewConflictError(fmt.Sprintf("hospital %s is already blacklisted", hospitalID))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// blacklisting a removed entry again replaces it with the new details
+	return putBlacklistEntry(ctx, &BlacklistEntry{
+		ObjectType:    "blacklistEntry",
+		HospitalID:    hospitalID,
+		HospitalName:  strings.TrimSpace(hospitalName),
+		Reason:        reason,
+		EffectiveFrom: from.Format("2006-01-02"),
+		EffectiveTo:   to,
+		BlacklistedBy: clientID,
+		BlacklistedAt: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		Active:        true,
+	})
+}
+
+// ///////////////////////////////////////////
+// TAKE A HOSPITAL OFF THE BLACKLIST AGAIN //
+// ///////////////////////////////////////////
+func (c *HealthInsurance) RemoveFromBlacklist(ctx contractapi.TransactionContextInterface, hospitalID string, reason string) error {
+	// the blacklist is managed by administrators of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "removal reason must not be empty")
+	}
+
+	entry, err := getBlacklistEntry(ctx, hospitalID)
+	if err != nil {
+		return err
+	}
+	if entry == nil || !entry.Active {
+		return NewNotFoundError("blacklist entry", hospitalID)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// keep the entry so the history of the blacklisting stays on record
+	entry.Active = false
+	entry.RemovedBy = clientID
+	entry.RemovedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+	entry.RemovalReason = reason
+
+	return putBlacklistEntry(ctx, entry)
+}
+
+// //////////////////////////////////////////////
+// RETRIEVE THE BLACKLIST ENTRY OF A HOSPITAL //
+// //////////////////////////////////////////////
+func (c *HealthInsurance) GetBlacklistEntry(ctx contractapi.TransactionContextInterface, hospitalID string) (*BlacklistEntry, error) {
+	entry, err := getBlacklistEntry(ctx, hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, NewNotFoundError("blacklist entry", hospitalID)
+	}
+
+	return entry, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////
+// FIND THE ACTIVE ENTRY FOR A HOSPITAL BY ID OR BY NAME, NIL IF NOT LISTED //
+// ////////////////////////////////////////////////////////////////////////////
+func findBlacklistEntry(ctx contractapi.TransactionContextInterface, hospitalID string, hospitalName string) (*BlacklistEntry, error) {
+	if hospitalID != "" {
+		entry, err := getBlacklistEntry(ctx, hospitalID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil && entry.Active {
+			return entry, nil
+		}
+	}
+
+	// hospitals let through by an insurer override have no ID, so they are matched by name
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("blacklist", []string{})
+	if err != nil {
+		return nil, NewLedgerError("read blacklist from world state", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate blacklist", err)
+		}
+
+		var entry BlacklistEntry
+		if err := json.Unmarshal(result.Value, &entry); err != nil {
+			return nil, NewLedgerError("unmarshal blacklist entry", err)
+		}
+
+		if entry.Active && strings.EqualFold(entry.HospitalName, strings.TrimSpace(hospitalName)) {
+			return &entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// //////////////////////////////////////////////////////////
+// CHECK WHETHER A BLACKLIST ENTRY APPLIES ON A GIVEN DAY //
+// //////////////////////////////////////////////////////////
+func (entry *BlacklistEntry) inEffect(day time.Time) bool {
+	day = day.UTC().Truncate(24 * time.Hour)
+
+	from, err := parsePolicyDate("effectiveFrom", entry.EffectiveFrom)
+	if err != nil || day.Before(from) {
+		return false
+	}
+	if entry.EffectiveTo == "" {
+		return true
+	}
+
+	to, err := parsePolicyDate("effectiveTo", entry.EffectiveTo)
+	if err != nil {
+		return false
+	}
+
+	return !day.After(to)
+}
+
+// ////////////////////////////////////////////////////////////////
+// READ A BLACKLIST ENTRY, NIL IF THE HOSPITAL WAS NEVER LISTED //
+// ////////////////////////////////////////////////////////////////
+func getBlacklistEntry(ctx contractapi.TransactionContextInterface, hospitalID string) (*BlacklistEntry, error) {
+	entryKey, err := ctx.GetStub().CreateCompositeKey("blacklist", []string{hospitalID})
+	if err != nil {
+		return nil, NewLedgerError("create blacklist key", err)
+	}
+
+	entryJSON, err := ctx.GetStub().GetState(entryKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if entryJSON == nil {
+		return nil, nil
+	}
+
+	var entry BlacklistEntry
+	if err := json.Unmarshal(entryJSON, &entry); err != nil {
+		return nil, NewLedgerError("unmarshal blacklist entry", err)
+	}
+
+	return &entry, nil
+}
+
+// //////////////////////////////////////////////
+// STORE A BLACKLIST ENTRY IN THE WORLD STATE //
+// //////////////////////////////////////////////
+func putBlacklistEntry(ctx contractapi.TransactionContextInterface, entry *BlacklistEntry) error {
+	entryKey, err := ctx.GetStub().CreateCompositeKey("blacklist", []string{entry.HospitalID})
+	if err != nil {
+		return NewLedgerError("create blacklist key", err)
+	}
+
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return NewLedgerError("marshal blacklist entry", err)
+	}
+
+	if err := ctx.GetStub().PutState(entryKey, entryJSON); err != nil {
+		return NewLedgerError("store blacklist entry", err)
+	}
+
+	return nil
+}
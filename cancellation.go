@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// how the refund of a cancelled policy was worked out
+const (
+	RefundMethodProRata     = "PRO_RATA"     // insurer cancelled, the unused days are refunded in full
+	RefundMethodShortPeriod = "SHORT_PERIOD" // policyholder cancelled, the insurer keeps a share by the short-period scale
+	RefundMethodNone        = "NONE"         // a claim was paid in the term, or no premium was, nothing is refunded
+)
+
+// share of the term premium the insurer keeps when the policyholder cancels, by months of cover used
+var shortPeriodRetention = []struct {
+	Months  int // cover used up to this many months
+	Percent int // percentage of the premium retained
+}{
+	{1, 25},
+	{3, 50},
+	{6, 75},
+}
+
+// STRUCTURE FOR THE CANCELLATION OF A POLICY AND THE PREMIUM IT REFUNDS
+type Cancellation struct {
+	ObjectType    string `json:"docType"`
+	PolicyID      string `json:"policyID"`
+	Reason        string `json:"reason"`
+	EffectiveDate string `json:"effectiveDate"` // YYYY-MM-DD, last day of cover
+	CancelledBy   string `json:"cancelledBy"`   // client ID of the insurer or the policyholder
+	Method        string `json:"method"`        // one of the RefundMethod constants
+	TermStartDate string `json:"termStartDate"` // YYYY-MM-DD, start of the term being cancelled
+	TermDays      int    `json:"termDays"`
+	DaysCovered   int    `json:"daysCovered"` // days of the term up to and including the effective date
+	PremiumPaid   int    `json:"premiumPaid"` // premiums paid for the term
+	ClaimedTotal  int    `json:"claimedTotal"`
+	RefundAmount  int    `json:"refundAmount"`
+	Currency      string `json:"currency,omitempty"`
+	CancelledAt   string `json:"cancelledAt"` // RFC3339, UTC
+}
+
+// ///////////////////////////////////////////////////////////////
+// CANCEL A POLICY FROM A DATE AND WORK OUT THE PREMIUM REFUND //
+// ///////////////////////////////////////////////////////////////
+func (c *HealthInsurance) CancelPolicy(ctx contractapi.TransactionContextInterface, policyID string, reason string, effectiveDate string) (*Cancellation, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, NewValidationError("reason", "cancellation reason must not be empty")
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the insurer cancels at pro-rata, the policyholder at the short-period scale
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	method := RefundMethodProRata
+	if requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer") != nil {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+		method = RefundMethodShortPeriod
+	}
+
+	if err := validatePolicyTransition(policy, PolicyStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	effective, err := parsePolicyDate("effectiveDate", effectiveDate)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// cover cannot be taken back for days already passed, nor cancelled past its end
+	today, err := parsePolicyDate("today", now.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	if effective.Before(today) {
+		return nil, NewValidationError("effectiveDate", fmt.Sprintf("effective date %s must not be in the past", effectiveDate))
+	}
+	if effective.After(end) {
+		return nil, NewValidationError("effectiveDate", fmt.Sprintf("effective date %s is after the policy ends on %s", effectiveDate, policy.EndDate))
+	}
+
+	termStart, err := currentTermStart(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	premiumPaid, err := termPremiumPaid(ctx, policyID, termStart, end)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, NewLedgerError("get client ID", err)
+	}
+
+	cancellation := &Cancellation{
+		ObjectType:    "cancellation",
+		PolicyID:      policyID,
+		Reason:        strings.TrimSpace(reason),
+		EffectiveDate: effective.Format("2006-01-02"),
+		CancelledBy:   clientID,
+		Method:        method,
+		TermStartDate: termStart.Format("2006-01-02"),
+		TermDays:      int(end.Sub(termStart).Hours()/24) + 1,
+		PremiumPaid:   premiumPaid,
+		ClaimedTotal:  policy.ClaimedTotal,
+		Currency:      policy.Currency,
+		CancelledAt:   now.Format(time.RFC3339),
+	}
+	if !effective.Before(termStart) {
+		cancellation.DaysCovered = int(effective.Sub(termStart).Hours()/24) + 1
+	}
+	cancellation.RefundAmount = cancellationRefund(cancellation, termStart, effective)
+
+	if err := putCancellation(ctx, cancellation); err != nil {
+		return nil, err
+	}
+
+	// the policy is closed now, claims for admissions up to the effective date are still accepted
+	previous := *policy
+	if err := transitionPolicy(policy, PolicyStatusCancelled); err != nil {
+		return nil, err
+	}
+	policy.CancellationDate = cancellation.EffectiveDate
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return nil, err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return nil, NewLedgerError("store updated policy", err)
+	}
+
+	if err := setClaimEvent(ctx, "PolicyCancelled", policyID, "", cancellation.RefundAmount); err != nil {
+		return nil, err
+	}
+
+	return cancellation, nil
+}
+
+// /////////////////////////////////////////
+// RETRIEVE THE CANCELLATION OF A POLICY //
+// /////////////////////////////////////////
+func (c *HealthInsurance) GetPolicyCancellation(ctx contractapi.TransactionContextInterface, policyID string) (*Cancellation, error) {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// cancellations are seen by the policyholder and the insurer
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if assertMSP(ctx, config.AllowedInsuranceMSP) != nil {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	cancellationKey, err := ctx.GetStub().CreateCompositeKey("cancellation", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("create cancellation key", err)
+	}
+
+	cancellationJSON, err := ctx.GetStub().GetState(cancellationKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if cancellationJSON == nil {
+		return nil, NewNotFoundError("cancellation", policyID)
+	}
+
+	var cancellation Cancellation
+	if err := json.Unmarshal(cancellationJSON, &cancellation); err != nil {
+		return nil, NewLedgerError("unmarshal cancellation", err)
+	}
+
+	return &cancellation, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////
+// THE REFUND FOR A CANCELLATION, FROM THE PREMIUM PAID AND COVER USED //
+// ///////////////////////////////////////////////////////////////////////
+func cancellationRefund(cancellation *Cancellation, termStart time.Time, effective time.Time) int {
+	// a policy that has paid a claim in the term has used its cover
+	if cancellation.ClaimedTotal > 0 || cancellation.PremiumPaid == 0 {
+		cancellation.Method = RefundMethodNone
+		return 0
+	}
+
+	if cancellation.Method == RefundMethodProRata {
+		return scaleAmount(cancellation.PremiumPaid, cancellation.TermDays-cancellation.DaysCovered, cancellation.TermDays)
+	}
+
+	// the short-period scale goes by whole months of cover, a part month counts in full
+	for _, band := range shortPeriodRetention {
+		if effective.Before(termStart.AddDate(0, band.Months, 0)) {
+			return scaleAmount(cancellation.PremiumPaid, 100-band.Percent, 100)
+		}
+	}
+	return 0
+}
+
+// //////////////////////////////////////////////////////////////
+// FIRST DAY OF THE TERM A POLICY IS IN, THE LATEST RENEWAL'S //
+// //////////////////////////////////////////////////////////////
+func currentTermStart(ctx contractapi.TransactionContextInterface, policy *Policy) (time.Time, error) {
+	termStart, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("policyrenewal", []string{policy.PolicyID})
+	if err != nil {
+		return time.Time{}, NewLedgerError("read renewals from world state", err)
+	}
+	defer iterator.Close()
+
+	// renewal keys end in the term start date, so the last one is the current term
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return time.Time{}, NewLedgerError("iterate renewals", err)
+		}
+
+		var renewal PolicyRenewal
+		if err := json.Unmarshal(result.Value, &renewal); err != nil {
+			return time.Time{}, NewLedgerError("unmarshal renewal", err)
+		}
+		if termStart, err = parsePolicyDate("term start date", renewal.TermStartDate); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return termStart, nil
+}
+
+// //////////////////////////////////////////////////////////////
+// TOTAL THE PREMIUMS PAID FOR DUE DATES WITHIN A POLICY TERM //
+// //////////////////////////////////////////////////////////////
+func termPremiumPaid(ctx contractapi.TransactionContextInterface, policyID string, termStart time.Time, termEnd time.Time) (int, error) {
+	premiums, err := getPremiumsForPolicy(ctx, policyID)
+	if err != nil {
+		return 0, err
+	}
+
+	from := termStart.Format("2006-01-02")
+	to := termEnd.Format("2006-01-02")
+
+	paid := 0
+	for _, premium := range premiums {
+		if premium.Status != "paid" || premium.DueDate < from || premium.DueDate > to {
+			continue
+		}
+		if paid, err = addAmounts("premiumPaid", paid, premium.Amount); err != nil {
+			return 0, err
+		}
+	}
+
+	return paid, nil
+}
+
+// ///////////////////////////////////////////
+// STORE A CANCELLATION IN THE WORLD STATE //
+// ///////////////////////////////////////////
+func putCancellation(ctx contractapi.TransactionContextInterface, cancellation *Cancellation) error {
+	cancellationKey, err := ctx.GetStub().CreateCompositeKey("cancellation", []string{cancellation.PolicyID})
+	if err != nil {
+		return NewLedgerError("create cancellation key", err)
+	}
+
+	cancellationJSON, err := json.Marshal(cancellation)
+	if err != nil {
+		return NewLedgerError("marshal cancellation", err)
+	}
+
+	if err := ctx.GetStub().PutState(cancellationKey, cancellationJSON); err != nil {
+		return NewLedgerError("store cancellation", err)
+	}
+
+	return nil
+}
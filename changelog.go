@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR AN AUDIT RECORD OF A POLICY UPDATE
+type PolicyChangeRecord struct {
+	PolicyID       string            `json:"policyID"`
+	TxID           string            `json:"txID"`
+	ChangedBy      string            `json:"changedBy"` // client ID of the identity that made the change
+	ChangeReason   string            `json:"changeReason"`
+	PreviousValues map[string]string `json:"previousValues"` // JSON of only the fields that changed, before the update
+	Timestamp      string            `json:"timestamp"`      // RFC3339, UTC
+}
+
+// /////////////////////////////////////////////////////
+// RETRIEVE THE CHANGE LOG OF A POLICY, OLDEST FIRST //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) GetPolicyChangeLog(ctx contractapi.TransactionContextInterface, policyID string) ([]*PolicyChangeRecord, error) {
+	// only insurers and auditors can review why policies were changed
+	if err := assertRole(ctx, "insurer", "auditor"); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("policychange", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read change log from world state", err)
+	}
+	defer iterator.Close()
+
+	records := []*PolicyChangeRecord{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate change log", err)
+		}
+
+		var record PolicyChangeRecord
+		if err := json.Unmarshal(result.Value, &record); err != nil {
+			return nil, NewLedgerError("unmarshal change record", err)
+		}
+		records = append(records, &record)
+	}
+
+	// keys are ordered by transaction ID, so sort by time for a chronological log
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp < records[j].Timestamp
+	})
+
+	return records, nil
+}
+
+// /////////////////////////////////////////////////////////////
+// RECORD WHICH FIELDS OF A POLICY AN UPDATE CHANGED AND WHY //
+// /////////////////////////////////////////////////////////////
+func recordPolicyChange(ctx contractapi.TransactionContextInterface, previous *Policy, updated *Policy, changeReason string) error {
+	previousFields, err := policyFields(previous)
+	if err != nil {
+		return err
+	}
+
+	updatedFields, err := policyFields(updated)
+	if err != nil {
+		return err
+	}
+
+	// keep only the old values of fields that changed, the version always does
+	previousValues := map[string]string{}
+	for field, value := range previousFields {
+		if field == "version" {
+			continue
+		}
+		if string(updatedFields[field]) != string(value) {
+			previousValues[field] = string(value)
+		}
+	}
+
+	changedBy, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	record := PolicyChangeRecord{
+		PolicyID:       previous.PolicyID,
+		TxID:           txID,
+		ChangedBy:      changedBy,
+		ChangeReason:   changeReason,
+		PreviousValues: previousValues,
+		Timestamp:      txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return NewLedgerError("marshal change record", err)
+	}
+
+	recordKey, err := ctx.GetStub().CreateCompositeKey("policychange", []string{previous.PolicyID, txID})
+	if err != nil {
+		return NewLedgerError("create change record key", err)
+	}
+
+	if err := ctx.GetStub().PutState(recordKey, recordJSON); err != nil {
+		return NewLedgerError("store change record", err)
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////
+// SPLIT A POLICY INTO ITS JSON-ENCODED FIELDS //
+// ///////////////////////////////////////////////
+func policyFields(policy *Policy) (map[string]json.RawMessage, error) {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, NewLedgerError("marshal policy", err)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(policyJSON, &fields); err != nil {
+		return nil, NewLedgerError("unmarshal policy fields", err)
+	}
+
+	return fields, nil
+}
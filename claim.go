@@ -0,0 +1,1143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// private collection holding the claims, only the insurer and hospital organisations read it
+const claimCollection = "claims-collection"
+
+// STRUCTURE FOR THE PUBLIC RECORD OF A CLAIM, ITS STATUS AND THE HASH OF THE PRIVATE CLAIM
+type ClaimRecord struct {
+	ObjectType         string `json:"docType"`
+	ClaimID            string `json:"claimID"`
+	PolicyID           string `json:"policyID"`
+	Status             string `json:"status"`
+	HospitalID         string `json:"hospitalID,omitempty"`         // kept public for the hospital index
+	DateOfAdmission    string `json:"dateOfAdmission"`              // kept public for the admission index
+	AssignedAdjusterID string `json:"assignedAdjusterID,omitempty"` // kept public for the worklist index
+	ClaimHash          string `json:"claimHash"`                    // SHA-256 of the claim as stored in the private collection
+}
+
+// STRUCTURE FOR A SINGLE HISTORICAL VERSION OF A CLAIM
+type ClaimHistoryEntry struct {
+	ClaimID   string       `json:"claimID"`
+	TxID      string       `json:"txID"`
+	Timestamp string       `json:"timestamp"`
+	IsDeleted bool         `json:"isDeleted"`
+	Record    *ClaimRecord `json:"record,omitempty"` // nil when the version is a deletion
+}
+
+// //////////////////////////////////////////////////////////////////
+// RETRIEVE A SINGLE CLAIM USING CLAIM-ID, REDACTED FOR OUTSIDERS //
+// //////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaim(ctx contractapi.TransactionContextInterface, claimID string) (*Claim, error) {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.projectClaim(ctx, claim)
+}
+
+// ////////////////////////////////////////////////////////
+// READ A CLAIM IN FULL, WHOEVER CALLED THE TRANSACTION //
+// ////////////////////////////////////////////////////////
+func getClaim(ctx contractapi.TransactionContextInterface, claimID string) (*Claim, error) {
+	// look up which policy the claim was filed against
+	claimIndexKey, err := ctx.GetStub().CreateCompositeKey("claimid", []string{claimID})
+	if err != nil {
+		return nil, NewLedgerError("create claim index key", err)
+	}
+
+	policyID, err := ctx.GetStub().GetState(claimIndexKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if policyID == nil {
+		return nil, NewNotFoundError("claim", claimID)
+	}
+
+	claimKey, err := getClaimKey(ctx, string(policyID), claimID)
+	if err != nil {
+		return nil, err
+	}
+
+	claim, err := readClaimAt(ctx, claimKey)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, NewNotFoundError("claim", claimID)
+	}
+
+	return claim, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////
+// CHECK THAT AN OFF-CHAIN FILE MATCHES A DOCUMENT ANCHORED ON A CLAIM //
+// ///////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) VerifyClaimDocument(ctx contractapi.TransactionContextInterface, claimID string, docHash string) (bool, error) {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return false, err
+	}
+
+	// hashes are stored in lower case
+	for _, documentRef := range claim.DocumentRefs {
+		if strings.EqualFold(documentRef.SHA256, strings.TrimSpace(docHash)) {
+			return true, nil
+		}
+	}
+
+	// claims submitted before document references only recorded the hashes
+	return containsFold(claim.DocumentHashes, docHash), nil
+}
+
+// ////////////////////////////////////////////////////////
+// CHECK WHETHER A DOCUMENT HASH IS RECORDED ON A CLAIM //
+// ////////////////////////////////////////////////////////
+// Deprecated: use VerifyClaimDocument
+func (c *HealthInsurance) VerifyDocumentHash(ctx contractapi.TransactionContextInterface, claimID string, documentHash string) (bool, error) {
+	return c.VerifyClaimDocument(ctx, claimID, documentHash)
+}
+
+// //////////////////////////////////////////////////////////////
+// RETRIEVE ALL CLAIMS FOR A POLICY, EARLIEST ADMISSION FIRST //
+// //////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*Claim, error) {
+	role, err := getClientRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "insurer" && role != "doctor" && role != "patient" {
+		return nil, NewUnauthorizedError(fmt.Sprintf("unauthorized access: role %q is not permitted, requires one of insurer, doctor, patient", role))
+	}
+
+	// patients can only see the claims on their own policy
+	if role == "patient" {
+		policy, err := c.GetPolicy(ctx, policyID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	claims, err := getAllClaimsForPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// dates are YYYY-MM-DD, so they sort as strings
+	sort.SliceStable(claims, func(i, j int) bool {
+		return claims[i].DateOfAdmission < claims[j].DateOfAdmission
+	})
+
+	// doctors and other outsiders to a claim see its status and amounts only
+	return c.projectClaims(ctx, claims)
+}
+
+// /////////////////////////////////////////////////////////////
+// RETRIEVE THE CLAIMS FOR A POLICY THAT HAVE A GIVEN STATUS //
+// /////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByPolicyAndStatus(ctx contractapi.TransactionContextInterface, policyID string, status string) ([]*Claim, error) {
+	claims, err := c.GetClaimsByPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// a partial key scan cannot filter on the status, so filter here
+	filtered := []*Claim{}
+	for _, claim := range claims {
+		if claim.Status == status {
+			filtered = append(filtered, claim)
+		}
+	}
+
+	return filtered, nil
+}
+
+// //////////////////////////////////////////////////////////////////////////////
+// RETRIEVE THE CLAIMS WITH A GIVEN STATUS, ONE PAGE AT A TIME (COUCHDB ONLY) //
+// //////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByStatus(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*PaginatedClaimsResult, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// never fetch an unbounded amount of data in a single call
+	if pageSize <= 0 || pageSize > config.MaxPageSize {
+		return nil, NewValidationError("pageSize", fmt.Sprintf("invalid page size %d: must be between 1 and %d", pageSize, config.MaxPageSize))
+	}
+
+	// claims stored before the state machine still carry the lowercase name of the status
+	if current, ok := legacyClaimStatuses[status]; ok {
+		status = current
+	}
+	storedStatuses := []string{status}
+	for legacy, current := range legacyClaimStatuses {
+		if current == status {
+			storedStatuses = append(storedStatuses, legacy)
+		}
+	}
+	// map order is random, sort so every endorser runs the same query
+	sort.Strings(storedStatuses[1:])
+
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "claim",
+			"status":  map[string][]string{"$in": storedStatuses},
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build claim query", err)
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+	if err != nil {
+		return nil, NewLedgerError("query claims", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		// the query matched the public record, the claim itself is in the private collection
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		if claim == nil {
+			continue
+		}
+		claims = append(claims, claim)
+	}
+
+	// callers outside a claim see its status and amounts only
+	claims, err = c.projectClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedClaimsResult{
+		Claims:   claims,
+		Bookmark: metadata.GetBookmark(),
+	}, nil
+}
+
+// //////////////////////////////////////////////////////////////////////////////////
+// RETRIEVE THE CLAIMS FILED AGAINST A HOSPITAL, BY ADMISSION DATE (COUCHDB ONLY) //
+// //////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByHospital(ctx contractapi.TransactionContextInterface, hospitalID string, fromDate string, toDate string) ([]*Claim, error) {
+	// hospitals and the insurer's settlement teams reconcile claims
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := assertMSP(ctx, config.AllowedInsuranceMSP, config.AllowedHospitalMSP); err != nil {
+		return nil, err
+	}
+
+	if hospitalID == "" {
+		return nil, NewValidationError("hospitalID", "hospital ID must not be empty")
+	}
+
+	from, err := parsePolicyDate("fromDate", fromDate)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parsePolicyDate("toDate", toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if to.Before(from) {
+		return nil, NewValidationError("toDate", "toDate must not be before fromDate")
+	}
+
+	// YYYY-MM-DD strings compare in date order, served by the docType/hospitalID/dateOfAdmission index
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType":    "claim",
+			"hospitalID": hospitalID,
+			"dateOfAdmission": map[string]string{
+				"$gte": from.Format("2006-01-02"),
+				"$lte": to.Format("2006-01-02"),
+			},
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build claim query", err)
+	}
+
+	iterator, err := ctx.GetStub().GetQueryResult(string(query))
+	if err != nil {
+		return nil, NewLedgerError("query claims", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		// the query matched the public record, the claim itself is in the private collection
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		if claim == nil {
+			continue
+		}
+
+		// pre-authorizations share the claim documents but are not claims themselves
+		if isPreAuthStatus(claim.Status) {
+			continue
+		}
+		claims = append(claims, claim)
+	}
+
+	// earliest admission first, ties broken by ID so the order is deterministic
+	sort.SliceStable(claims, func(i, j int) bool {
+		if claims[i].DateOfAdmission != claims[j].DateOfAdmission {
+			return claims[i].DateOfAdmission < claims[j].DateOfAdmission
+		}
+		return claims[i].ClaimID < claims[j].ClaimID
+	})
+
+	// callers outside a claim see its status and amounts only
+	return c.projectClaims(ctx, claims)
+}
+
+// ///////////////////////////////////////////////////////////////////////////////////
+// RETRIEVE THE CLAIMS ADMITTED IN A DATE RANGE, ONE PAGE AT A TIME (COUCHDB ONLY) //
+// ///////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsByDateRange(ctx contractapi.TransactionContextInterface, fromDate string, toDate string, pageSize int32, bookmark string) (*PaginatedClaimsResult, error) {
+	// only insurers and auditors sample claims for reporting
+	if err := assertRole(ctx, "insurer", "auditor"); err != nil {
+		return nil, err
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// never fetch an unbounded amount of data in a single call
+	if pageSize <= 0 || pageSize > config.MaxPageSize {
+		return nil, NewValidationError("pageSize", fmt.Sprintf("invalid page size %d: must be between 1 and %d", pageSize, config.MaxPageSize))
+	}
+
+	from, err := parsePolicyDate("fromDate", fromDate)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parsePolicyDate("toDate", toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if to.Before(from) {
+		return nil, NewValidationError("toDate", "toDate must not be before fromDate")
+	}
+
+	// YYYY-MM-DD strings compare in date order, served and sorted by the docType/dateOfAdmission index
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "claim",
+			"dateOfAdmission": map[string]string{
+				"$gte": from.Format("2006-01-02"),
+				"$lte": to.Format("2006-01-02"),
+			},
+		},
+		"sort": []map[string]string{
+			{"docType": "asc"},
+			{"dateOfAdmission": "asc"},
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build claim query", err)
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+	if err != nil {
+		return nil, NewLedgerError("query claims", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		// the query matched the public record, the claim itself is in the private collection
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		if claim == nil {
+			continue
+		}
+		claims = append(claims, claim)
+	}
+
+	// callers outside a claim see its status and amounts only
+	claims, err = c.projectClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedClaimsResult{
+		Claims:   claims,
+		Bookmark: metadata.GetBookmark(),
+	}, nil
+}
+
+// //////////////////////////////////////////////////////
+// RETRIEVE EVERY VERSION OF EVERY CLAIM FOR A POLICY //
+// //////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimHistoryForPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*ClaimHistoryEntry, error) {
+	// the history lives on the public records, the private claims keep only their latest version
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("claim", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read claim records from world state", err)
+	}
+	defer iterator.Close()
+
+	history := []*ClaimHistoryEntry{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(result.Key)
+		if err != nil {
+			return nil, NewLedgerError("split claim key", err)
+		}
+		claimID := keyParts[1]
+
+		entries, err := getClaimHistory(ctx, result.Key, claimID)
+		if err != nil {
+			return nil, err
+		}
+
+		// callers outside a claim only follow its status
+		claim, err := readClaimAt(ctx, result.Key)
+		if err != nil {
+			return nil, err
+		}
+		fullAccess := false
+		if claim != nil {
+			if fullAccess, err = c.canReadClaim(ctx, claim); err != nil {
+				return nil, err
+			}
+		}
+		if !fullAccess {
+			for _, entry := range entries {
+				if entry.Record != nil {
+					entry.Record = redactClaimRecord(entry.Record)
+				}
+			}
+		}
+
+		history = append(history, entries...)
+	}
+
+	return history, nil
+}
+
+// ////////////////////////////////////////////////
+// RETRIEVE EVERY VERSION OF A SINGLE CLAIM KEY //
+// ////////////////////////////////////////////////
+func getClaimHistory(ctx contractapi.TransactionContextInterface, claimKey string, claimID string) ([]*ClaimHistoryEntry, error) {
+	historyIterator, err := ctx.GetStub().GetHistoryForKey(claimKey)
+	if err != nil {
+		return nil, NewLedgerError("read history for claim "+claimID, err)
+	}
+	defer historyIterator.Close()
+
+	entries := []*ClaimHistoryEntry{}
+	for historyIterator.HasNext() {
+		modification, err := historyIterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claim history", err)
+		}
+
+		entry := &ClaimHistoryEntry{
+			ClaimID:   claimID,
+			TxID:      modification.GetTxId(),
+			Timestamp: modification.GetTimestamp().AsTime().UTC().Format(time.RFC3339),
+			IsDeleted: modification.GetIsDelete(),
+		}
+
+		// deletions carry no value
+		if !entry.IsDeleted {
+			var record ClaimRecord
+			if err := json.Unmarshal(modification.GetValue(), &record); err != nil {
+				return nil, NewLedgerError("unmarshal claim record", err)
+			}
+			entry.Record = &record
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ///////////////////////////////////////////////////////
+// APPROVE A PENDING CLAIM, IN FULL OR WITH DEDUCTIONS //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) ApproveClaim(ctx contractapi.TransactionContextInterface, claimID string, approvedAmount int, remarks string, deductionReasonsJSON string) error {
+	deductionReasons, err := parseStringList("deductionReasons", deductionReasonsJSON)
+	if err != nil {
+		return err
+	}
+
+	return c.decideClaim(ctx, claimID, ClaimStatusApproved, approvedAmount, remarks, deductionReasons)
+}
+
+// //////////////////////////
+// REJECT A PENDING CLAIM //
+// //////////////////////////
+func (c *HealthInsurance) RejectClaim(ctx contractapi.TransactionContextInterface, claimID string, reason string) error {
+	if reason == "" {
+		return NewValidationError("reason", "rejection reason must not be empty")
+	}
+
+	return c.decideClaim(ctx, claimID, ClaimStatusRejected, 0, reason, nil)
+}
+
+// ///////////////////////////////////////////////////////////////
+// PAY OUT AN APPROVED REIMBURSEMENT CLAIM TO THE POLICYHOLDER //
+// ///////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ProcessReimbursement(ctx contractapi.TransactionContextInterface, claimID string, bankReference string) error {
+	if strings.TrimSpace(bankReference) == "" {
+		return NewValidationError("bankReference", "bank reference must not be empty")
+	}
+
+	claim, _, err := c.getDecidableClaim(ctx, claimID, ClaimStatusReimbursed)
+	if err != nil {
+		return err
+	}
+
+	// the reimbursement is the payout, so only now does the claim count against the policy
+	if err := c.adjustClaimedTotal(ctx, claim, payableAmount(claim)); err != nil {
+		return err
+	}
+
+	claim.Status = ClaimStatusReimbursed
+	claim.BankReference = bankReference
+
+	if err := setClaimEvent(ctx, "ClaimReimbursed", claim.PolicyID, claimID, payableAmount(claim)); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// ///////////////////////////////////////////////////////
+// WITHDRAW A CLAIM THE INSURER HAS NOT YET DECIDED ON //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) WithdrawClaim(ctx contractapi.TransactionContextInterface, claimID string) error {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	// only the policyholder can withdraw their own claim
+	if err := assertPolicyOwner(ctx, policy); err != nil {
+		return err
+	}
+
+	if err := transitionClaim(claim, ClaimStatusWithdrawn); err != nil {
+		return err
+	}
+
+	// claims filed before claim types were charged on submission, give the cover back
+	if claim.ClaimType == "" {
+		if err := c.adjustClaimedTotal(ctx, claim, -claim.ClaimAmount); err != nil {
+			return err
+		}
+	}
+
+	if err := releaseAssignedAdjuster(ctx, claim); err != nil {
+		return err
+	}
+
+	// a withdrawn claim no longer counts towards the policy year's claim limit
+	if err := uncountClaim(ctx, policy, claim); err != nil {
+		return err
+	}
+
+	if err := setChaincodeEvent(ctx, "ClaimWithdrawn", claim.PolicyID, claimID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// ///////////////////////////////////////////
+// UNDO AN APPROVAL THAT WAS MADE IN ERROR //
+// ///////////////////////////////////////////
+func (c *HealthInsurance) ReverseClaim(ctx contractapi.TransactionContextInterface, claimID string, reason string) error {
+	// reversals are reserved for administrators of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "reversal reason must not be empty")
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	// the batch total would no longer match what its hospital is paid
+	if claim.SettlementBatchID != "" {
+		return NewStateError(fmt.Sprintf("claim %s is in settlement batch %s and can no longer be reversed", claimID, claim.SettlementBatchID))
+	}
+
+	// a reimbursement awaiting the bank transfer has not been charged yet
+	charged := claim.Status != ClaimStatusReimbursementPending
+
+	if err := transitionClaim(claim, ClaimStatusReversed); err != nil {
+		return err
+	}
+
+	if charged {
+		if err := c.adjustClaimedTotal(ctx, claim, -payableAmount(claim)); err != nil {
+			return err
+		}
+	}
+
+	// the reversed claim no longer uses up the policyholder's deductible
+	if err := c.releaseDeductible(ctx, claim); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// the access log is append-only, so the reversal stays on record
+	if err := logAccessEvent(ctx, claim.PolicyID, fmt.Sprintf("reversed claim %s: %s", claimID, reason), clientID, "admin"); err != nil {
+		return err
+	}
+
+	if err := setChaincodeEvent(ctx, "ClaimReversed", claim.PolicyID, claimID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////
+// MOVE A PENDING CLAIM TO ITS FINAL STATUS, THE NOTE IS THE REMARKS OR REJECTION REASON //
+// /////////////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) decideClaim(ctx contractapi.TransactionContextInterface, claimID string, status string, approvedAmount int, note string, deductionReasons []string) error {
+	claim, config, err := c.getDecidableClaim(ctx, claimID, status)
+	if err != nil {
+		return err
+	}
+
+	// a held approval may be confirmed or rejected by any adjuster, not only the assigned one
+	if claim.Status != ClaimStatusPendingSecondApproval {
+		if err := assertClaimAssignee(ctx, claim); err != nil {
+			return err
+		}
+	}
+
+	if status == ClaimStatusApproved {
+		// the second adjuster confirms the first one's decision as it was made
+		if claim.Status == ClaimStatusPendingSecondApproval {
+			if err := confirmSecondApproval(ctx, claim, approvedAmount); err != nil {
+				return err
+			}
+			approvedAmount, note, deductionReasons = claim.FirstApproval.AssessedAmount, claim.FirstApproval.Remarks, claim.FirstApproval.DeductionReasons
+		}
+		givenReasons := append([]string{}, deductionReasons...)
+
+		// billing above the agreed tariff is never approved without an adjuster looking at it
+		if claim.OverTariffAmount > 0 && claim.AssignedAdjusterID == "" {
+			return NewStateError(fmt.Sprintf("claim %s bills %d above the agreed tariff and must be reviewed by an assigned adjuster", claimID, claim.OverTariffAmount))
+		}
+
+		policy, err := c.GetPolicy(ctx, claim.PolicyID)
+		if err != nil {
+			return err
+		}
+
+		// the policy's terms may have changed since submission, so eligibility is checked again
+		coverageMatched, err := checkEligibility(policy, claim.CoverageType, claim.DiagnosisCodes)
+		if err != nil {
+			return err
+		}
+		claim.UnmatchedCoverage = !coverageMatched
+		if claim.UnmatchedCoverage && claim.AssignedAdjusterID == "" {
+			return NewStateError(fmt.Sprintf("claim %s matches no covered category of policy %s and must be reviewed by an assigned adjuster", claimID, claim.PolicyID))
+		}
+
+		// claims under investigation are only approved by the adjuster investigating them
+		if claim.UnderInvestigation && claim.AssignedAdjusterID == "" {
+			return NewStateError(fmt.Sprintf("claim %s has a risk score of %d and must be investigated by an assigned adjuster", claimID, claim.RiskScore))
+		}
+
+		// the room-rent limit is applied on-chain, line by line
+		if reason := applyRoomRentLimit(policy, claim); reason != "" {
+			deductionReasons = append(deductionReasons, reason)
+		}
+
+		// without an amount, the claim is approved for everything the policy pays
+		if approvedAmount == 0 {
+			approvedAmount = claim.ClaimAmount - claim.RoomRentDeduction
+		}
+
+		if err := validateApprovedAmount(claim, approvedAmount); err != nil {
+			return err
+		}
+
+		// the annual deductible comes off the assessed amount before the co-pay
+		breakdown, tracker, err := computePayout(ctx, policy, claim, approvedAmount)
+		if err != nil {
+			return err
+		}
+		if breakdown.PayableAmount == 0 {
+			return NewStateError(fmt.Sprintf("claim %s falls entirely within the annual deductible of policy %s, it cannot be approved", claimID, claim.PolicyID))
+		}
+		assessedAmount := approvedAmount
+		if breakdown.DeductibleApplied > 0 {
+			deductionReasons = append(deductionReasons, fmt.Sprintf("annual deductible of %d, %d applied to this claim", policy.Deductible, breakdown.DeductibleApplied))
+		}
+		approvedAmount = breakdown.PayableAmount
+
+		if err := validateDeductionReasons(claim, approvedAmount, deductionReasons); err != nil {
+			return err
+		}
+
+		// a large payout is held, unpaid and uncharged, until a second adjuster confirms it
+		if config.SecondApprovalThreshold > 0 && approvedAmount > config.SecondApprovalThreshold && claim.Status != ClaimStatusPendingSecondApproval {
+			return holdForSecondApproval(ctx, claim, assessedAmount, approvedAmount, note, givenReasons)
+		}
+
+		if breakdown.DeductibleApplied > 0 {
+			if err := putDeductibleTracker(ctx, tracker); err != nil {
+				return err
+			}
+		}
+		claim.ApprovedAmount = approvedAmount
+		claim.PayoutBreakdown = breakdown
+		claim.DeductionReasons = deductionReasons
+		claim.Remarks = note
+
+		// an approved reimbursement claim still waits for the bank transfer
+		if claim.ClaimType == ClaimTypeReimbursement {
+			status = ClaimStatusReimbursementPending
+		} else if approvedAmount < claim.ClaimAmount {
+			status = ClaimStatusPartiallyApproved
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	claim.DecidedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	if status == ClaimStatusRejected {
+		claim.RejectionReason = note
+		claim.RejectedAt = claim.DecidedAt
+	}
+
+	switch {
+	case claim.ClaimType == "":
+		// claims filed before claim types were charged in full on submission, release what is not paid
+		released := claim.ClaimAmount - claim.ApprovedAmount
+		if status == ClaimStatusRejected {
+			released = claim.ClaimAmount
+		}
+		if released > 0 {
+			if err := c.adjustClaimedTotal(ctx, claim, -released); err != nil {
+				return err
+			}
+		}
+	case isApprovedClaimStatus(status):
+		if err := c.adjustClaimedTotal(ctx, claim, claim.ApprovedAmount); err != nil {
+			return err
+		}
+	}
+
+	if err := transitionClaim(claim, status); err != nil {
+		return err
+	}
+
+	// record what each co-insurer pays towards an approved claim
+	if status != ClaimStatusRejected {
+		policy, err := c.GetPolicy(ctx, claim.PolicyID)
+		if err != nil {
+			return err
+		}
+
+		if len(policy.CoInsurers) > 0 {
+			claim.CoInsuranceBreakdown, err = splitClaimAmount(policy, claim.ApprovedAmount)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the primary policy has paid, what is left is claimed from a linked secondary policy
+		if err := c.coordinateBenefits(ctx, policy, claim); err != nil {
+			return err
+		}
+	}
+
+	// the decided claim no longer counts towards the adjuster's workload
+	if err := releaseAssignedAdjuster(ctx, claim); err != nil {
+		return err
+	}
+
+	// claims filed before claim types are paid out on-chain on approval,
+	// a cashless claim is paid to its hospital once, when its settlement batch is paid
+	if isApprovedClaimStatus(status) && claim.ClaimType != ClaimTypeCashless {
+		if err := c.settleClaimPayment(ctx, config, claim); err != nil {
+			return err
+		}
+	}
+
+	// notify off-chain listeners of the decision and the amount it was for
+	eventType, eventAmount := "ClaimApproved", claim.ApprovedAmount
+	if status == ClaimStatusRejected {
+		eventType, eventAmount = "ClaimRejected", claim.ClaimAmount
+	}
+
+	if err := setClaimEvent(ctx, eventType, claim.PolicyID, claimID, eventAmount); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// ///////////////////////////////////////////////////////////////////
+// CHECK AN APPROVED AMOUNT AGAINST THE INSURED PORTION OF A CLAIM //
+// ///////////////////////////////////////////////////////////////////
+func validateApprovedAmount(claim *Claim, approvedAmount int) error {
+	// the co-pay and sub-limits were already applied to the claim amount on submission
+	payable := claim.ClaimAmount - claim.RoomRentDeduction
+	if approvedAmount <= 0 || approvedAmount > payable {
+		return NewValidationError("approvedAmount", fmt.Sprintf("invalid approved amount %d: must be between 1 and the payable amount %d", approvedAmount, payable))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////
+// EVERY DEDUCTION FROM THE CLAIM AMOUNT MUST BE EXPLAINED //
+// ///////////////////////////////////////////////////////////
+func validateDeductionReasons(claim *Claim, approvedAmount int, deductionReasons []string) error {
+	for _, reason := range deductionReasons {
+		if reason == "" {
+			return NewValidationError("deductionReasons", "deduction reasons must not be empty")
+		}
+	}
+
+	if approvedAmount < claim.ClaimAmount && len(deductionReasons) == 0 {
+		return NewValidationError("deductionReasons", fmt.Sprintf("approving %d of the claimed %d requires at least one deduction reason", approvedAmount, claim.ClaimAmount))
+	}
+	if approvedAmount == claim.ClaimAmount && len(deductionReasons) > 0 {
+		return NewValidationError("deductionReasons", "a claim approved in full has no deductions")
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////
+// AMOUNT PAID OUT FOR A CLAIM, FALLING BACK TO THE CLAIM AMOUNT FOR OLDER APPROVALS //
+// /////////////////////////////////////////////////////////////////////////////////////
+func payableAmount(claim *Claim) int {
+	if claim.ApprovedAmount > 0 {
+		return claim.ApprovedAmount
+	}
+
+	return claim.ClaimAmount
+}
+
+// //////////////////////////////////////////////////////////////
+// AMOUNT A CLAIM CURRENTLY COUNTS TOWARDS ITS POLICY'S TOTAL //
+// //////////////////////////////////////////////////////////////
+func chargedAmount(claim *Claim) int {
+	switch claim.Status {
+	case ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusSettled, ClaimStatusReimbursed:
+		return payableAmount(claim)
+	}
+
+	// claims filed before claim types were charged in full on submission
+	if claim.ClaimType == "" && isOpenClaimStatus(claim.Status) {
+		return claim.ClaimAmount
+	}
+
+	return 0
+}
+
+// ///////////////////////////////////////////////////////////
+// READ A CLAIM AND CHECK THAT THE CALLER MAY DECIDE ON IT //
+// ///////////////////////////////////////////////////////////
+func (c *HealthInsurance) getDecidableClaim(ctx contractapi.TransactionContextInterface, claimID string, status string) (*Claim, *ChaincodeConfig, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// only claims adjusters of the insurer can decide on claims
+	if err := assertRole(ctx, claimDecisionRoles...); err != nil {
+		return nil, nil, err
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// role attributes alone can be forged, so also require an organisation carrying the policy
+	if len(policy.CoInsurers) > 0 {
+		if err := assertCurrentMSPIsCoInsurer(ctx, policy); err != nil {
+			return nil, nil, err
+		}
+	} else if err := assertMSP(ctx, config.AllowedInsuranceMSP); err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateClaimTransition(claim, status); err != nil {
+		return nil, nil, err
+	}
+
+	return claim, config, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////
+// CHARGE A PAID CLAIM TO ITS POLICY, OR RELEASE IT WITH A NEGATIVE AMOUNT //
+// ///////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) adjustClaimedTotal(ctx contractapi.TransactionContextInterface, claim *Claim, amount int) error {
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	claimedTotal, err := addAmounts("claimedTotal", policy.ClaimedTotal, amount)
+	if err != nil {
+		return err
+	}
+
+	// other claims may have been paid since this one was submitted
+	if amount > 0 {
+		if claimedTotal > policy.SumAssured {
+			return NewStateError(fmt.Sprintf("paying claim %s would exceed the sum assured of policy %s", claim.ClaimID, policy.PolicyID))
+		}
+		if payable, err := subLimitedAmount(policy, amount, claim.CoverageType); err != nil || payable < amount {
+			return NewStateError(fmt.Sprintf("paying claim %s would exceed the %q sub-limit of policy %s", claim.ClaimID, claim.CoverageType, policy.PolicyID))
+		}
+	}
+
+	previous := *policy
+	policy.ClaimedTotal = claimedTotal
+	addSubLimitUsage(policy, claim.CoverageType, amount)
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////
+// BUILD THE COMPOSITE KEY FOR A SINGLE CLAIM //
+// //////////////////////////////////////////////
+func getClaimKey(ctx contractapi.TransactionContextInterface, policyID string, claimID string) (string, error) {
+	claimKey, err := ctx.GetStub().CreateCompositeKey("claim", []string{policyID, claimID})
+	if err != nil {
+		return "", NewLedgerError("create claim key", err)
+	}
+
+	return claimKey, nil
+}
+
+// /////////////////////////////////////////////////////////
+// READ EVERY CLAIM FILED AGAINST A POLICY, IN KEY ORDER //
+// /////////////////////////////////////////////////////////
+func getAllClaimsForPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*Claim, error) {
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		var claim Claim
+		if err := json.Unmarshal(result.Value, &claim); err != nil {
+			return nil, NewLedgerError("unmarshal claim", err)
+		}
+		claims = append(claims, &claim)
+	}
+
+	return claims, nil
+}
+
+// //////////////////////////////////////////////////////////////
+// RE-FILE A CLAIM UNDER ANOTHER POLICY, KEEPING ITS CLAIM ID //
+// //////////////////////////////////////////////////////////////
+func moveClaim(ctx contractapi.TransactionContextInterface, claim *Claim, policyID string) error {
+	oldKey, err := getClaimKey(ctx, claim.PolicyID, claim.ClaimID)
+	if err != nil {
+		return err
+	}
+
+	// the old key's history ends here, the claim carries on under the new policy's key
+	if err := ctx.GetStub().DelPrivateData(claimCollection, oldKey); err != nil {
+		return NewLedgerError("delete claim details", err)
+	}
+	if err := ctx.GetStub().DelState(oldKey); err != nil {
+		return NewLedgerError("delete claim record", err)
+	}
+
+	claim.PolicyID = policyID
+	if err := putClaim(ctx, claim); err != nil {
+		return err
+	}
+
+	return indexClaimID(ctx, claim.ClaimID, policyID)
+}
+
+// /////////////////////////////////////////////////////
+// MAP A CLAIM ID TO THE POLICY IT WAS FILED AGAINST //
+// /////////////////////////////////////////////////////
+func indexClaimID(ctx contractapi.TransactionContextInterface, claimID string, policyID string) error {
+	claimIndexKey, err := ctx.GetStub().CreateCompositeKey("claimid", []string{claimID})
+	if err != nil {
+		return NewLedgerError("create claim index key", err)
+	}
+
+	if err := ctx.GetStub().PutState(claimIndexKey, []byte(policyID)); err != nil {
+		return NewLedgerError("store claim index", err)
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////////
+// READ THE PRIVATE CLAIM STORED UNDER A KEY, NIL WHEN THERE IS NONE //
+// /////////////////////////////////////////////////////////////////////
+func readClaimAt(ctx contractapi.TransactionContextInterface, claimKey string) (*Claim, error) {
+	claimJSON, err := ctx.GetStub().GetPrivateData(claimCollection, claimKey)
+	if err != nil {
+		return nil, NewLedgerError("read from private data", err)
+	}
+	if claimJSON == nil {
+		return nil, nil
+	}
+
+	var claim Claim
+	// convert the JSON data to a claim struct
+	if err := json.Unmarshal(claimJSON, &claim); err != nil {
+		return nil, NewLedgerError("unmarshal claim", err)
+	}
+
+	return &claim, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////
+// STORE A CLAIM IN THE PRIVATE COLLECTION AND ITS PUBLIC RECORD IN WORLD STATE //
+// ////////////////////////////////////////////////////////////////////////////////
+func putClaim(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	claimKey, err := getClaimKey(ctx, claim.PolicyID, claim.ClaimID)
+	if err != nil {
+		return err
+	}
+
+	claimJSON, err := json.Marshal(claim)
+	if err != nil {
+		return NewLedgerError("marshal claim details", err)
+	}
+
+	// diagnoses, bills, documents and amounts stay with the organisations in the collection
+	if err := ctx.GetStub().PutPrivateData(claimCollection, claimKey, claimJSON); err != nil {
+		return NewLedgerError("store claim details", err)
+	}
+
+	// the public record carries what the indexes query on, and its history is the claim's status trail
+	claimHash := sha256.Sum256(claimJSON)
+	recordJSON, err := json.Marshal(ClaimRecord{
+		ObjectType:         "claim",
+		ClaimID:            claim.ClaimID,
+		PolicyID:           claim.PolicyID,
+		Status:             claim.Status,
+		HospitalID:         claim.HospitalID,
+		DateOfAdmission:    claim.DateOfAdmission,
+		AssignedAdjusterID: claim.AssignedAdjusterID,
+		ClaimHash:          hex.EncodeToString(claimHash[:]),
+	})
+	if err != nil {
+		return NewLedgerError("marshal claim record", err)
+	}
+
+	if err := ctx.GetStub().PutState(claimKey, recordJSON); err != nil {
+		return NewLedgerError("store claim record", err)
+	}
+
+	return nil
+}
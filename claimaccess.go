@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ////////////////////////////////////////////////////////////////////////////////////
+// THE CLAIM AS THE CALLER MAY SEE IT, IN FULL OR REDUCED TO ITS STATUS AND AMOUNTS //
+// ////////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) projectClaim(ctx contractapi.TransactionContextInterface, claim *Claim) (*Claim, error) {
+	fullAccess, err := c.canReadClaim(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
+	if fullAccess {
+		return claim, nil
+	}
+
+	return redactClaim(claim), nil
+}
+
+// ///////////////////////////////////////////////////////////
+// A LIST OF CLAIMS AS THE CALLER MAY SEE THEM, ONE BY ONE //
+// ///////////////////////////////////////////////////////////
+func (c *HealthInsurance) projectClaims(ctx contractapi.TransactionContextInterface, claims []*Claim) ([]*Claim, error) {
+	projected := make([]*Claim, 0, len(claims))
+	for _, claim := range claims {
+		visible, err := c.projectClaim(ctx, claim)
+		if err != nil {
+			return nil, err
+		}
+		projected = append(projected, visible)
+	}
+
+	return projected, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////
+// WHETHER THE CALLER IS THE INSURER, THE POLICYHOLDER OR THE HOSPITAL THAT FILED IT //
+// /////////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) canReadClaim(ctx contractapi.TransactionContextInterface, claim *Claim) (bool, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// the insurer's claims staff work on every claim
+	if requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "claims_adjuster", "insurer", "admin") == nil {
+		return true, nil
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, NewLedgerError("get client ID", err)
+	}
+
+	// a hospital sees the claims it filed, not those other hospitals filed under the same organisation
+	if assertMSP(ctx, config.AllowedHospitalMSP) == nil && claim.SubmittedBy != "" && claim.SubmittedBy == clientID {
+		return true, nil
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return false, err
+	}
+
+	return policy.OwnerCertID == clientID, nil
+}
+
+// ////////////////////////////////////////////////////////////
+// KEEP ONLY WHAT ANY NETWORK MEMBER MAY KNOW ABOUT A CLAIM //
+// ////////////////////////////////////////////////////////////
+func redactClaim(claim *Claim) *Claim {
+	// diagnoses, bills, documents and the people involved are left out
+	return &Claim{
+		ObjectType:     claim.ObjectType,
+		ClaimID:        claim.ClaimID,
+		PolicyID:       claim.PolicyID,
+		ClaimAmount:    claim.ClaimAmount,
+		GrossAmount:    claim.GrossAmount,
+		ApprovedAmount: claim.ApprovedAmount,
+		Currency:       claim.Currency,
+		Status:         claim.Status,
+	}
+}
+
+// /////////////////////////////////////////////////////////
+// KEEP ONLY THE STATUS TRAIL OF A CLAIM'S PUBLIC RECORD //
+// /////////////////////////////////////////////////////////
+func redactClaimRecord(record *ClaimRecord) *ClaimRecord {
+	// the hospital, admission date and adjuster are left out
+	return &ClaimRecord{
+		ObjectType: record.ObjectType,
+		ClaimID:    record.ClaimID,
+		PolicyID:   record.PolicyID,
+		Status:     record.Status,
+		ClaimHash:  record.ClaimHash,
+	}
+}
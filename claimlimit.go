@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE NUMBER OF CLAIMS FILED FOR A COVERAGE TYPE IN ONE POLICY YEAR
+type ClaimCounter struct {
+	ObjectType   string `json:"docType"`
+	PolicyID     string `json:"policyID"`
+	PolicyYear   string `json:"policyYear"` // first day of the policy year, YYYY-MM-DD
+	CoverageType string `json:"coverageType"`
+	Count        int    `json:"count"`
+}
+
+// //////////////////////////////////////////////////////////////////////
+// RETRIEVE HOW MANY CLAIMS OF A COVERAGE TYPE A POLICY YEAR HAS SEEN //
+// //////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimCount(ctx contractapi.TransactionContextInterface, policyID string, coverageType string, date string) (*ClaimCounter, error) {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	policyYear, err := policyYearStart(policy, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// counters are kept under the coverage type's name as the sub-limit spells it
+	if subLimit := findSubLimit(policy, coverageType); subLimit != nil {
+		coverageType = subLimit.CoverageType
+	}
+
+	return getClaimCounter(ctx, policyID, policyYear, coverageType)
+}
+
+// /////////////////////////////////////////////////////////////////////////////////
+// COUNT A NEW CLAIM AGAINST ITS COVERAGE TYPE'S LIMIT, REFUSING IT ONCE USED UP //
+// /////////////////////////////////////////////////////////////////////////////////
+func countClaim(ctx contractapi.TransactionContextInterface, policy *Policy, claim *Claim) (*ClaimCounter, error) {
+	subLimit := findSubLimit(policy, claim.CoverageType)
+	if subLimit == nil || subLimit.MaxClaimsPerYear == 0 {
+		return nil, nil
+	}
+
+	// the year is the one the admission falls in, not the one the claim is filed in
+	policyYear, err := policyYearStart(policy, claim.DateOfAdmission)
+	if err != nil {
+		return nil, err
+	}
+
+	counter, err := getClaimCounter(ctx, policy.PolicyID, policyYear, subLimit.CoverageType)
+	if err != nil {
+		return nil, err
+	}
+	if counter.Count >= subLimit.MaxClaimsPerYear {
+		return nil, NewClaimLimitError(counter, subLimit.MaxClaimsPerYear)
+	}
+
+	// the caller stores the counter once the claim is accepted
+	counter.Count++
+	claim.CountedPolicyYear = policyYear
+
+	return counter, nil
+}
+
+// /////////////////////////////////////////////////////////////
+// GIVE BACK THE CLAIM A WITHDRAWN CLAIM TOOK FROM THE LIMIT //
+// /////////////////////////////////////////////////////////////
+func uncountClaim(ctx contractapi.TransactionContextInterface, policy *Policy, claim *Claim) error {
+	if claim.CountedPolicyYear == "" {
+		return nil
+	}
+
+	// the sub-limit may have been dropped since, the claim's own type is the best guess then
+	coverageType := claim.CoverageType
+	if subLimit := findSubLimit(policy, claim.CoverageType); subLimit != nil {
+		coverageType = subLimit.CoverageType
+	}
+
+	counter, err := getClaimCounter(ctx, claim.PolicyID, claim.CountedPolicyYear, coverageType)
+	if err != nil {
+		return err
+	}
+	if counter.Count > 0 {
+		counter.Count--
+	}
+	claim.CountedPolicyYear = ""
+
+	return putClaimCounter(ctx, counter)
+}
+
+// /////////////////////////////////////////////////////////
+// READ A CLAIM COUNTER, AT ZERO IF NO CLAIM WAS COUNTED //
+// /////////////////////////////////////////////////////////
+func getClaimCounter(ctx contractapi.TransactionContextInterface, policyID string, policyYear string, coverageType string) (*ClaimCounter, error) {
+	counterKey, err := ctx.GetStub().CreateCompositeKey("claimcount", []string{policyID, policyYear, coverageType})
+	if err != nil {
+		return nil, NewLedgerError("create claim counter key", err)
+	}
+
+	counterJSON, err := ctx.GetStub().GetState(counterKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+
+	counter := ClaimCounter{
+		ObjectType:   "claimCounter",
+		PolicyID:     policyID,
+		PolicyYear:   policyYear,
+		CoverageType: coverageType,
+	}
+	if counterJSON != nil {
+		if err := json.Unmarshal(counterJSON, &counter); err != nil {
+			return nil, NewLedgerError("unmarshal claim counter", err)
+		}
+	}
+
+	return &counter, nil
+}
+
+// ////////////////////////////////////////////
+// STORE A CLAIM COUNTER IN THE WORLD STATE //
+// ////////////////////////////////////////////
+func putClaimCounter(ctx contractapi.TransactionContextInterface, counter *ClaimCounter) error {
+	counterKey, err := ctx.GetStub().CreateCompositeKey("claimcount", []string{counter.PolicyID, counter.PolicyYear, counter.CoverageType})
+	if err != nil {
+		return NewLedgerError("create claim counter key", err)
+	}
+
+	counterJSON, err := json.Marshal(counter)
+	if err != nil {
+		return NewLedgerError("marshal claim counter", err)
+	}
+
+	if err := ctx.GetStub().PutState(counterKey, counterJSON); err != nil {
+		return NewLedgerError("store claim counter", err)
+	}
+
+	return nil
+}
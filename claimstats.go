@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE CLAIMS IN ONE STATUS AND WHAT THEY ADD UP TO
+type ClaimStatusTotals struct {
+	Count          int `json:"count"`
+	ClaimedAmount  int `json:"claimedAmount"`  // insured amount claimed, after co-pay
+	ApprovedAmount int `json:"approvedAmount"` // amount the insurer agreed to pay
+}
+
+// STRUCTURE FOR CLAIM COUNTS AND AMOUNTS GROUPED BY STATUS
+type ClaimStatistics struct {
+	PolicyID       string                       `json:"policyID,omitempty"` // empty for the whole portfolio
+	TotalClaims    int                          `json:"totalClaims"`        // pre-authorizations are not counted
+	ClaimedAmount  int                          `json:"claimedAmount"`
+	ApprovedAmount int                          `json:"approvedAmount"`
+	ByStatus       map[string]ClaimStatusTotals `json:"byStatus"`
+}
+
+// ////////////////////////////////////////////////////////////
+// COUNT AND TOTAL EVERY CLAIM ON THE LEDGER, BY ITS STATUS //
+// ////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimStatistics(ctx contractapi.TransactionContextInterface) (*ClaimStatistics, error) {
+	if err := assertPortfolioReader(ctx); err != nil {
+		return nil, err
+	}
+
+	return aggregateClaims(ctx, []string{})
+}
+
+// ///////////////////////////////////////////////////////
+// COUNT AND TOTAL THE CLAIMS OF ONE POLICY, BY STATUS //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimsTotalsByPolicy(ctx contractapi.TransactionContextInterface, policyID string) (*ClaimStatistics, error) {
+	if err := assertPortfolioReader(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return nil, err
+	}
+
+	stats, err := aggregateClaims(ctx, []string{policyID})
+	if err != nil {
+		return nil, err
+	}
+	stats.PolicyID = policyID
+
+	return stats, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// ONLY INSURERS AND AUDITORS OF THE REGULATOR SEE PORTFOLIO AGGREGATES //
+// ////////////////////////////////////////////////////////////////////////
+func assertPortfolioReader(ctx contractapi.TransactionContextInterface) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer") == nil {
+		return nil
+	}
+
+	return assertRegulator(ctx)
+}
+
+// //////////////////////////////////////////////////////////////////////
+// TOTAL THE CLAIMS UNDER A PARTIAL CLAIM KEY, EMPTY FOR EVERY POLICY //
+// //////////////////////////////////////////////////////////////////////
+func aggregateClaims(ctx contractapi.TransactionContextInterface, keyAttributes []string) (*ClaimStatistics, error) {
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", keyAttributes)
+	if err != nil {
+		return nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	stats := &ClaimStatistics{ByStatus: map[string]ClaimStatusTotals{}}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		var claim Claim
+		if err := json.Unmarshal(result.Value, &claim); err != nil {
+			return nil, NewLedgerError("unmarshal claim", err)
+		}
+
+		// pre-authorizations share the claim keys but are not claims themselves
+		if isPreAuthStatus(claim.Status) {
+			continue
+		}
+
+		totals := stats.ByStatus[claim.Status]
+		totals.Count++
+		if totals.ClaimedAmount, err = addAmounts("claimedAmount", totals.ClaimedAmount, claim.ClaimAmount); err != nil {
+			return nil, err
+		}
+		if totals.ApprovedAmount, err = addAmounts("approvedAmount", totals.ApprovedAmount, claim.ApprovedAmount); err != nil {
+			return nil, err
+		}
+		stats.ByStatus[claim.Status] = totals
+
+		stats.TotalClaims++
+		if stats.ClaimedAmount, err = addAmounts("claimedAmount", stats.ClaimedAmount, claim.ClaimAmount); err != nil {
+			return nil, err
+		}
+		if stats.ApprovedAmount, err = addAmounts("approvedAmount", stats.ApprovedAmount, claim.ApprovedAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
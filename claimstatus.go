@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// statuses a claim moves through, from submission to payout
+const (
+	ClaimStatusSubmitted             = "SUBMITTED"
+	ClaimStatusUnderReview           = "UNDER_REVIEW"
+	ClaimStatusDuplicateSuspect      = "DUPLICATE_SUSPECT"       // submitted, but likely repeats an earlier claim
+	ClaimStatusEscalated             = "ESCALATED"               // open beyond the turnaround SLA, flagged to supervisors
+	ClaimStatusPendingSecondApproval = "PENDING_SECOND_APPROVAL" // approved by one adjuster, waiting for a second to confirm
+	ClaimStatusQueryRaised           = "QUERY_RAISED"            // insurer asked for more documents, waiting on the claimant
+	ClaimStatusApproved              = "APPROVED"
+	ClaimStatusPartiallyApproved     = "PARTIALLY_APPROVED" // approved for less than the claim amount
+	ClaimStatusRejected              = "REJECTED"
+	ClaimStatusAppealPending         = "APPEAL_PENDING" // rejected claim appealed by the policyholder
+	ClaimStatusSettled               = "SETTLED"
+	ClaimStatusWithdrawn             = "WITHDRAWN"
+	ClaimStatusReimbursementPending  = "REIMBURSEMENT_PENDING" // approved reimbursement claim awaiting the bank transfer
+	ClaimStatusReimbursed            = "REIMBURSED"
+	ClaimStatusReversed              = "REVERSED" // approval undone because it was made in error
+)
+
+// statuses of a pre-authorization, which is stored like a claim
+const (
+	PreAuthStatusRequested = "PREAUTH_REQUESTED"
+	PreAuthStatusApproved  = "PREAUTH_APPROVED"
+	PreAuthStatusRejected  = "PREAUTH_REJECTED"
+	PreAuthStatusClaimed   = "PREAUTH_CLAIMED" // used by a claim, cannot be used again
+)
+
+// returned, wrapped, when a claim cannot move to the requested status
+var ErrInvalidClaimTransition = errors.New("invalid claim status transition")
+
+// statuses each claim status may move to, final statuses have none
+var claimTransitions = map[string][]string{
+	ClaimStatusSubmitted:             {ClaimStatusUnderReview, ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusRejected, ClaimStatusReimbursementPending, ClaimStatusPendingSecondApproval, ClaimStatusWithdrawn, ClaimStatusEscalated, ClaimStatusQueryRaised},
+	ClaimStatusDuplicateSuspect:      {ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusRejected, ClaimStatusReimbursementPending, ClaimStatusPendingSecondApproval, ClaimStatusWithdrawn, ClaimStatusEscalated, ClaimStatusQueryRaised},
+	ClaimStatusUnderReview:           {ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusRejected, ClaimStatusReimbursementPending, ClaimStatusPendingSecondApproval, ClaimStatusWithdrawn, ClaimStatusEscalated, ClaimStatusQueryRaised},
+	ClaimStatusEscalated:             {ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusRejected, ClaimStatusReimbursementPending, ClaimStatusPendingSecondApproval, ClaimStatusWithdrawn, ClaimStatusQueryRaised},
+	ClaimStatusPendingSecondApproval: {ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusRejected, ClaimStatusReimbursementPending},
+	ClaimStatusQueryRaised:           {ClaimStatusUnderReview, ClaimStatusRejected, ClaimStatusWithdrawn},
+	ClaimStatusApproved:              {ClaimStatusSettled, ClaimStatusReversed},
+	ClaimStatusPartiallyApproved:     {ClaimStatusSettled, ClaimStatusReversed},
+	ClaimStatusRejected:              {ClaimStatusApproved, ClaimStatusReimbursementPending, ClaimStatusAppealPending}, // an upheld dispute overturns the rejection
+	ClaimStatusAppealPending:         {ClaimStatusApproved, ClaimStatusReimbursementPending, ClaimStatusRejected},
+	ClaimStatusReimbursementPending:  {ClaimStatusReimbursed, ClaimStatusReversed},
+	ClaimStatusReimbursed:            {},
+	ClaimStatusReversed:              {},
+	ClaimStatusSettled:               {},
+	ClaimStatusWithdrawn:             {},
+	PreAuthStatusRequested:           {PreAuthStatusApproved, PreAuthStatusRejected},
+	PreAuthStatusApproved:            {PreAuthStatusClaimed},
+	PreAuthStatusClaimed:             {},
+	PreAuthStatusRejected:            {},
+}
+
+// lowercase statuses written before the state machine, by their current name
+var legacyClaimStatuses = map[string]string{
+	"pending":               ClaimStatusSubmitted,
+	"approved":              ClaimStatusApproved,
+	"rejected":              ClaimStatusRejected,
+	"settled":               ClaimStatusSettled,
+	"reimbursement-pending": ClaimStatusReimbursementPending,
+	"reimbursed":            ClaimStatusReimbursed,
+	"preauth":               PreAuthStatusRequested,
+	"approved-preauth":      PreAuthStatusApproved,
+	"preauth-claimed":       PreAuthStatusClaimed,
+}
+
+// //////////////////////////////////////////////////////////
+// CHECK THAT A CLAIM MAY MOVE FROM ITS STATUS TO ANOTHER //
+// //////////////////////////////////////////////////////////
+func validateClaimTransition(claim *Claim, status string) error {
+	for _, allowed := range claimTransitions[claim.Status] {
+		if allowed == status {
+			return nil
+		}
+	}
+
+	return &ContractError{
+		Code:    ErrCodeInvalidState,
+		Message: fmt.Sprintf("cannot move claim %s from %s to %s", claim.ClaimID, claim.Status, status),
+		Details: map[string]string{"from": claim.Status, "to": status},
+		cause:   ErrInvalidClaimTransition,
+	}
+}
+
+// ////////////////////////////////////////////////////
+// MOVE A CLAIM TO A NEW STATUS, IF THAT IS ALLOWED //
+// ////////////////////////////////////////////////////
+func transitionClaim(claim *Claim, status string) error {
+	if err := validateClaimTransition(claim, status); err != nil {
+		return err
+	}
+
+	claim.Status = status
+	return nil
+}
+
+// claim types, each taking its own path through the statuses above
+const (
+	ClaimTypeCashless      = "CASHLESS"      // paid to a network hospital under a pre-authorization
+	ClaimTypeReimbursement = "REIMBURSEMENT" // paid to the policyholder after they paid the hospital
+)
+
+// claim types written before the constants above, read as their current names
+var legacyClaimTypes = map[string]string{
+	"cashless":      ClaimTypeCashless,
+	"reimbursement": ClaimTypeReimbursement,
+}
+
+// //////////////////////////////////////////////////////
+// CHECK WHETHER A CLAIM IS STILL AWAITING A DECISION //
+// //////////////////////////////////////////////////////
+func isOpenClaimStatus(status string) bool {
+	switch status {
+	case ClaimStatusSubmitted, ClaimStatusUnderReview, ClaimStatusDuplicateSuspect, ClaimStatusEscalated, ClaimStatusPendingSecondApproval, ClaimStatusQueryRaised:
+		return true
+	}
+	return false
+}
+
+// //////////////////////////////////////////////////////////
+// CHECK WHETHER A CLAIM WAS APPROVED, IN FULL OR IN PART //
+// //////////////////////////////////////////////////////////
+func isApprovedClaimStatus(status string) bool {
+	return status == ClaimStatusApproved || status == ClaimStatusPartiallyApproved
+}
+
+// ///////////////////////////////////////////////////////
+// CHECK WHETHER A CLAIM RECORD IS A PRE-AUTHORIZATION //
+// ///////////////////////////////////////////////////////
+func isPreAuthStatus(status string) bool {
+	return strings.HasPrefix(status, "PREAUTH_")
+}
+
+// ////////////////////////////////////////////////////////////////////
+// READ A CLAIM, RENAMING STATUSES WRITTEN BEFORE THE STATE MACHINE //
+// ////////////////////////////////////////////////////////////////////
+func (claim *Claim) UnmarshalJSON(data []byte) error {
+	// the alias has the fields but not this method, so decoding it does not recurse
+	type claimAlias Claim
+	var alias claimAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	*claim = Claim(alias)
+	if status, ok := legacyClaimStatuses[claim.Status]; ok {
+		claim.Status = status
+	}
+	if claimType, ok := legacyClaimTypes[claim.ClaimType]; ok {
+		claim.ClaimType = claimType
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR AN INSURER CARRYING PART OF A CO-INSURED POLICY
+type CoInsurer struct {
+	MSPID        string `json:"mspID"`
+	SharePercent int    `json:"sharePercent"` // share of every claim, all shares add up to 100
+	MaxLiability int    `json:"maxLiability"` // most the insurer pays towards a single claim, 0 for no cap
+}
+
+// STRUCTURE FOR THE PART OF AN APPROVED CLAIM PAID BY ONE CO-INSURER
+type CoInsuranceShare struct {
+	MSPID  string `json:"mspID"`
+	Amount int    `json:"amount"`
+}
+
+// /////////////////////////////////////////////
+// PARSE A JSON-ENCODED ARRAY OF CO-INSURERS //
+// /////////////////////////////////////////////
+func parseCoInsurers(coInsurersJSON string) ([]CoInsurer, error) {
+	coInsurers := []CoInsurer{}
+	if strings.TrimSpace(coInsurersJSON) == "" {
+		return coInsurers, nil
+	}
+
+	if err := json.Unmarshal([]byte(coInsurersJSON), &coInsurers); err != nil {
+		return nil, NewValidationError("coInsurers", fmt.Sprintf("invalid co-insurers, expected a JSON array of {mspID, sharePercent, maxLiability} objects: %v", err))
+	}
+
+	for i := range coInsurers {
+		coInsurers[i].MSPID = strings.TrimSpace(coInsurers[i].MSPID)
+	}
+
+	if err := validateCoInsurers(coInsurers); err != nil {
+		return nil, err
+	}
+
+	return coInsurers, nil
+}
+
+// ////////////////////////////////////////////////////////////
+// CHECK THAT THE CO-INSURERS ARE UNIQUE AND SHARE ALL RISK //
+// ////////////////////////////////////////////////////////////
+func validateCoInsurers(coInsurers []CoInsurer) error {
+	// a policy without co-insurers is carried by the insurer organisation alone
+	if len(coInsurers) == 0 {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	total := 0
+	for _, coInsurer := range coInsurers {
+		if coInsurer.MSPID == "" {
+			return NewValidationError("coInsurers", "co-insurer MSP ID must not be empty")
+		}
+		if seen[coInsurer.MSPID] {
+			return NewValidationError("coInsurers", fmt.Sprintf("co-insurer %s is listed more than once", coInsurer.MSPID))
+		}
+		if coInsurer.SharePercent <= 0 || coInsurer.SharePercent > 100 {
+			return NewValidationError("coInsurers", fmt.Sprintf("invalid share %d%% for co-insurer %s: must be between 1 and 100", coInsurer.SharePercent, coInsurer.MSPID))
+		}
+		if coInsurer.MaxLiability < 0 {
+			return NewValidationError("coInsurers", fmt.Sprintf("invalid maximum liability %d for co-insurer %s: must not be negative", coInsurer.MaxLiability, coInsurer.MSPID))
+		}
+		seen[coInsurer.MSPID] = true
+		total += coInsurer.SharePercent
+	}
+
+	if total != 100 {
+		return NewValidationError("coInsurers", fmt.Sprintf("co-insurer shares add up to %d%%, they must add up to 100%%", total))
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////////
+// SPLIT A CLAIM AMOUNT BETWEEN THE CO-INSURERS OF A POLICY //
+// ////////////////////////////////////////////////////////////
+func splitClaimAmount(policy *Policy, amount int) ([]CoInsuranceShare, error) {
+	shares := []CoInsuranceShare{}
+	allocated := 0
+	for _, coInsurer := range policy.CoInsurers {
+		share := amount * coInsurer.SharePercent / 100
+		shares = append(shares, CoInsuranceShare{MSPID: coInsurer.MSPID, Amount: share})
+		allocated += share
+	}
+
+	// the lead co-insurer, listed first, absorbs the rounding remainder
+	if len(shares) > 0 {
+		shares[0].Amount += amount - allocated
+	}
+
+	for i, coInsurer := range policy.CoInsurers {
+		if coInsurer.MaxLiability > 0 && shares[i].Amount > coInsurer.MaxLiability {
+			return nil, NewStateError(fmt.Sprintf("share of %d for co-insurer %s exceeds its maximum liability of %d", shares[i].Amount, coInsurer.MSPID, coInsurer.MaxLiability))
+		}
+	}
+
+	return shares, nil
+}
+
+// ///////////////////////////////////////////////////////////
+// ENSURE THE CLIENT BELONGS TO A CO-INSURER OF THE POLICY //
+// ///////////////////////////////////////////////////////////
+func assertCurrentMSPIsCoInsurer(ctx contractapi.TransactionContextInterface, policy *Policy) error {
+	mspIDs := []string{}
+	for _, coInsurer := range policy.CoInsurers {
+		mspIDs = append(mspIDs, coInsurer.MSPID)
+	}
+
+	return assertMSP(ctx, mspIDs...)
+}
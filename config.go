@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// world state key holding the chaincode configuration
+const chaincodeConfigKey = "__chaincode_config__"
+
+// STRUCTURE FOR THE CHAINCODE CONFIGURATION SET AT INSTANTIATION
+type ChaincodeConfig struct {
+	DefaultWaitingPeriodDays int      `json:"defaultWaitingPeriodDays"` // used when a policy is created with -1
+	DefaultPreAuthThreshold  int      `json:"defaultPreAuthThreshold"`  // used when a policy is created with -1
+	AllowedInsuranceMSP      string   `json:"allowedInsuranceMSP"`      // MSP of the insurer organisation
+	AllowedPatientMSP        string   `json:"allowedPatientMSP"`        // MSP of the policyholders
+	AllowedHospitalMSP       string   `json:"allowedHospitalMSP"`       // MSP of the network hospitals
+	AllowedRegulatorMSP      string   `json:"allowedRegulatorMSP"`      // MSP whose auditors see network-wide statistics
+	MaxPageSize              int32    `json:"maxPageSize"`              // largest page a paginated query may return
+	PaymentChaincodeName     string   `json:"paymentChaincodeName"`     // chaincode that settles approved claims, empty to settle off-chain
+	PaymentChannel           string   `json:"paymentChannel"`           // channel of the payment chaincode, empty or the current one, as writes on others are discarded
+	MinInsurableAge          int      `json:"minInsurableAge"`          // youngest age at which a policy can start
+	MaxInsurableAge          int      `json:"maxInsurableAge"`          // oldest age covered, for new policies and admissions
+	HighValueClaimThreshold  int      `json:"highValueClaimThreshold"`  // claims above this amount need a witness signature, 0 disables the check
+	SecondApprovalThreshold  int      `json:"secondApprovalThreshold"`  // approvals above this amount need a second adjuster, 0 disables the check
+	AppealWindowDays         int      `json:"appealWindowDays"`         // days after a rejection in which the policyholder may appeal
+	DuplicateAmountTolerance int      `json:"duplicateAmountTolerance"` // percentage by which a likely duplicate claim's amount may differ
+	DuplicateClaimAction     string   `json:"duplicateClaimAction"`     // flag/reject, what happens to a likely duplicate claim
+	IntimationWindowHours    int      `json:"intimationWindowHours"`    // hours from the start of the admission day to notify the insurer
+	ClaimFilingWindowDays    int      `json:"claimFilingWindowDays"`    // days after discharge in which a claim must be filed, 0 disables the check
+	GrievanceResponseDays    int      `json:"grievanceResponseDays"`    // days the insurer has to answer a grievance before it can go to the ombudsman
+	QueryResponseDays        int      `json:"queryResponseDays"`        // days the claimant has to answer an information request on a claim
+	RenewalGraceDays         int      `json:"renewalGraceDays"`         // days after the end date a policy can still be renewed
+	PremiumGraceDays         int      `json:"premiumGraceDays"`         // days after a missed premium's due date before the policy lapses
+	NoClaimBonusPercent      int      `json:"noClaimBonusPercent"`      // bonus on the sum assured earned by each claim-free term
+	MaxNoClaimBonusPercent   int      `json:"maxNoClaimBonusPercent"`   // cap on the accumulated no-claim bonus
+	FraudReviewThreshold     int      `json:"fraudReviewThreshold"`     // risk score from which a claim is routed to manual investigation
+	MaxClaimsPerBatch        int      `json:"maxClaimsPerBatch"`        // most claims a hospital may submit in one SubmitClaimsBatch transaction
+	AutoAssignClaims         bool     `json:"autoAssignClaims"`         // route every submitted claim to an adjuster, see AutoRouteClaim
+	AutoRouteJuniorMax       int      `json:"autoRouteJuniorMax"`       // claims up to this amount go to junior adjusters first, 0 for no junior band
+	AutoRouteSeniorMin       int      `json:"autoRouteSeniorMin"`       // claims from this amount only go to senior adjusters, 0 for no senior band
+	Currency                 string   `json:"currency"`                 // ISO 4217 code of the network currency
+	AmountUnit               string   `json:"amountUnit"`               // minor/whole, the unit of the currency amounts are kept in, see amount.go
+	NonPayableCategories     []string `json:"nonPayableCategories"`     // claim line item categories that are never paid, such as consumables
+	ReimbursementDocuments   []string `json:"reimbursementDocuments"`   // document types every reimbursement claim must include
+
+	// amount one token is worth in the network amount unit, by token type, see RecordTokenPremiumPayment
+	TokenRates map[string]int `json:"tokenRates,omitempty"`
+}
+
+// ///////////////////////////////////////////////////
+// INITIALISE OR PATCH THE CHAINCODE CONFIGURATION //
+// ///////////////////////////////////////////////////
+func (c *HealthInsurance) InitLedger(ctx contractapi.TransactionContextInterface, configJSON string) error {
+	existing, err := ctx.GetStub().GetState(chaincodeConfigKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the deployer initialises freely, later changes need the insurer organisation
+	if existing != nil {
+		if err := assertMSP(ctx, config.AllowedInsuranceMSP); err != nil {
+			return err
+		}
+	}
+
+	// the configuration can also be passed privately, under the "config" transient field
+	if strings.TrimSpace(configJSON) == "" {
+		transientMap, err := ctx.GetStub().GetTransient()
+		if err != nil {
+			return NewLedgerError("get transient data", err)
+		}
+		configJSON = string(transientMap["config"])
+	}
+
+	// merge-patch semantics: only the fields present in the JSON are changed
+	currency, amountUnit := config.Currency, config.AmountUnit
+	if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+		return NewLedgerError("unmarshal chaincode configuration", err)
+	}
+
+	// amounts already on the ledger are in the configured currency and unit
+	if existing != nil && config.Currency != currency {
+		return NewValidationError("currency", fmt.Sprintf("the network currency is %s and cannot be changed once initialised", currency))
+	}
+	if existing != nil && config.AmountUnit != amountUnit {
+		return NewValidationError("amountUnit", fmt.Sprintf("the network amount unit is %s and cannot be changed once initialised", amountUnit))
+	}
+
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	if err := checkPaymentChannel(ctx, config); err != nil {
+		return err
+	}
+
+	return putConfig(ctx, config)
+}
+
+// /////////////////////////////////////////////////////////////
+// READ THE CHAINCODE CONFIGURATION, DEFAULTS IF NOT YET SET //
+// /////////////////////////////////////////////////////////////
+func getConfig(ctx contractapi.TransactionContextInterface) (*ChaincodeConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(chaincodeConfigKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+
+	config := defaultConfig()
+	if configJSON == nil {
+		return config, nil
+	}
+
+	// a configuration stored before the amount unit was recorded belongs to a network keeping whole units
+	config.AmountUnit = AmountUnitWhole
+	if err := json.Unmarshal(configJSON, config); err != nil {
+		return nil, NewLedgerError("unmarshal chaincode configuration", err)
+	}
+
+	return config, nil
+}
+
+// ///////////////////////////////////////////////////
+// STORE THE CHAINCODE CONFIGURATION IN THE LEDGER //
+// ///////////////////////////////////////////////////
+func putConfig(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return NewLedgerError("marshal chaincode configuration", err)
+	}
+
+	if err := ctx.GetStub().PutState(chaincodeConfigKey, configJSON); err != nil {
+		return NewLedgerError("store chaincode configuration", err)
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// CONFIGURATION USED UNTIL THE DEPLOYER INITIALISES IT //
+// ////////////////////////////////////////////////////////
+func defaultConfig() *ChaincodeConfig {
+	return &ChaincodeConfig{
+		AllowedInsuranceMSP:      "InsuranceMSP",
+		AllowedPatientMSP:        "PatientMSP",
+		AllowedHospitalMSP:       "HospitalMSP",
+		AllowedRegulatorMSP:      "RegulatorMSP",
+		MaxPageSize:              100,
+		MinInsurableAge:          18,
+		MaxInsurableAge:          80,
+		AppealWindowDays:         30,
+		DuplicateAmountTolerance: 10,
+		DuplicateClaimAction:     "flag",
+		IntimationWindowHours:    48,
+		ClaimFilingWindowDays:    30,
+		GrievanceResponseDays:    15,
+		QueryResponseDays:        7,
+		RenewalGraceDays:         30,
+		PremiumGraceDays:         30,
+		NoClaimBonusPercent:      10,
+		MaxNoClaimBonusPercent:   50,
+		FraudReviewThreshold:     50,
+		MaxClaimsPerBatch:        50,
+		Currency:                 "INR",
+		AmountUnit:               AmountUnitMinor,
+		NonPayableCategories:     []string{"consumables"},
+		ReimbursementDocuments:   []string{"discharge_summary", "final_bill", "payment_receipt"},
+	}
+}
+
+// ////////////////////////////////////////////////
+// CHECK THAT A CONFIGURATION CAN BE PUT TO USE //
+// ////////////////////////////////////////////////
+func validateConfig(config *ChaincodeConfig) error {
+	// an empty allow-list would lock every caller out
+	if strings.TrimSpace(config.AllowedInsuranceMSP) == "" || strings.TrimSpace(config.AllowedPatientMSP) == "" || strings.TrimSpace(config.AllowedHospitalMSP) == "" || strings.TrimSpace(config.AllowedRegulatorMSP) == "" {
+		return NewValidationError("allowedMSP", "allowed MSP IDs must not be empty")
+	}
+
+	if config.DefaultWaitingPeriodDays < 0 || config.DefaultPreAuthThreshold < 0 {
+		return NewValidationError("defaultWaitingPeriodDays", "default waiting period and pre-authorization threshold must not be negative")
+	}
+
+	if config.HighValueClaimThreshold < 0 {
+		return NewValidationError("highValueClaimThreshold", fmt.Sprintf("invalid high-value claim threshold %d: must not be negative", config.HighValueClaimThreshold))
+	}
+
+	if err := checkAmount("secondApprovalThreshold", config.SecondApprovalThreshold); err != nil {
+		return err
+	}
+
+	if config.AppealWindowDays <= 0 {
+		return NewValidationError("appealWindowDays", fmt.Sprintf("invalid appeal window of %d days: must be greater than zero", config.AppealWindowDays))
+	}
+
+	if config.DuplicateAmountTolerance < 0 || config.DuplicateAmountTolerance > 100 {
+		return NewValidationError("duplicateAmountTolerance", fmt.Sprintf("invalid duplicate amount tolerance %d%%: must be between 0 and 100", config.DuplicateAmountTolerance))
+	}
+
+	if config.DuplicateClaimAction != "flag" && config.DuplicateClaimAction != "reject" {
+		return NewValidationError("duplicateClaimAction", fmt.Sprintf("invalid duplicate claim action %q: must be flag or reject", config.DuplicateClaimAction))
+	}
+
+	if config.IntimationWindowHours <= 0 {
+		return NewValidationError("intimationWindowHours", fmt.Sprintf("invalid intimation window of %d hours: must be greater than zero", config.IntimationWindowHours))
+	}
+
+	if config.ClaimFilingWindowDays < 0 {
+		return NewValidationError("claimFilingWindowDays", fmt.Sprintf("invalid claim filing window of %d days: must not be negative", config.ClaimFilingWindowDays))
+	}
+
+	if config.GrievanceResponseDays <= 0 {
+		return NewValidationError("grievanceResponseDays", fmt.Sprintf("invalid grievance response days %d: must be greater than zero", config.GrievanceResponseDays))
+	}
+
+	if config.QueryResponseDays <= 0 {
+		return NewValidationError("queryResponseDays", fmt.Sprintf("invalid query response days %d: must be greater than zero", config.QueryResponseDays))
+	}
+
+	if config.RenewalGraceDays < 0 {
+		return NewValidationError("renewalGraceDays", fmt.Sprintf("invalid renewal grace days %d: must not be negative", config.RenewalGraceDays))
+	}
+
+	if config.PremiumGraceDays < 0 {
+		return NewValidationError("premiumGraceDays", fmt.Sprintf("invalid premium grace days %d: must not be negative", config.PremiumGraceDays))
+	}
+
+	if config.NoClaimBonusPercent < 0 || config.NoClaimBonusPercent > 100 {
+		return NewValidationError("noClaimBonusPercent", fmt.Sprintf("invalid no-claim bonus %d: must be between 0 and 100", config.NoClaimBonusPercent))
+	}
+
+	if config.MaxNoClaimBonusPercent < 0 || config.MaxNoClaimBonusPercent > 100 {
+		return NewValidationError("maxNoClaimBonusPercent", fmt.Sprintf("invalid maximum no-claim bonus %d: must be between 0 and 100", config.MaxNoClaimBonusPercent))
+	}
+
+	if config.MaxClaimsPerBatch <= 0 {
+		return NewValidationError("maxClaimsPerBatch", fmt.Sprintf("invalid maximum claims per batch %d: must be greater than zero", config.MaxClaimsPerBatch))
+	}
+
+	if err := checkAmount("autoRouteJuniorMax", config.AutoRouteJuniorMax); err != nil {
+		return err
+	}
+	if err := checkAmount("autoRouteSeniorMin", config.AutoRouteSeniorMin); err != nil {
+		return err
+	}
+	if config.AutoRouteJuniorMax > 0 && config.AutoRouteSeniorMin > 0 && config.AutoRouteJuniorMax >= config.AutoRouteSeniorMin {
+		return NewValidationError("autoRouteJuniorMax", fmt.Sprintf("invalid routing thresholds: the junior maximum %d must be below the senior minimum %d", config.AutoRouteJuniorMax, config.AutoRouteSeniorMin))
+	}
+
+	if _, ok := currencyMinorDigits[config.Currency]; !ok {
+		return NewValidationError("currency", fmt.Sprintf("unsupported currency %q", config.Currency))
+	}
+	if config.AmountUnit != AmountUnitMinor && config.AmountUnit != AmountUnitWhole {
+		return NewValidationError("amountUnit", fmt.Sprintf("invalid amount unit %q: must be minor or whole", config.AmountUnit))
+	}
+
+	if config.FraudReviewThreshold < 0 || config.FraudReviewThreshold > 100 {
+		return NewValidationError("fraudReviewThreshold", fmt.Sprintf("invalid fraud review threshold %d: must be between 0 and 100", config.FraudReviewThreshold))
+	}
+
+	for tokenType, rate := range config.TokenRates {
+		if strings.TrimSpace(tokenType) == "" {
+			return NewValidationError("tokenRates", "token types must not be empty")
+		}
+		if rate <= 0 || rate > maxAmount {
+			return NewValidationError("tokenRates", fmt.Sprintf("invalid rate %d for token %s: must be between 1 and %d", rate, tokenType, maxAmount))
+		}
+	}
+
+	for _, category := range config.NonPayableCategories {
+		if !containsFold(lineItemCategories, category) {
+			return NewValidationError("nonPayableCategories", fmt.Sprintf("invalid non-payable category %q: must be one of %s", category, strings.Join(lineItemCategories, ", ")))
+		}
+	}
+
+	if config.MinInsurableAge < 0 || config.MaxInsurableAge < config.MinInsurableAge {
+		return NewValidationError("minInsurableAge", fmt.Sprintf("invalid insurable ages %d to %d: must not be negative and the minimum must not exceed the maximum", config.MinInsurableAge, config.MaxInsurableAge))
+	}
+
+	if config.MaxPageSize <= 0 {
+		return NewValidationError("maxPageSize", fmt.Sprintf("invalid maximum page size %d: must be greater than zero", config.MaxPageSize))
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////
+// ENSURE THE CLIENT BELONGS TO ONE OF THE GIVEN MSPS //
+// //////////////////////////////////////////////////////
+func assertMSP(ctx contractapi.TransactionContextInterface, allowedMSPs ...string) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return NewLedgerError("get client MSP ID", err)
+	}
+
+	for _, allowed := range allowedMSPs {
+		if mspID == allowed {
+			return nil
+		}
+	}
+
+	return NewUnauthorizedError(fmt.Sprintf("unauthorized access: MSP %q is not permitted, requires one of %s", mspID, strings.Join(allowedMSPs, ", ")))
+}
+
+// roles that may decide and settle claims, insurer identities enrolled before claims_adjuster keep theirs
+var claimDecisionRoles = []string{"claims_adjuster", "insurer"}
+
+// //////////////////////////////////////////////////////////////////////////
+// ENSURE THAT THE CLIENT HOLDS AN ALLOWED ROLE IN THE GIVEN ORGANISATION //
+// //////////////////////////////////////////////////////////////////////////
+func requireOrgAndRole(ctx contractapi.TransactionContextInterface, allowedMSP string, allowedRoles ...string) error {
+	// any organisation's CA can issue a role attribute, so a role only counts within the expected MSP
+	if err := assertMSP(ctx, allowedMSP); err != nil {
+		return err
+	}
+
+	return assertRole(ctx, allowedRoles...)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ///////////////////////////////////////////////////////////////////////////
+// MERGE A POLICY CREATED BY MISTAKE INTO THE ONE KEPT FOR THE SAME PERSON //
+// ///////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ConsolidatePolicies(ctx contractapi.TransactionContextInterface, primaryPolicyID string, duplicatePolicyID string, transferClaims bool) error {
+	// duplicates are cleaned up by admins of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return err
+	}
+
+	if primaryPolicyID == duplicatePolicyID {
+		return NewValidationError("duplicatePolicyID", "primary and duplicate policy must be different")
+	}
+
+	primary, err := c.GetPolicy(ctx, primaryPolicyID)
+	if err != nil {
+		return err
+	}
+	duplicate, err := c.GetPolicy(ctx, duplicatePolicyID)
+	if err != nil {
+		return err
+	}
+
+	// both records must be the same person, held by the same identity
+	if !strings.EqualFold(strings.TrimSpace(primary.PersonName), strings.TrimSpace(duplicate.PersonName)) {
+		return NewValidationError("duplicatePolicyID", fmt.Sprintf("policy %s is not held in the name of the person insured by policy %s", duplicatePolicyID, primaryPolicyID))
+	}
+	if primary.OwnerCertID != duplicate.OwnerCertID {
+		return NewValidationError("duplicatePolicyID", fmt.Sprintf("policy %s is not held by the owner of policy %s", duplicatePolicyID, primaryPolicyID))
+	}
+	if primary.Currency != duplicate.Currency {
+		return NewValidationError("duplicatePolicyID", fmt.Sprintf("policy %s is in %q, policy %s in %q", duplicatePolicyID, duplicate.Currency, primaryPolicyID, primary.Currency))
+	}
+
+	// the kept policy must still be able to take the claims, the duplicate must be able to close
+	if primary.Status == PolicyStatusCancelled || primary.Status == PolicyStatusPorted {
+		return NewStateError(fmt.Sprintf("cannot consolidate into policy %s, current status is %q", primaryPolicyID, primary.Status))
+	}
+	if err := validatePolicyTransition(duplicate, PolicyStatusCancelled); err != nil {
+		return err
+	}
+	if duplicate.PrimaryPolicyID != "" || duplicate.SecondaryPolicyID != "" {
+		return NewConflictError(fmt.Sprintf("policy %s is linked to another policy, unlink it before consolidating", duplicatePolicyID))
+	}
+
+	// what was paid on the moved claims counts against the person's single policy,
+	// claims left on the duplicate keep their charges there
+	previousPrimary, previousDuplicate := *primary, *duplicate
+	transferred := []string{}
+	if transferClaims {
+		claims, err := getAllClaimsForPolicy(ctx, duplicatePolicyID)
+		if err != nil {
+			return err
+		}
+
+		for _, claim := range claims {
+			charged := chargedAmount(claim)
+			duplicate.ClaimedTotal -= charged
+			addSubLimitUsage(duplicate, claim.CoverageType, -charged)
+			primary.ClaimedTotal, err = addAmounts("claimedTotal", primary.ClaimedTotal, charged)
+			if err != nil {
+				return err
+			}
+			addSubLimitUsage(primary, claim.CoverageType, charged)
+
+			if err := moveClaim(ctx, claim, primaryPolicyID); err != nil {
+				return err
+			}
+			transferred = append(transferred, claim.ClaimID)
+		}
+
+		// the kept policy must cover what is now charged to it
+		if primary.ClaimedTotal > primary.SumAssured {
+			return NewStateError(fmt.Sprintf("policy %s would have %d claimed against a sum assured of %d", primaryPolicyID, primary.ClaimedTotal, primary.SumAssured))
+		}
+		for _, subLimit := range primary.SubLimits {
+			if subLimit.Limit > 0 && primary.SubLimitUtilized[subLimit.CoverageType] > subLimit.Limit {
+				return NewStateError(fmt.Sprintf("policy %s would have %d used of its %d sub-limit for %q", primaryPolicyID, primary.SubLimitUtilized[subLimit.CoverageType], subLimit.Limit, subLimit.CoverageType))
+			}
+		}
+	}
+	primary.Version++
+
+	// the duplicate is closed rather than removed, so its history stays on the ledger
+	if err := transitionPolicy(duplicate, PolicyStatusCancelled); err != nil {
+		return err
+	}
+	duplicate.ConsolidatedInto = primaryPolicyID
+	duplicate.Version++
+
+	// a transaction cannot read its own writes, so both changes go into one stats update
+	stats := newNetworkStats()
+	stats.trackPolicyChange(&previousPrimary, primary)
+	stats.trackPolicyChange(&previousDuplicate, duplicate)
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return err
+	}
+
+	for _, policy := range []*Policy{primary, duplicate} {
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return NewLedgerError("marshal updated policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return NewLedgerError("store updated policy", err)
+		}
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	if err := logAccessEvent(ctx, primaryPolicyID, fmt.Sprintf("consolidated duplicate policy %s at %s", duplicatePolicyID, now), clientID, "admin"); err != nil {
+		return err
+	}
+
+	// the event names both policies, so it is built here rather than by setChaincodeEvent
+	eventJSON, err := json.Marshal(map[string]interface{}{
+		"primaryPolicyID":   primaryPolicyID,
+		"duplicatePolicyID": duplicatePolicyID,
+		"transferredClaims": transferred,
+		"timestamp":         now,
+		"actorID":           clientID,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("PoliciesConsolidated", eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
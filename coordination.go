@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ///////////////////////////////////////////////////////////////////////////
+// LINK TWO POLICIES OF ONE PERSON SO CLAIMS FALL THROUGH TO THE SECONDARY //
+// ///////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) LinkPolicies(ctx contractapi.TransactionContextInterface, primaryPolicyID string, secondaryPolicyID string) error {
+	// coordination of benefits is set up by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if primaryPolicyID == secondaryPolicyID {
+		return NewValidationError("secondaryPolicyID", "primary and secondary policy must be different")
+	}
+
+	primary, err := c.GetPolicy(ctx, primaryPolicyID)
+	if err != nil {
+		return err
+	}
+	secondary, err := c.GetPolicy(ctx, secondaryPolicyID)
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range []*Policy{primary, secondary} {
+		if policy.Status != PolicyStatusActive {
+			return NewStateError(fmt.Sprintf("cannot link policy %s, current status is %q", policy.PolicyID, policy.Status))
+		}
+		if policy.PrimaryPolicyID != "" || policy.SecondaryPolicyID != "" {
+			return NewConflictError(fmt.Sprintf("policy %s is already linked to another policy", policy.PolicyID))
+		}
+	}
+
+	// both policies must cover the same person
+	if !strings.EqualFold(strings.TrimSpace(primary.PersonName), strings.TrimSpace(secondary.PersonName)) || primary.DateOfBirth != secondary.DateOfBirth {
+		return NewValidationError("secondaryPolicyID", fmt.Sprintf("policy %s does not cover the person insured by policy %s", secondaryPolicyID, primaryPolicyID))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	primary.SecondaryPolicyID = secondaryPolicyID
+	primary.Version++
+	secondary.PrimaryPolicyID = primaryPolicyID
+	secondary.Version++
+
+	for _, policy := range []*Policy{primary, secondary} {
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return NewLedgerError("marshal updated policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return NewLedgerError("store updated policy", err)
+		}
+	}
+
+	if err := logAccessEvent(ctx, primaryPolicyID, fmt.Sprintf("linked to secondary policy %s at %s", secondaryPolicyID, now), clientID, "insurer"); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "PoliciesLinked", primaryPolicyID, "")
+}
+
+// ////////////////////////////////////////////////////////////////
+// CLAIM WHAT THE PRIMARY POLICY DID NOT PAY FROM THE SECONDARY //
+// ////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) coordinateBenefits(ctx contractapi.TransactionContextInterface, primaryPolicy *Policy, claim *Claim) error {
+	// a claim that is itself the secondary part is not split again
+	if primaryPolicy.SecondaryPolicyID == "" || claim.PrimaryClaimID != "" {
+		return nil
+	}
+
+	remainder := claim.GrossAmount - claim.ApprovedAmount
+	if remainder <= 0 {
+		return nil
+	}
+
+	secondary, err := c.GetPolicy(ctx, primaryPolicy.SecondaryPolicyID)
+	if err != nil {
+		return err
+	}
+
+	// a lapsed secondary policy leaves the remainder with the policyholder
+	if secondary.Status != PolicyStatusActive {
+		return nil
+	}
+
+	// the secondary policy applies its own co-pay and sub-limit to what is left
+	insuredAmount, err := subLimitedAmount(secondary, insuredPortion(remainder, secondary.CoPay), claim.CoverageType)
+	if err != nil {
+		// an exhausted sub-limit on the secondary policy leaves nothing to claim from it
+		return nil
+	}
+	if insuredAmount <= 0 {
+		return nil
+	}
+
+	// the secondary part is decided by the secondary policy's terms, like any other claim
+	secondaryClaim := &Claim{
+		ObjectType:      "claim",
+		ClaimID:         claim.ClaimID + "-cob",
+		PolicyID:        secondary.PolicyID,
+		ClaimAmount:     insuredAmount,
+		GrossAmount:     remainder,
+		Currency:        claim.Currency,
+		DiagnosisCodes:  claim.DiagnosisCodes,
+		CoverageType:    claim.CoverageType,
+		HospitalName:    claim.HospitalName,
+		HospitalID:      claim.HospitalID,
+		DateOfAdmission: claim.DateOfAdmission,
+		DateOfDischarge: claim.DateOfDischarge,
+		TreatmentDate:   claim.TreatmentDate,
+		DocumentRefs:    claim.DocumentRefs,
+		Status:          ClaimStatusSubmitted,
+		Timestamp:       claim.Timestamp,
+		SubmittedAt:     claim.DecidedAt,
+		SubmittedBy:     claim.SubmittedBy,
+		ClaimType:       claim.ClaimType,
+		PrimaryClaimID:  claim.ClaimID,
+	}
+
+	// a reimbursement is paid to the account the primary part went to
+	if claim.ClaimType == ClaimTypeReimbursement {
+		payee, err := getPayeeDetails(ctx, claim.ClaimID)
+		if err != nil {
+			return err
+		}
+		if payee != nil {
+			payee.ClaimID = secondaryClaim.ClaimID
+			payee.PolicyID = secondary.PolicyID
+			if err := putPayeeDetails(ctx, payee); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := putClaim(ctx, secondaryClaim); err != nil {
+		return err
+	}
+	if err := indexClaimID(ctx, secondaryClaim.ClaimID, secondaryClaim.PolicyID); err != nil {
+		return err
+	}
+
+	claim.SecondaryClaimID = secondaryClaim.ClaimID
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE PART OF A POLICY'S ANNUAL DEDUCTIBLE USED IN ONE POLICY YEAR
+type DeductibleTracker struct {
+	ObjectType string `json:"docType"`
+	PolicyID   string `json:"policyID"`
+	PolicyYear string `json:"policyYear"` // first day of the policy year, YYYY-MM-DD
+	Deductible int    `json:"deductible"` // annual deductible of the policy
+	Used       int    `json:"used"`       // borne by the policyholder so far this year
+}
+
+// STRUCTURE FOR HOW THE AMOUNT PAID FOR A CLAIM WAS WORKED OUT
+type PayoutBreakdown struct {
+	AssessedAmount    int    `json:"assessedAmount"`       // approved by the insurer, after co-pay, sub-limits and room rent
+	PolicyYear        string `json:"policyYear,omitempty"` // policy year the admission falls in, YYYY-MM-DD
+	DeductibleApplied int    `json:"deductibleApplied"`    // part of the annual deductible the claim used up, before co-pay
+	CoPay             int    `json:"coPay"`                // co-pay percentage of the policy
+	DeductibleShare   int    `json:"deductibleShare"`      // what the deductible took off the assessed amount, after co-pay
+	PayableAmount     int    `json:"payableAmount"`        // paid by the insurer, the assessed amount less the deductible share
+}
+
+// //////////////////////////////////////////////////////////////////////
+// RETRIEVE HOW MUCH OF ITS DEDUCTIBLE A POLICY USED IN A POLICY YEAR //
+// //////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetDeductibleUsage(ctx contractapi.TransactionContextInterface, policyID string, date string) (*DeductibleTracker, error) {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	policyYear, err := policyYearStart(policy, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return getDeductibleTracker(ctx, policy, policyYear)
+}
+
+// //////////////////////////////////////////////////////////////////////////////
+// WORK OUT WHAT THE INSURER PAYS FOR AN ASSESSED CLAIM, AFTER THE DEDUCTIBLE //
+// //////////////////////////////////////////////////////////////////////////////
+func computePayout(ctx contractapi.TransactionContextInterface, policy *Policy, claim *Claim, assessedAmount int) (*PayoutBreakdown, *DeductibleTracker, error) {
+	// policies without a deductible pay the assessed amount as it is
+	if policy.Deductible == 0 {
+		return &PayoutBreakdown{
+			AssessedAmount: assessedAmount,
+			CoPay:          policy.CoPay,
+			PayableAmount:  assessedAmount,
+		}, nil, nil
+	}
+
+	policyYear, err := policyYearStart(policy, claim.DateOfAdmission)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracker, err := getDeductibleTracker(ctx, policy, policyYear)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remaining := policy.Deductible - tracker.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// (assessed - deductible) x (1 - co-pay), the deductible is never more than the claim itself
+	applied := 0
+	if policy.CoPay < 100 {
+		applied = assessedAmount * 100 / (100 - policy.CoPay)
+	}
+	if applied > remaining {
+		applied = remaining
+	}
+
+	share := insuredPortion(applied, policy.CoPay)
+	if share > assessedAmount {
+		share = assessedAmount
+	}
+	tracker.Used += applied
+
+	return &PayoutBreakdown{
+		AssessedAmount:    assessedAmount,
+		PolicyYear:        policyYear,
+		DeductibleApplied: applied,
+		CoPay:             policy.CoPay,
+		DeductibleShare:   share,
+		PayableAmount:     assessedAmount - share,
+	}, tracker, nil
+}
+
+// ////////////////////////////////////////////////////////////////////
+// GIVE BACK THE DEDUCTIBLE A CLAIM USED, WHEN IT IS NO LONGER PAID //
+// ////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) releaseDeductible(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	if claim.PayoutBreakdown == nil || claim.PayoutBreakdown.DeductibleApplied == 0 {
+		return nil
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	tracker, err := getDeductibleTracker(ctx, policy, claim.PayoutBreakdown.PolicyYear)
+	if err != nil {
+		return err
+	}
+
+	tracker.Used -= claim.PayoutBreakdown.DeductibleApplied
+	if tracker.Used < 0 {
+		tracker.Used = 0
+	}
+
+	return putDeductibleTracker(ctx, tracker)
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// FIRST DAY OF THE POLICY YEAR A DATE FALLS IN, COUNTED FROM THE START //
+// ////////////////////////////////////////////////////////////////////////
+func policyYearStart(policy *Policy, date string) (string, error) {
+	start, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return "", err
+	}
+
+	day, err := parsePolicyDate("date", date)
+	if err != nil {
+		return "", err
+	}
+	if day.Before(start) {
+		return "", NewValidationError("date", fmt.Sprintf("%s is before policy %s started on %s", date, policy.PolicyID, policy.StartDate))
+	}
+
+	// the deductible resets on every anniversary of the start date
+	yearStart := start
+	for !day.Before(yearStart.AddDate(1, 0, 0)) {
+		yearStart = yearStart.AddDate(1, 0, 0)
+	}
+
+	return yearStart.Format("2006-01-02"), nil
+}
+
+// /////////////////////////////////////////////////////////////////////
+// READ THE DEDUCTIBLE TRACKER OF A POLICY YEAR, UNUSED IF NOT FOUND //
+// /////////////////////////////////////////////////////////////////////
+func getDeductibleTracker(ctx contractapi.TransactionContextInterface, policy *Policy, policyYear string) (*DeductibleTracker, error) {
+	trackerKey, err := ctx.GetStub().CreateCompositeKey("deductible", []string{policy.PolicyID, policyYear})
+	if err != nil {
+		return nil, NewLedgerError("create deductible key", err)
+	}
+
+	trackerJSON, err := ctx.GetStub().GetState(trackerKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+
+	tracker := DeductibleTracker{
+		ObjectType: "deductible",
+		PolicyID:   policy.PolicyID,
+		PolicyYear: policyYear,
+	}
+	if trackerJSON != nil {
+		if err := json.Unmarshal(trackerJSON, &tracker); err != nil {
+			return nil, NewLedgerError("unmarshal deductible tracker", err)
+		}
+	}
+
+	// the deductible in force is the policy's current one
+	tracker.Deductible = policy.Deductible
+
+	return &tracker, nil
+}
+
+// /////////////////////////////////////////////////
+// STORE A DEDUCTIBLE TRACKER IN THE WORLD STATE //
+// /////////////////////////////////////////////////
+func putDeductibleTracker(ctx contractapi.TransactionContextInterface, tracker *DeductibleTracker) error {
+	trackerKey, err := ctx.GetStub().CreateCompositeKey("deductible", []string{tracker.PolicyID, tracker.PolicyYear})
+	if err != nil {
+		return NewLedgerError("create deductible key", err)
+	}
+
+	trackerJSON, err := json.Marshal(tracker)
+	if err != nil {
+		return NewLedgerError("marshal deductible tracker", err)
+	}
+
+	if err := ctx.GetStub().PutState(trackerKey, trackerJSON); err != nil {
+		return NewLedgerError("store deductible tracker", err)
+	}
+
+	return nil
+}
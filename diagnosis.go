@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// letter, two characters for the category, then up to four for the subcategory
+var icd10CodePattern = regexp.MustCompile(`^[A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+// STRUCTURE FOR AN ENTRY IN THE ICD-10 CODE TABLE
+type DiagnosisCode struct {
+	ObjectType  string `json:"docType"`
+	Code        string `json:"code"` // upper case, e.g. E11.9
+	Description string `json:"description"`
+}
+
+// ///////////////////////////////////////////////////
+// LOAD OR UPDATE ENTRIES IN THE ICD-10 CODE TABLE //
+// ///////////////////////////////////////////////////
+func (c *HealthInsurance) LoadDiagnosisCodes(ctx contractapi.TransactionContextInterface, codesJSON string) (int, error) {
+	// the code table is maintained by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return 0, err
+	}
+
+	var codes []DiagnosisCode
+	if err := json.Unmarshal([]byte(codesJSON), &codes); err != nil {
+		return 0, NewValidationError("codes", fmt.Sprintf("invalid codes, expected a JSON array of {code, description} objects: %v", err))
+	}
+	if len(codes) == 0 {
+		return 0, NewValidationError("codes", "at least one diagnosis code is required")
+	}
+
+	// validate the whole batch before writing any of it
+	for i := range codes {
+		code, err := normalizeDiagnosisCode(codes[i].Code)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(codes[i].Description) == "" {
+			return 0, NewValidationError("description", fmt.Sprintf("diagnosis code %s has no description", code))
+		}
+
+		codes[i].ObjectType = "diagnosisCode"
+		codes[i].Code = code
+		codes[i].Description = strings.TrimSpace(codes[i].Description)
+	}
+
+	// loading a code that is already in the table replaces its description
+	for i := range codes {
+		if err := putDiagnosisCode(ctx, &codes[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(codes), nil
+}
+
+// //////////////////////////////////////////////
+// RETRIEVE AN ENTRY OF THE ICD-10 CODE TABLE //
+// //////////////////////////////////////////////
+func (c *HealthInsurance) GetDiagnosisCode(ctx contractapi.TransactionContextInterface, code string) (*DiagnosisCode, error) {
+	code, err := normalizeDiagnosisCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnosisCode, err := getDiagnosisCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if diagnosisCode == nil {
+		return nil, NewNotFoundError("diagnosis code", code)
+	}
+
+	return diagnosisCode, nil
+}
+
+// /////////////////////////////////////////////////////////////////
+// PARSE THE DIAGNOSIS CODES OF A CLAIM AND CHECK THE CODE TABLE //
+// /////////////////////////////////////////////////////////////////
+func parseDiagnosisCodes(ctx contractapi.TransactionContextInterface, diagnosisCodesJSON string) ([]string, error) {
+	list, err := parseStringList("diagnosisCodes", diagnosisCodesJSON)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, NewValidationError("diagnosisCodes", "at least one diagnosis code is required")
+	}
+
+	codes := []string{}
+	seen := map[string]bool{}
+	for _, entry := range list {
+		code, err := normalizeDiagnosisCode(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		diagnosisCode, err := getDiagnosisCode(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if diagnosisCode == nil {
+			return nil, NewValidationError("diagnosisCodes", fmt.Sprintf("diagnosis code %s is not in the ICD-10 code table", code))
+		}
+
+		codes = append(codes, code)
+	}
+
+	// keep a stable order so equal claims store equal code lists
+	sort.Strings(codes)
+
+	return codes, nil
+}
+
+// //////////////////////////////////////////////////////////////////////
+// CHECK WHETHER A DIAGNOSIS CODE FALLS UNDER A CODE OR CODE CATEGORY //
+// //////////////////////////////////////////////////////////////////////
+func matchesDiagnosisCode(code string, category string) bool {
+	category, err := normalizeDiagnosisCode(category)
+	if err != nil {
+		return false
+	}
+
+	// E11 covers E11 itself and every subcategory such as E11.9
+	return code == category || strings.HasPrefix(code, category+".") || (strings.Contains(category, ".") && strings.HasPrefix(code, category))
+}
+
+// /////////////////////////////////////////////////////////////
+// TRIM AND UPPER-CASE A CODE, CHECKING IT LOOKS LIKE ICD-10 //
+// /////////////////////////////////////////////////////////////
+func normalizeDiagnosisCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !icd10CodePattern.MatchString(code) {
+		return "", NewValidationError("diagnosisCode", fmt.Sprintf("invalid diagnosis code %q: expected an ICD-10 code such as E11.9", code))
+	}
+
+	return code, nil
+}
+
+// ////////////////////////////////////////////////////////
+// READ A DIAGNOSIS CODE, NIL IF IT IS NOT IN THE TABLE //
+// ////////////////////////////////////////////////////////
+func getDiagnosisCode(ctx contractapi.TransactionContextInterface, code string) (*DiagnosisCode, error) {
+	codeKey, err := ctx.GetStub().CreateCompositeKey("diagnosiscode", []string{code})
+	if err != nil {
+		return nil, NewLedgerError("create diagnosis code key", err)
+	}
+
+	codeJSON, err := ctx.GetStub().GetState(codeKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if codeJSON == nil {
+		return nil, nil
+	}
+
+	var diagnosisCode DiagnosisCode
+	if err := json.Unmarshal(codeJSON, &diagnosisCode); err != nil {
+		return nil, NewLedgerError("unmarshal diagnosis code", err)
+	}
+
+	return &diagnosisCode, nil
+}
+
+// /////////////////////////////////////////////
+// STORE A DIAGNOSIS CODE IN THE WORLD STATE //
+// /////////////////////////////////////////////
+func putDiagnosisCode(ctx contractapi.TransactionContextInterface, diagnosisCode *DiagnosisCode) error {
+	codeKey, err := ctx.GetStub().CreateCompositeKey("diagnosiscode", []string{diagnosisCode.Code})
+	if err != nil {
+		return NewLedgerError("create diagnosis code key", err)
+	}
+
+	codeJSON, err := json.Marshal(diagnosisCode)
+	if err != nil {
+		return NewLedgerError("marshal diagnosis code", err)
+	}
+
+	if err := ctx.GetStub().PutState(codeKey, codeJSON); err != nil {
+		return NewLedgerError("store diagnosis code", err)
+	}
+
+	return nil
+}
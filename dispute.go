@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A POLICYHOLDER'S DISPUTE OF A REJECTED CLAIM
+type Dispute struct {
+	ObjectType      string `json:"docType"`
+	DisputeID       string `json:"disputeID"`
+	ClaimID         string `json:"claimID"`
+	PolicyID        string `json:"policyID"`
+	DisputeReason   string `json:"disputeReason"`
+	Status          string `json:"status"` // open/upheld/dismissed
+	ResolutionNotes string `json:"resolutionNotes,omitempty"`
+	Timestamp       string `json:"timestamp"` // RFC3339, when the dispute was raised
+}
+
+// /////////////////////////////////////////////////
+// DISPUTE A REJECTED CLAIM FOR SECONDARY REVIEW //
+// /////////////////////////////////////////////////
+func (c *HealthInsurance) DisputeClaim(ctx contractapi.TransactionContextInterface, claimID string, disputeReason string) error {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	// only the policy owner can challenge a decision on their claim
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+	if policy.OwnerCertID != clientID {
+		return NewUnauthorizedError(fmt.Sprintf("user is not authorised to dispute claims on policy %s", policy.PolicyID))
+	}
+
+	if claim.Status != ClaimStatusRejected {
+		return NewStateError(fmt.Sprintf("only rejected claims can be disputed, claim %s is %q", claimID, claim.Status))
+	}
+
+	// a claim gets a single secondary review
+	disputeIndexKey, err := ctx.GetStub().CreateCompositeKey("claimdispute", []string{claimID})
+	if err != nil {
+		return NewLedgerError("create dispute index key", err)
+	}
+
+	existingDisputeID, err := ctx.GetStub().GetState(disputeIndexKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+	if existingDisputeID != nil {
+		return NewConflictError(fmt.Sprintf("claim %s has already been disputed in dispute %s", claimID, string(existingDisputeID)))
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	dispute := &Dispute{
+		ObjectType:    "dispute",
+		DisputeID:     ctx.GetStub().GetTxID(),
+		ClaimID:       claimID,
+		PolicyID:      claim.PolicyID,
+		DisputeReason: disputeReason,
+		Status:        "open",
+		Timestamp:     txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	if err := putDispute(ctx, dispute); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(disputeIndexKey, []byte(dispute.DisputeID)); err != nil {
+		return NewLedgerError("store dispute index", err)
+	}
+
+	return setChaincodeEvent(ctx, "ClaimDisputed", claim.PolicyID, claimID)
+}
+
+// /////////////////////////////////////
+// UPHOLD OR DISMISS AN OPEN DISPUTE //
+// /////////////////////////////////////
+func (c *HealthInsurance) ResolveDispute(ctx contractapi.TransactionContextInterface, disputeID string, resolution string, notes string) error {
+	// only insurers can review disputed claims
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if resolution != "upheld" && resolution != "dismissed" {
+		return NewValidationError("resolution", fmt.Sprintf("invalid resolution %q: must be upheld or dismissed", resolution))
+	}
+
+	dispute, err := getDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute == nil {
+		return NewNotFoundError("dispute", disputeID)
+	}
+
+	if dispute.Status != "open" {
+		return NewStateError(fmt.Sprintf("dispute %s has already been resolved as %s", disputeID, dispute.Status))
+	}
+
+	// an upheld dispute overturns the rejection
+	if resolution == "upheld" {
+		claim, err := getClaim(ctx, dispute.ClaimID)
+		if err != nil {
+			return err
+		}
+
+		if err := c.overturnRejection(ctx, claim); err != nil {
+			return err
+		}
+	}
+
+	dispute.Status = resolution
+	dispute.ResolutionNotes = notes
+	if err := putDispute(ctx, dispute); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "DisputeResolved", dispute.PolicyID, dispute.ClaimID)
+}
+
+// /////////////////////////////////////////////////////////////
+// APPROVE A REJECTED CLAIM IN FULL AFTER A SECONDARY REVIEW //
+// /////////////////////////////////////////////////////////////
+func (c *HealthInsurance) overturnRejection(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	// a reimbursement claim still waits for the bank transfer, the others count against the sum assured now
+	// an overturned rejection pays the full insured amount
+	claim.ApprovedAmount = claim.ClaimAmount
+	if claim.ClaimType == ClaimTypeReimbursement {
+		if err := transitionClaim(claim, ClaimStatusReimbursementPending); err != nil {
+			return err
+		}
+	} else {
+		if err := c.adjustClaimedTotal(ctx, claim, claim.ClaimAmount); err != nil {
+			return err
+		}
+		if err := transitionClaim(claim, ClaimStatusApproved); err != nil {
+			return err
+		}
+	}
+	claim.RejectionReason = ""
+
+	return putClaim(ctx, claim)
+}
+
+// //////////////////////////////////////////////////
+// CHECK WHETHER A CLAIM IS UNDER AN OPEN DISPUTE //
+// //////////////////////////////////////////////////
+func hasOpenDispute(ctx contractapi.TransactionContextInterface, claimID string) (bool, error) {
+	disputeIndexKey, err := ctx.GetStub().CreateCompositeKey("claimdispute", []string{claimID})
+	if err != nil {
+		return false, NewLedgerError("create dispute index key", err)
+	}
+
+	disputeID, err := ctx.GetStub().GetState(disputeIndexKey)
+	if err != nil {
+		return false, NewLedgerError("read from world state", err)
+	}
+	if disputeID == nil {
+		return false, nil
+	}
+
+	dispute, err := getDispute(ctx, string(disputeID))
+	if err != nil {
+		return false, err
+	}
+
+	return dispute != nil && dispute.Status == "open", nil
+}
+
+// ////////////////////////////////////////////
+// READ A DISPUTE, NIL IF IT DOES NOT EXIST //
+// ////////////////////////////////////////////
+func getDispute(ctx contractapi.TransactionContextInterface, disputeID string) (*Dispute, error) {
+	disputeKey, err := ctx.GetStub().CreateCompositeKey("dispute", []string{disputeID})
+	if err != nil {
+		return nil, NewLedgerError("create dispute key", err)
+	}
+
+	disputeJSON, err := ctx.GetStub().GetState(disputeKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if disputeJSON == nil {
+		return nil, nil
+	}
+
+	var dispute Dispute
+	if err := json.Unmarshal(disputeJSON, &dispute); err != nil {
+		return nil, NewLedgerError("unmarshal dispute", err)
+	}
+
+	return &dispute, nil
+}
+
+// //////////////////////////////////////
+// STORE A DISPUTE IN THE WORLD STATE //
+// //////////////////////////////////////
+func putDispute(ctx contractapi.TransactionContextInterface, dispute *Dispute) error {
+	disputeKey, err := ctx.GetStub().CreateCompositeKey("dispute", []string{dispute.DisputeID})
+	if err != nil {
+		return NewLedgerError("create dispute key", err)
+	}
+
+	disputeJSON, err := json.Marshal(dispute)
+	if err != nil {
+		return NewLedgerError("marshal dispute", err)
+	}
+
+	if err := ctx.GetStub().PutState(disputeKey, disputeJSON); err != nil {
+		return NewLedgerError("store dispute", err)
+	}
+
+	return nil
+}
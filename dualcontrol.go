@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR AN APPROVAL WAITING FOR A SECOND ADJUSTER TO CONFIRM IT
+type ClaimApproval struct {
+	ApproverID       string   `json:"approverID"`     // client ID of the first adjuster
+	ApprovedAt       string   `json:"approvedAt"`     // RFC3339, UTC
+	AssessedAmount   int      `json:"assessedAmount"` // amount the first adjuster approved, before the deductible
+	PayableAmount    int      `json:"payableAmount"`  // what the insurer would pay, compared with the threshold
+	Remarks          string   `json:"remarks,omitempty"`
+	DeductionReasons []string `json:"deductionReasons,omitempty"` // as given by the first adjuster
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// RECORD THE FIRST APPROVAL OF A LARGE CLAIM, WITHOUT CHARGING OR PAYING IT //
+// /////////////////////////////////////////////////////////////////////////////
+func holdForSecondApproval(ctx contractapi.TransactionContextInterface, claim *Claim, assessedAmount int, payable int, remarks string, deductionReasons []string) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	if err := transitionClaim(claim, ClaimStatusPendingSecondApproval); err != nil {
+		return err
+	}
+
+	// the amounts are recomputed when the approval is confirmed, the claim may have moved on by then
+	claim.FirstApproval = &ClaimApproval{
+		ApproverID:       clientID,
+		ApprovedAt:       txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		AssessedAmount:   assessedAmount,
+		PayableAmount:    payable,
+		Remarks:          remarks,
+		DeductionReasons: deductionReasons,
+	}
+	claim.ApprovedAmount = 0
+	claim.PayoutBreakdown = nil
+	claim.RoomRentDeduction = 0
+
+	if err := setClaimEvent(ctx, "ClaimPendingSecondApproval", claim.PolicyID, claim.ClaimID, payable); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// CHECK THAT A DIFFERENT ADJUSTER CONFIRMS THE HELD APPROVAL, AND RECORD IT //
+// /////////////////////////////////////////////////////////////////////////////
+func confirmSecondApproval(ctx contractapi.TransactionContextInterface, claim *Claim, approvedAmount int) error {
+	if claim.FirstApproval == nil {
+		return NewStateError(fmt.Sprintf("claim %s has no first approval to confirm", claim.ClaimID))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// two people must agree on a large payout, one identity cannot approve it twice
+	if clientID == claim.FirstApproval.ApproverID {
+		return NewUnauthorizedError(fmt.Sprintf("claim %s was first approved by the caller, a different adjuster must confirm it", claim.ClaimID))
+	}
+
+	// the second adjuster confirms the decision as made, or rejects the claim
+	if approvedAmount != 0 && approvedAmount != claim.FirstApproval.AssessedAmount {
+		return NewValidationError("approvedAmount", fmt.Sprintf("claim %s was approved for %d, confirm that amount or reject the claim", claim.ClaimID, claim.FirstApproval.AssessedAmount))
+	}
+
+	claim.SecondApproverID = clientID
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ///////////////////////////////////////////////////////////////////////
+// FIND AN EARLIER CLAIM ON THE POLICY THAT THE NEW ONE LIKELY REPEATS //
+// ///////////////////////////////////////////////////////////////////////
+func findDuplicateClaim(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig, claim *Claim) (*Claim, error) {
+	// read the policy's claims directly, the caller may be a hospital without a claim-reading role
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{claim.PolicyID})
+	if err != nil {
+		return nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		var existing Claim
+		if err := json.Unmarshal(result.Value, &existing); err != nil {
+			return nil, NewLedgerError("unmarshal claim", err)
+		}
+
+		// pre-authorizations and withdrawn claims are never paid, so they cannot be repeated
+		if isPreAuthStatus(existing.Status) || existing.Status == ClaimStatusWithdrawn {
+			continue
+		}
+
+		if isDuplicateClaim(config, &existing, claim) {
+			return &existing, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// //////////////////////////////////////////////////////////////////////
+// SAME HOSPITAL, OVERLAPPING STAY AND AN AMOUNT WITHIN THE TOLERANCE //
+// //////////////////////////////////////////////////////////////////////
+func isDuplicateClaim(config *ChaincodeConfig, existing *Claim, claim *Claim) bool {
+	sameHospital := strings.EqualFold(strings.TrimSpace(existing.HospitalName), strings.TrimSpace(claim.HospitalName))
+	if existing.HospitalID != "" && claim.HospitalID != "" {
+		sameHospital = existing.HospitalID == claim.HospitalID
+	}
+	if !sameHospital {
+		return false
+	}
+
+	existingFrom, existingTo, ok := claimStay(existing)
+	if !ok {
+		return false
+	}
+	claimFrom, claimTo, ok := claimStay(claim)
+	if !ok {
+		return false
+	}
+	if existingTo.Before(claimFrom) || claimTo.Before(existingFrom) {
+		return false
+	}
+
+	// compare what was billed, claims filed before the co-pay only recorded the claim amount
+	existingAmount, claimAmount := existing.GrossAmount, claim.GrossAmount
+	if existingAmount == 0 {
+		existingAmount = existing.ClaimAmount
+	}
+	larger, difference := existingAmount, existingAmount-claimAmount
+	if claimAmount > larger {
+		larger = claimAmount
+	}
+	if difference < 0 {
+		difference = -difference
+	}
+
+	return difference*100 <= larger*config.DuplicateAmountTolerance
+}
+
+// ///////////////////////////////////////////////////////////////////////
+// ADMISSION AND DISCHARGE OF A CLAIM, A SAME-DAY STAY IF UNDISCHARGED //
+// ///////////////////////////////////////////////////////////////////////
+func claimStay(claim *Claim) (time.Time, time.Time, bool) {
+	admission, err := parsePolicyDate("dateOfAdmission", claim.DateOfAdmission)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	discharge, err := parsePolicyDate("dateOfDischarge", claim.DateOfDischarge)
+	if err != nil || discharge.Before(admission) {
+		discharge = admission
+	}
+
+	return admission, discharge, true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// STRUCTURE FOR A CATEGORY OF TREATMENT A POLICY COVERS OR EXCLUDES
+type CoverageCategory struct {
+	Name  string   `json:"name"`  // coverage type, matched against a claim's coverageType
+	Codes []string `json:"codes"` // ICD-10 codes or code categories that fall under it
+}
+
+// /////////////////////////////////////////////////////////////////////////
+// READ A CATEGORY, ACCEPTING THE PLAIN NAMES POLICIES WERE WRITTEN WITH //
+// /////////////////////////////////////////////////////////////////////////
+func (category *CoverageCategory) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*category = CoverageCategory{Name: name, Codes: []string{}}
+		return nil
+	}
+
+	// the alias has the fields but not this method, so decoding it does not recurse
+	type categoryAlias CoverageCategory
+	var alias categoryAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	*category = CoverageCategory(alias)
+	if category.Codes == nil {
+		category.Codes = []string{}
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////
+// PARSE A JSON ARRAY OF CATEGORIES, EACH A NAME OR A {name, codes} OBJECT //
+// ///////////////////////////////////////////////////////////////////////////
+func parseCoverageCategories(field string, categoriesJSON string) ([]CoverageCategory, error) {
+	categories := []CoverageCategory{}
+	if strings.TrimSpace(categoriesJSON) == "" {
+		return categories, nil
+	}
+
+	if err := json.Unmarshal([]byte(categoriesJSON), &categories); err != nil {
+		return nil, NewValidationError(field, fmt.Sprintf("invalid %s, expected a JSON array of names or {name, codes} objects: %v", field, err))
+	}
+
+	return categories, nil
+}
+
+// //////////////////////////////////////////////////////////////////
+// TRIM THE NAMES AND NORMALIZE THE CODES OF A LIST OF CATEGORIES //
+// //////////////////////////////////////////////////////////////////
+func normalizeCoverageCategories(field string, categories []CoverageCategory) error {
+	for i := range categories {
+		categories[i].Name = strings.TrimSpace(categories[i].Name)
+		if categories[i].Name == "" {
+			return NewValidationError(field, fmt.Sprintf("%s must have a name", field))
+		}
+
+		if categories[i].Codes == nil {
+			categories[i].Codes = []string{}
+		}
+		for j := range categories[i].Codes {
+			code, err := normalizeDiagnosisCode(categories[i].Codes[j])
+			if err != nil {
+				return err
+			}
+			categories[i].Codes[j] = code
+		}
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// FIND THE CATEGORY WITH THE GIVEN NAME, NIL IF ABSENT //
+// ////////////////////////////////////////////////////////
+func findCoverageCategory(categories []CoverageCategory, name string) *CoverageCategory {
+	for i := range categories {
+		if strings.EqualFold(categories[i].Name, strings.TrimSpace(name)) {
+			return &categories[i]
+		}
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////
+// NAMES OF A LIST OF CATEGORIES, IN ORDER //
+// ///////////////////////////////////////////
+func coverageCategoryNames(categories []CoverageCategory) []string {
+	names := []string{}
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+
+	return names
+}
+
+// //////////////////////////////////////////////////////////////////////////////
+// MATCH A CLAIM AGAINST THE POLICY'S TERMS, FALSE IF NO COVERED CODE MATCHES //
+// //////////////////////////////////////////////////////////////////////////////
+func checkEligibility(policy *Policy, coverageType string, diagnosisCodes []string) (bool, error) {
+	// an exclusion applies by its name or by any of its codes
+	for _, exclusion := range policy.Exclusions {
+		if strings.EqualFold(exclusion.Name, strings.TrimSpace(coverageType)) {
+			return false, NewValidationError("coverageType", fmt.Sprintf("coverage type %q is excluded by policy %s", coverageType, policy.PolicyID))
+		}
+
+		for _, code := range diagnosisCodes {
+			// exclusions written before categories had codes may name a code directly
+			if matchesDiagnosisCode(code, exclusion.Name) {
+				return false, NewValidationError("diagnosisCodes", fmt.Sprintf("diagnosis %s is excluded by policy %s under %s", code, policy.PolicyID, exclusion.Name))
+			}
+			for _, excluded := range exclusion.Codes {
+				if matchesDiagnosisCode(code, excluded) {
+					return false, NewValidationError("diagnosisCodes", fmt.Sprintf("diagnosis %s is excluded by policy %s under %s (%s)", code, policy.PolicyID, exclusion.Name, excluded))
+				}
+			}
+		}
+	}
+
+	if findCoverageCategory(policy.Coverages, coverageType) == nil {
+		return false, NewValidationError("coverageType", fmt.Sprintf("coverage type %q is not covered by policy %s, covered types are: %s", coverageType, policy.PolicyID, strings.Join(coverageCategoryNames(policy.Coverages), ", ")))
+	}
+
+	// policies whose coverages carry no codes are matched by coverage type alone
+	hasCodes := false
+	for _, coverage := range policy.Coverages {
+		if len(coverage.Codes) == 0 {
+			continue
+		}
+		hasCodes = true
+
+		for _, code := range diagnosisCodes {
+			for _, covered := range coverage.Codes {
+				if matchesDiagnosisCode(code, covered) {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return !hasCodes, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// error codes clients can switch on after unmarshalling a failed transaction's message
+const (
+	ErrCodeInvalidInput = "INVALID_INPUT"
+	ErrCodeInvalidState = "INVALID_STATE"
+	ErrCodeConflict     = "CONFLICT"
+	ErrCodeUnauthorized = "UNAUTHORIZED"
+	ErrCodeLedger       = "LEDGER_ERROR"
+
+	ErrCodeOutsidePolicyPeriod = "OUTSIDE_POLICY_PERIOD"
+	ErrCodeClaimLimitExhausted = "CLAIM_LIMIT_EXHAUSTED"
+)
+
+// STRUCTURE FOR AN ERROR RETURNED TO CLIENTS
+type ContractError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+	cause   error             // kept for errors.Is, not sent to clients
+}
+
+// ///////////////////////////////////////////////////////////
+// JSON ENCODED SO CLIENTS CAN UNMARSHAL THE ERROR PAYLOAD //
+// ///////////////////////////////////////////////////////////
+func (e *ContractError) Error() string {
+	errorJSON, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf(`{"code":%q,"message":%q}`, e.Code, e.Message)
+	}
+
+	return string(errorJSON)
+}
+
+// /////////////////////////////////////////////////////
+// UNDERLYING ERROR, IF THE CONTRACT ERROR WRAPS ONE //
+// /////////////////////////////////////////////////////
+func (e *ContractError) Unwrap() error {
+	return e.cause
+}
+
+// /////////////////////////////////////////////
+// AN ARGUMENT SUPPLIED BY THE CLIENT IS BAD //
+// /////////////////////////////////////////////
+func NewValidationError(field string, message string) *ContractError {
+	return &ContractError{
+		Code:    ErrCodeInvalidInput,
+		Message: message,
+		Details: map[string]string{"field": field},
+	}
+}
+
+// //////////////////////////////////////////////////////////////
+// A STUB, IDENTITY OR ENCODING OPERATION FAILED UNEXPECTEDLY //
+// //////////////////////////////////////////////////////////////
+func NewLedgerError(op string, cause error) *ContractError {
+	return &ContractError{
+		Code:    ErrCodeLedger,
+		Message: fmt.Sprintf("failed to %s: %v", op, cause),
+		Details: map[string]string{"operation": op},
+		cause:   cause,
+	}
+}
+
+// /////////////////////////////////////////////////
+// AN ASSET LOOKED UP BY ID IS NOT IN THE LEDGER //
+// /////////////////////////////////////////////////
+func NewNotFoundError(asset string, id string) *ContractError {
+	// e.g. "pre-authorization" becomes PRE_AUTHORIZATION_NOT_FOUND
+	code := strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_").Replace(asset)) + "_NOT_FOUND"
+
+	return &ContractError{
+		Code:    code,
+		Message: fmt.Sprintf("%s %s does not exist", asset, id),
+		Details: map[string]string{"id": id},
+	}
+}
+
+// ///////////////////////////////////////////////////
+// THE CLIENT IS NOT ALLOWED TO PERFORM THE ACTION //
+// ///////////////////////////////////////////////////
+func NewUnauthorizedError(message string) *ContractError {
+	return &ContractError{Code: ErrCodeUnauthorized, Message: message}
+}
+
+// ////////////////////////////////////////////////////////////////
+// THE ASSET IS NOT IN A STATE THAT ALLOWS THE REQUESTED ACTION //
+// ////////////////////////////////////////////////////////////////
+func NewStateError(message string) *ContractError {
+	return &ContractError{Code: ErrCodeInvalidState, Message: message}
+}
+
+// ///////////////////////////////////////////////////////////////
+// THE ACTION CLASHES WITH DATA ALREADY IN THE LEDGER OR BATCH //
+// ///////////////////////////////////////////////////////////////
+func NewConflictError(message string) *ContractError {
+	return &ContractError{Code: ErrCodeConflict, Message: message}
+}
+
+// //////////////////////////////////////////////////
+// A CLAIMED DATE FALLS OUTSIDE THE POLICY'S TERM //
+// //////////////////////////////////////////////////
+func NewPolicyPeriodError(policy *Policy, date string) *ContractError {
+	return &ContractError{
+		Code:    ErrCodeOutsidePolicyPeriod,
+		Message: fmt.Sprintf("admission on %s is outside the period of policy %s, which runs from %s to %s", date, policy.PolicyID, policy.StartDate, policy.EndDate),
+		Details: map[string]string{"policyID": policy.PolicyID, "date": date, "startDate": policy.StartDate, "endDate": policy.EndDate},
+	}
+}
+
+// ////////////////////////////////////////////////////////////////
+// THE POLICY YEAR'S CLAIMS FOR A COVERAGE TYPE ARE ALL USED UP //
+// ////////////////////////////////////////////////////////////////
+func NewClaimLimitError(counter *ClaimCounter, maxClaims int) *ContractError {
+	return &ContractError{
+		Code:    ErrCodeClaimLimitExhausted,
+		Message: fmt.Sprintf("policy %s allows %d %s claims per policy year, the year from %s has used them all", counter.PolicyID, maxClaims, counter.CoverageType, counter.PolicyYear),
+		Details: map[string]string{"policyID": counter.PolicyID, "coverageType": counter.CoverageType, "policyYear": counter.PolicyYear, "maxClaims": fmt.Sprintf("%d", maxClaims)},
+	}
+}
+
+// //////////////////////////////////////////////////////////////////
+// HUMAN READABLE MESSAGE OF AN ERROR, FOR RESULTS THAT LIST THEM //
+// //////////////////////////////////////////////////////////////////
+func errorMessage(err error) string {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		return contractErr.Message
+	}
+
+	return err.Error()
+}
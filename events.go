@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE PAYLOAD OF A POLICY OR CLAIM LIFECYCLE EVENT
+type ChaincodeEvent struct {
+	EventType string `json:"eventType"`
+	PolicyID  string `json:"policyID"`
+	ClaimID   string `json:"claimID,omitempty"`
+	Amount    int    `json:"amount,omitempty"` // amount claimed, approved, rejected or paid, for claim events that move money
+	Timestamp string `json:"timestamp"`
+	ActorID   string `json:"actorID"` // identity of the client that caused the event
+
+	// set on ClaimSubmitted when the claim was routed to an adjuster on submission
+	AdjusterID       string `json:"adjusterID,omitempty"`
+	AdjusterTier     string `json:"adjusterTier,omitempty"`
+	RoutingRationale string `json:"routingRationale,omitempty"`
+}
+
+// //////////////////////////////////////////////////////////////
+// EMIT A LIFECYCLE EVENT FOR OFF-CHAIN LISTENERS TO REACT ON //
+// //////////////////////////////////////////////////////////////
+func setChaincodeEvent(ctx contractapi.TransactionContextInterface, eventType string, policyID string, claimID string) error {
+	return setClaimEvent(ctx, eventType, policyID, claimID, 0)
+}
+
+// /////////////////////////////////////////////////////////////
+// EMIT A CLAIM EVENT CARRYING THE AMOUNT THE EVENT IS ABOUT //
+// /////////////////////////////////////////////////////////////
+func setClaimEvent(ctx contractapi.TransactionContextInterface, eventType string, policyID string, claimID string, amount int) error {
+	return putChaincodeEvent(ctx, &ChaincodeEvent{
+		EventType: eventType,
+		PolicyID:  policyID,
+		ClaimID:   claimID,
+		Amount:    amount,
+	})
+}
+
+// ///////////////////////////////////////////////////////////////
+// EMIT AN EVENT, STAMPED WITH THE TIME AND THE CALLING CLIENT //
+// ///////////////////////////////////////////////////////////////
+func putChaincodeEvent(ctx contractapi.TransactionContextInterface, event *ChaincodeEvent) error {
+	actorID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	event.Timestamp = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+	event.ActorID = actorID
+
+	// a failure here aborts the transaction rather than dropping the event
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return NewLedgerError("marshal "+event.EventType+" event", err)
+	}
+
+	// fabric keeps only one event per transaction, so this must be the only call
+	if err := ctx.GetStub().SetEvent(event.EventType, eventJSON); err != nil {
+		return NewLedgerError("set "+event.EventType+" event", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR NET PROMOTER SCORE FEEDBACK ON A CLAIM
+type ClaimProcessFeedback struct {
+	PolicyID    string `json:"policyID"`
+	ClaimID     string `json:"claimID"`
+	NPSScore    int    `json:"npsScore"` // 0 to 10
+	Category    string `json:"category"` // speed/communication/payout/overall
+	Comments    string `json:"comments"`
+	SubmittedAt string `json:"submittedAt"`
+}
+
+// ////////////////////////////////////////////////////
+// SUBMIT NPS FEEDBACK ON THE PROCESSING OF A CLAIM //
+// ////////////////////////////////////////////////////
+func (c *HealthInsurance) SubmitClaimProcessFeedback(ctx contractapi.TransactionContextInterface, policyID string, claimID string, npsScore int, category string, comments string) error {
+	// only patients can rate the claims process
+	if err := assertRole(ctx, "patient"); err != nil {
+		return err
+	}
+
+	if npsScore < 0 || npsScore > 10 {
+		return NewValidationError("npsScore", fmt.Sprintf("invalid NPS score %d: must be between 0 and 10", npsScore))
+	}
+
+	if category != "speed" && category != "communication" && category != "payout" && category != "overall" {
+		return NewValidationError("category", fmt.Sprintf("invalid feedback category %q: must be speed, communication, payout or overall", category))
+	}
+
+	// retrieve the claim the feedback is about
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+	if claim.PolicyID != policyID {
+		return NewValidationError("claimID", fmt.Sprintf("claim %s does not belong to policy %s", claimID, policyID))
+	}
+
+	// feedback is only meaningful once the claim has been paid out
+	if claim.Status != ClaimStatusSettled {
+		return NewStateError(fmt.Sprintf("feedback can only be submitted after the claim is settled, current status is %q", claim.Status))
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	feedback := ClaimProcessFeedback{
+		PolicyID:    policyID,
+		ClaimID:     claimID,
+		NPSScore:    npsScore,
+		Category:    category,
+		Comments:    comments,
+		SubmittedAt: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	feedbackJSON, err := json.Marshal(feedback)
+	if err != nil {
+		return NewLedgerError("marshal feedback", err)
+	}
+
+	// one feedback entry per claim and category
+	feedbackKey, err := ctx.GetStub().CreateCompositeKey("nps", []string{claimID, category})
+	if err != nil {
+		return NewLedgerError("create feedback key", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData("nps-collection", feedbackKey, feedbackJSON); err != nil {
+		return NewLedgerError("store feedback", err)
+	}
+
+	// free-text comments stay in the private collection and are not part of the event
+	eventJSON, err := json.Marshal(map[string]interface{}{
+		"claimID":  claimID,
+		"npsScore": npsScore,
+		"category": category,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("NPSFeedbackReceived", eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////
+// AVERAGE NPS SCORE FOR FEEDBACK WITHIN A DATE RANGE //
+// //////////////////////////////////////////////////////
+func (c *HealthInsurance) GetAverageNPS(ctx contractapi.TransactionContextInterface, fromDate string, toDate string) (float64, error) {
+	// only insurers and auditors can view aggregated feedback
+	if err := assertRole(ctx, "insurer", "auditor"); err != nil {
+		return 0, err
+	}
+
+	from, err := time.Parse("2006-01-02", fromDate)
+	if err != nil {
+		return 0, NewValidationError("fromDate", fmt.Sprintf("invalid fromDate %q, expected YYYY-MM-DD: %v", fromDate, err))
+	}
+
+	to, err := time.Parse("2006-01-02", toDate)
+	if err != nil {
+		return 0, NewValidationError("toDate", fmt.Sprintf("invalid toDate %q, expected YYYY-MM-DD: %v", toDate, err))
+	}
+
+	if to.Before(from) {
+		return 0, NewValidationError("toDate", "toDate must not be before fromDate")
+	}
+
+	// include feedback submitted at any time on the final day
+	to = to.AddDate(0, 0, 1)
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey("nps-collection", "nps", []string{})
+	if err != nil {
+		return 0, NewLedgerError("read feedback from private data collection", err)
+	}
+	defer iterator.Close()
+
+	total, count := 0, 0
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return 0, NewLedgerError("iterate feedback", err)
+		}
+
+		var feedback ClaimProcessFeedback
+		if err := json.Unmarshal(result.Value, &feedback); err != nil {
+			return 0, NewLedgerError("unmarshal feedback", err)
+		}
+
+		submittedAt, err := time.Parse(time.RFC3339, feedback.SubmittedAt)
+		if err != nil {
+			return 0, NewLedgerError("parse feedback timestamp", err)
+		}
+
+		if submittedAt.Before(from) || !submittedAt.Before(to) {
+			continue
+		}
+
+		total += feedback.NPSScore
+		count++
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	return float64(total) / float64(count), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// weight of each risk signal, the score is their sum capped at 100
+const (
+	riskWeightFrequentClaims  = 25 // several claims on the policy within a year
+	riskWeightEarlyClaim      = 25 // admission soon after the policy started
+	riskWeightNearSumAssured  = 25 // amount close to what is left of the sum assured
+	riskWeightUnknownHospital = 20 // hospital outside the approved network
+	riskWeightBlacklisted     = 30 // hospital on the blacklist, for a stay before or after the blacklist applied
+)
+
+// ///////////////////////////////////////////////////////////////////////
+// SCORE A NEW CLAIM FROM 0 TO 100 USING SIGNALS ALREADY ON THE LEDGER //
+// ///////////////////////////////////////////////////////////////////////
+func scoreClaimRisk(ctx contractapi.TransactionContextInterface, policy *Policy, claim *Claim, now time.Time) (int, []string, error) {
+	score := 0
+	signals := []string{}
+
+	// read the policy's claims directly, the caller may be a hospital without a claim-reading role
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{policy.PolicyID})
+	if err != nil {
+		return 0, nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	yearAgo := now.AddDate(-1, 0, 0)
+	recentClaims := 0
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return 0, nil, NewLedgerError("iterate claims", err)
+		}
+
+		var existing Claim
+		if err := json.Unmarshal(result.Value, &existing); err != nil {
+			return 0, nil, NewLedgerError("unmarshal claim", err)
+		}
+
+		if isPreAuthStatus(existing.Status) || existing.Status == ClaimStatusWithdrawn {
+			continue
+		}
+		if submittedAt, ok := claimSubmittedAt(&existing); ok && submittedAt.After(yearAgo) {
+			recentClaims++
+		}
+	}
+	if recentClaims >= 2 {
+		score += riskWeightFrequentClaims
+		signals = append(signals, fmt.Sprintf("%d other claims on the policy in the last year", recentClaims))
+	}
+
+	start, startErr := parsePolicyDate("start date", policy.StartDate)
+	admission, admissionErr := parsePolicyDate("dateOfAdmission", claim.DateOfAdmission)
+	if startErr == nil && admissionErr == nil && admission.Before(start.AddDate(0, 0, 60)) {
+		score += riskWeightEarlyClaim
+		signals = append(signals, fmt.Sprintf("admission within 60 days of the policy start on %s", policy.StartDate))
+	}
+
+	remaining := policy.SumAssured - policy.ClaimedTotal
+	if remaining > 0 && claim.ClaimAmount*10 >= remaining*9 {
+		score += riskWeightNearSumAssured
+		signals = append(signals, fmt.Sprintf("claim amount %d is at least 90%% of the remaining sum assured %d", claim.ClaimAmount, remaining))
+	}
+
+	// an insurer override lets claims through for hospitals outside the network
+	if claim.HospitalID == "" {
+		score += riskWeightUnknownHospital
+		signals = append(signals, fmt.Sprintf("hospital %q is not in the approved network", claim.HospitalName))
+	}
+
+	// stays during the blacklist are refused before scoring, the rest are still suspect
+	blacklistEntry, err := findBlacklistEntry(ctx, claim.HospitalID, claim.HospitalName)
+	if err != nil {
+		return 0, nil, err
+	}
+	if blacklistEntry != nil {
+		score += riskWeightBlacklisted
+		signals = append(signals, fmt.Sprintf("hospital %q is blacklisted from %s: %s", blacklistEntry.HospitalName, blacklistEntry.EffectiveFrom, blacklistEntry.Reason))
+	}
+
+	if score > 100 {
+		score = 100
+	}
+
+	return score, signals, nil
+}
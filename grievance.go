@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// statuses of a grievance
+const (
+	GrievanceStatusOpen      = "OPEN"                   // raised, waiting for the insurer's response
+	GrievanceStatusResponded = "RESPONDED"              // answered by the insurer
+	GrievanceStatusEscalated = "ESCALATED_TO_OMBUDSMAN" // taken outside the insurer, final on the ledger
+)
+
+// STRUCTURE FOR A POLICYHOLDER'S COMPLAINT ABOUT A CLAIM OR THE SERVICE
+type Grievance struct {
+	ObjectType       string `json:"docType"`
+	GrievanceID      string `json:"grievanceID"`
+	PolicyID         string `json:"policyID"`
+	ClaimID          string `json:"claimID,omitempty"` // empty for a complaint about the service
+	Description      string `json:"description"`
+	Status           string `json:"status"`
+	RaisedBy         string `json:"raisedBy"` // client ID of the policyholder
+	RaisedAt         string `json:"raisedAt"` // RFC3339, UTC
+	Response         string `json:"response,omitempty"`
+	RespondedBy      string `json:"respondedBy,omitempty"` // client ID of the insurer
+	RespondedAt      string `json:"respondedAt,omitempty"`
+	EscalationReason string `json:"escalationReason,omitempty"`
+	EscalatedAt      string `json:"escalatedAt,omitempty"`
+}
+
+// //////////////////////////////////////////////////////////////////
+// RAISE A COMPLAINT ABOUT A CLAIM, OR ABOUT THE SERVICE AT LARGE //
+// //////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RaiseGrievance(ctx contractapi.TransactionContextInterface, policyID string, claimID string, description string) (string, error) {
+	if strings.TrimSpace(description) == "" {
+		return "", NewValidationError("description", "grievance description must not be empty")
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return "", err
+	}
+
+	// only the policyholder complains about their own policy
+	if err := assertPolicyOwner(ctx, policy); err != nil {
+		return "", err
+	}
+
+	if claimID != "" {
+		claim, err := getClaim(ctx, claimID)
+		if err != nil {
+			return "", err
+		}
+		if claim.PolicyID != policyID {
+			return "", NewValidationError("claimID", fmt.Sprintf("claim %s was not filed under policy %s", claimID, policyID))
+		}
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", NewLedgerError("get transaction timestamp", err)
+	}
+
+	grievance := &Grievance{
+		ObjectType:  "grievance",
+		GrievanceID: ctx.GetStub().GetTxID(),
+		PolicyID:    policyID,
+		ClaimID:     claimID,
+		Description: strings.TrimSpace(description),
+		Status:      GrievanceStatusOpen,
+		RaisedBy:    clientID,
+		RaisedAt:    txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	if err := putGrievance(ctx, grievance); err != nil {
+		return "", err
+	}
+
+	if err := setChaincodeEvent(ctx, "GrievanceRaised", policyID, claimID); err != nil {
+		return "", err
+	}
+
+	return grievance.GrievanceID, nil
+}
+
+// //////////////////////////////////////
+// ANSWER AN OPEN GRIEVANCE ON RECORD //
+// //////////////////////////////////////
+func (c *HealthInsurance) RespondToGrievance(ctx contractapi.TransactionContextInterface, policyID string, grievanceID string, response string) error {
+	// grievances are answered by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(response) == "" {
+		return NewValidationError("response", "response must not be empty")
+	}
+
+	grievance, err := getGrievance(ctx, policyID, grievanceID)
+	if err != nil {
+		return err
+	}
+	if grievance == nil {
+		return NewNotFoundError("grievance", grievanceID)
+	}
+	if grievance.Status != GrievanceStatusOpen {
+		return NewStateError(fmt.Sprintf("grievance %s is %s, only %s grievances can be answered", grievanceID, grievance.Status, GrievanceStatusOpen))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	grievance.Status = GrievanceStatusResponded
+	grievance.Response = strings.TrimSpace(response)
+	grievance.RespondedBy = clientID
+	grievance.RespondedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	if err := putGrievance(ctx, grievance); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "GrievanceResponded", policyID, grievance.ClaimID)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////
+// TAKE A GRIEVANCE TO THE OMBUDSMAN, ONCE ANSWERED OR LEFT UNANSWERED TOO LONG //
+// ////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) EscalateToOmbudsman(ctx contractapi.TransactionContextInterface, policyID string, grievanceID string, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "escalation reason must not be empty")
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	// the policyholder decides whether the insurer's answer is enough
+	if err := assertPolicyOwner(ctx, policy); err != nil {
+		return err
+	}
+
+	grievance, err := getGrievance(ctx, policyID, grievanceID)
+	if err != nil {
+		return err
+	}
+	if grievance == nil {
+		return NewNotFoundError("grievance", grievanceID)
+	}
+
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	switch grievance.Status {
+	case GrievanceStatusResponded:
+		// a policyholder unhappy with the answer may escalate at once
+	case GrievanceStatusOpen:
+		// the insurer gets the response window to answer first
+		raisedAt, err := time.Parse(time.RFC3339, grievance.RaisedAt)
+		if err != nil {
+			return NewLedgerError("parse grievance time", err)
+		}
+		deadline := raisedAt.AddDate(0, 0, config.GrievanceResponseDays)
+		if now.Before(deadline) {
+			return NewStateError(fmt.Sprintf("grievance %s can be escalated once answered or after %s", grievanceID, deadline.Format(time.RFC3339)))
+		}
+	default:
+		return NewStateError(fmt.Sprintf("grievance %s is already %s", grievanceID, grievance.Status))
+	}
+
+	grievance.Status = GrievanceStatusEscalated
+	grievance.EscalationReason = strings.TrimSpace(reason)
+	grievance.EscalatedAt = now.Format(time.RFC3339)
+
+	if err := putGrievance(ctx, grievance); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "GrievanceEscalated", policyID, grievance.ClaimID)
+}
+
+// /////////////////////////////////////
+// RETRIEVE A GRIEVANCE FOR A POLICY //
+// /////////////////////////////////////
+func (c *HealthInsurance) GetGrievance(ctx contractapi.TransactionContextInterface, policyID string, grievanceID string) (*Grievance, error) {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// grievances are seen by the policyholder and the insurer
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if assertMSP(ctx, config.AllowedInsuranceMSP) != nil {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	grievance, err := getGrievance(ctx, policyID, grievanceID)
+	if err != nil {
+		return nil, err
+	}
+	if grievance == nil {
+		return nil, NewNotFoundError("grievance", grievanceID)
+	}
+
+	return grievance, nil
+}
+
+// //////////////////////////////////////////////
+// READ A GRIEVANCE, NIL IF IT DOES NOT EXIST //
+// //////////////////////////////////////////////
+func getGrievance(ctx contractapi.TransactionContextInterface, policyID string, grievanceID string) (*Grievance, error) {
+	grievanceKey, err := ctx.GetStub().CreateCompositeKey("grievance", []string{policyID, grievanceID})
+	if err != nil {
+		return nil, NewLedgerError("create grievance key", err)
+	}
+
+	grievanceJSON, err := ctx.GetStub().GetState(grievanceKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if grievanceJSON == nil {
+		return nil, nil
+	}
+
+	var grievance Grievance
+	if err := json.Unmarshal(grievanceJSON, &grievance); err != nil {
+		return nil, NewLedgerError("unmarshal grievance", err)
+	}
+
+	return &grievance, nil
+}
+
+// ////////////////////////////////////////
+// STORE A GRIEVANCE IN THE WORLD STATE //
+// ////////////////////////////////////////
+func putGrievance(ctx contractapi.TransactionContextInterface, grievance *Grievance) error {
+	grievanceKey, err := ctx.GetStub().CreateCompositeKey("grievance", []string{grievance.PolicyID, grievance.GrievanceID})
+	if err != nil {
+		return NewLedgerError("create grievance key", err)
+	}
+
+	grievanceJSON, err := json.Marshal(grievance)
+	if err != nil {
+		return NewLedgerError("marshal grievance", err)
+	}
+
+	if err := ctx.GetStub().PutState(grievanceKey, grievanceJSON); err != nil {
+		return NewLedgerError("store grievance", err)
+	}
+
+	return nil
+}
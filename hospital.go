@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A HOSPITAL IN THE INSURER'S APPROVED NETWORK
+type Hospital struct {
+	ObjectType   string `json:"docType"`
+	HospitalID   string `json:"hospitalID"`
+	HospitalName string `json:"hospitalName"`
+	Location     string `json:"location"`
+	Active       bool   `json:"active"` // false once removed from the network
+}
+
+// //////////////////////////////////////////
+// ADD A HOSPITAL TO THE APPROVED NETWORK //
+// //////////////////////////////////////////
+func (c *HealthInsurance) AddApprovedHospital(ctx contractapi.TransactionContextInterface, hospitalID string, hospitalName string, location string) error {
+	// the hospital network is managed by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	// only insurers maintain the hospital network
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if hospitalID == "" || strings.TrimSpace(hospitalName) == "" {
+		return NewValidationError("hospitalID", "hospital ID and name must not be empty")
+	}
+
+	existing, err := getHospital(ctx, hospitalID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Active {
+		return NewConflictError(fmt.Sprintf("hospital %s is already approved", hospitalID))
+	}
+
+	// re-adding a removed hospital reactivates it with the new details
+	return putHospital(ctx, &Hospital{
+		ObjectType:   "hospital",
+		HospitalID:   hospitalID,
+		HospitalName: hospitalName,
+		Location:     location,
+		Active:       true,
+	})
+}
+
+// ///////////////////////////////////////////////
+// REMOVE A HOSPITAL FROM THE APPROVED NETWORK //
+// ///////////////////////////////////////////////
+func (c *HealthInsurance) RemoveApprovedHospital(ctx contractapi.TransactionContextInterface, hospitalID string) error {
+	// only insurers maintain the hospital network
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	hospital, err := getHospital(ctx, hospitalID)
+	if err != nil {
+		return err
+	}
+	if hospital == nil || !hospital.Active {
+		return NewNotFoundError("hospital", hospitalID)
+	}
+
+	// keep the record so claims filed while it was approved still resolve
+	hospital.Active = false
+
+	return putHospital(ctx, hospital)
+}
+
+// ///////////////////////////////////////////////////////
+// CHECK WHETHER A HOSPITAL IS IN THE APPROVED NETWORK //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) IsApprovedHospital(ctx contractapi.TransactionContextInterface, hospitalName string) (bool, error) {
+	hospital, err := findApprovedHospital(ctx, hospitalName)
+	if err != nil {
+		return false, err
+	}
+
+	return hospital != nil, nil
+}
+
+// ///////////////////////////////////////////////////////////
+// FIND AN APPROVED HOSPITAL BY NAME, NIL IF THERE IS NONE //
+// ///////////////////////////////////////////////////////////
+func findApprovedHospital(ctx contractapi.TransactionContextInterface, hospitalName string) (*Hospital, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("hospital", []string{})
+	if err != nil {
+		return nil, NewLedgerError("read hospitals from world state", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate hospitals", err)
+		}
+
+		var hospital Hospital
+		if err := json.Unmarshal(result.Value, &hospital); err != nil {
+			return nil, NewLedgerError("unmarshal hospital", err)
+		}
+
+		// names are matched case-insensitively
+		if hospital.Active && strings.EqualFold(strings.TrimSpace(hospital.HospitalName), strings.TrimSpace(hospitalName)) {
+			return &hospital, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ///////////////////////////////////////////////////////
+// CHECK FOR AN INSURER OVERRIDE OF THE HOSPITAL CHECK //
+// ///////////////////////////////////////////////////////
+func hasHospitalCheckOverride(ctx contractapi.TransactionContextInterface) (bool, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, NewLedgerError("read transient data", err)
+	}
+
+	if _, ok := transientMap["override-hospital-check"]; !ok {
+		return false, nil
+	}
+
+	// the override is only honoured for insurers
+	return assertRole(ctx, "insurer") == nil, nil
+}
+
+// /////////////////////////////////////////////
+// READ A HOSPITAL, NIL IF IT DOES NOT EXIST //
+// /////////////////////////////////////////////
+func getHospital(ctx contractapi.TransactionContextInterface, hospitalID string) (*Hospital, error) {
+	hospitalKey, err := ctx.GetStub().CreateCompositeKey("hospital", []string{hospitalID})
+	if err != nil {
+		return nil, NewLedgerError("create hospital key", err)
+	}
+
+	hospitalJSON, err := ctx.GetStub().GetState(hospitalKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if hospitalJSON == nil {
+		return nil, nil
+	}
+
+	var hospital Hospital
+	if err := json.Unmarshal(hospitalJSON, &hospital); err != nil {
+		return nil, NewLedgerError("unmarshal hospital", err)
+	}
+
+	return &hospital, nil
+}
+
+// ///////////////////////////////////////
+// STORE A HOSPITAL IN THE WORLD STATE //
+// ///////////////////////////////////////
+func putHospital(ctx contractapi.TransactionContextInterface, hospital *Hospital) error {
+	hospitalKey, err := ctx.GetStub().CreateCompositeKey("hospital", []string{hospital.HospitalID})
+	if err != nil {
+		return NewLedgerError("create hospital key", err)
+	}
+
+	hospitalJSON, err := json.Marshal(hospital)
+	if err != nil {
+		return NewLedgerError("marshal hospital", err)
+	}
+
+	if err := ctx.GetStub().PutState(hospitalKey, hospitalJSON); err != nil {
+		return NewLedgerError("store hospital", err)
+	}
+
+	return nil
+}
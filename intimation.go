@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A NOTICE OF ADMISSION, GIVEN BEFORE THE CLAIM IS FILED
+type Intimation struct {
+	ObjectType           string `json:"docType"`
+	IntimationID         string `json:"intimationID"`
+	PolicyID             string `json:"policyID"`
+	HospitalName         string `json:"hospitalName"`
+	AdmissionDate        string `json:"admissionDate"` // YYYY-MM-DD
+	ProvisionalDiagnosis string `json:"provisionalDiagnosis"`
+	IntimatedAt          string `json:"intimatedAt"` // RFC3339, UTC
+	IntimatedBy          string `json:"intimatedBy"` // client ID of the policyholder or hospital
+	Timely               bool   `json:"timely"`      // given within the configured intimation window
+	ClaimID              string `json:"claimID,omitempty"`
+}
+
+// /////////////////////////////////////////////////////
+// NOTIFY THE INSURER OF AN ADMISSION UNDER A POLICY //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) IntimateHospitalization(ctx contractapi.TransactionContextInterface, policyID string, hospitalName string, admissionDate string, provisionalDiagnosis string) (string, error) {
+	// admissions are notified by policyholders or by hospitals on their behalf
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := assertMSP(ctx, config.AllowedPatientMSP, config.AllowedHospitalMSP); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(hospitalName) == "" || strings.TrimSpace(provisionalDiagnosis) == "" {
+		return "", NewValidationError("hospitalName", "hospital name and provisional diagnosis must not be empty")
+	}
+
+	admittedOn, err := parsePolicyDate("admissionDate", admissionDate)
+	if err != nil {
+		return "", err
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return "", err
+	}
+	if policy.Status != PolicyStatusActive {
+		return "", NewStateError(fmt.Sprintf("policy is not active, current status is %q", policy.Status))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	if admittedOn.After(now) {
+		return "", NewValidationError("admissionDate", fmt.Sprintf("admission date %s is in the future", admissionDate))
+	}
+
+	// the window runs from the start of the admission day
+	deadline := admittedOn.Add(time.Duration(config.IntimationWindowHours) * time.Hour)
+
+	intimation := &Intimation{
+		ObjectType:           "intimation",
+		IntimationID:         ctx.GetStub().GetTxID(),
+		PolicyID:             policyID,
+		HospitalName:         strings.TrimSpace(hospitalName),
+		AdmissionDate:        admittedOn.Format("2006-01-02"),
+		ProvisionalDiagnosis: provisionalDiagnosis,
+		IntimatedAt:          now.Format(time.RFC3339),
+		IntimatedBy:          clientID,
+		Timely:               !now.After(deadline),
+	}
+
+	if err := putIntimation(ctx, intimation); err != nil {
+		return "", err
+	}
+
+	if err := setChaincodeEvent(ctx, "HospitalizationIntimated", policyID, intimation.IntimationID); err != nil {
+		return "", err
+	}
+
+	return intimation.IntimationID, nil
+}
+
+// ///////////////////////////////////////
+// RETRIEVE AN INTIMATION FOR A POLICY //
+// ///////////////////////////////////////
+func (c *HealthInsurance) GetIntimation(ctx contractapi.TransactionContextInterface, policyID string, intimationID string) (*Intimation, error) {
+	intimation, err := getIntimation(ctx, policyID, intimationID)
+	if err != nil {
+		return nil, err
+	}
+	if intimation == nil {
+		return nil, NewNotFoundError("intimation", intimationID)
+	}
+
+	return intimation, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// LINK A CLAIM TO ITS INTIMATION, CHECKING THEY DESCRIBE THE SAME STAY //
+// ////////////////////////////////////////////////////////////////////////
+func useIntimation(ctx contractapi.TransactionContextInterface, claim *Claim, intimationID string) (*Intimation, error) {
+	intimation, err := getIntimation(ctx, claim.PolicyID, intimationID)
+	if err != nil {
+		return nil, err
+	}
+	if intimation == nil {
+		return nil, NewNotFoundError("intimation", intimationID)
+	}
+
+	if intimation.ClaimID != "" {
+		return nil, NewConflictError(fmt.Sprintf("intimation %s is already used by claim %s", intimationID, intimation.ClaimID))
+	}
+	if intimation.AdmissionDate != claim.DateOfAdmission {
+		return nil, NewValidationError("intimationID", fmt.Sprintf("intimation %s is for an admission on %s, not %s", intimationID, intimation.AdmissionDate, claim.DateOfAdmission))
+	}
+
+	// the caller stores the intimation once the claim is accepted
+	intimation.ClaimID = claim.ClaimID
+
+	return intimation, nil
+}
+
+// ////////////////////////////////////////////////
+// READ AN INTIMATION, NIL IF IT DOES NOT EXIST //
+// ////////////////////////////////////////////////
+func getIntimation(ctx contractapi.TransactionContextInterface, policyID string, intimationID string) (*Intimation, error) {
+	intimationKey, err := ctx.GetStub().CreateCompositeKey("intimation", []string{policyID, intimationID})
+	if err != nil {
+		return nil, NewLedgerError("create intimation key", err)
+	}
+
+	intimationJSON, err := ctx.GetStub().GetState(intimationKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if intimationJSON == nil {
+		return nil, nil
+	}
+
+	var intimation Intimation
+	if err := json.Unmarshal(intimationJSON, &intimation); err != nil {
+		return nil, NewLedgerError("unmarshal intimation", err)
+	}
+
+	return &intimation, nil
+}
+
+// //////////////////////////////////////////
+// STORE AN INTIMATION IN THE WORLD STATE //
+// //////////////////////////////////////////
+func putIntimation(ctx contractapi.TransactionContextInterface, intimation *Intimation) error {
+	intimationKey, err := ctx.GetStub().CreateCompositeKey("intimation", []string{intimation.PolicyID, intimation.IntimationID})
+	if err != nil {
+		return NewLedgerError("create intimation key", err)
+	}
+
+	intimationJSON, err := json.Marshal(intimation)
+	if err != nil {
+		return NewLedgerError("marshal intimation", err)
+	}
+
+	if err := ctx.GetStub().PutState(intimationKey, intimationJSON); err != nil {
+		return NewLedgerError("store intimation", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE POLICIES A LAPSE SWEEP MOVED, BY WHERE THEY WENT
+type LapseSweepResult struct {
+	LapsePending []string `json:"lapsePending"` // entered their grace period
+	Lapsed       []string `json:"lapsed"`       // premium grace period ran out
+	Expired      []string `json:"expired"`      // renewal grace window ran out without a renewal
+	Restored     []string `json:"restored"`     // paid or renewed during the grace period, back in force
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////
+// MOVE OVERDUE POLICIES INTO THEIR GRACE PERIOD, AND LAPSE OR EXPIRE THOSE PAST IT //
+// ////////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) LapseOverduePolicies(ctx contractapi.TransactionContextInterface) (*LapseSweepResult, error) {
+	// only insurers run the lapse sweep
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+	today := now.Format("2006-01-02")
+
+	iterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, NewLedgerError("read policies from world state", err)
+	}
+	defer iterator.Close()
+
+	result := &LapseSweepResult{LapsePending: []string{}, Lapsed: []string{}, Expired: []string{}, Restored: []string{}}
+	stats := newNetworkStats()
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate policies", err)
+		}
+
+		// other documents can share the key namespace, only keep policies
+		var policy Policy
+		if err := json.Unmarshal(entry.Value, &policy); err != nil || policy.ObjectType != "policy" {
+			continue
+		}
+
+		if policy.Status != PolicyStatusActive && policy.Status != PolicyStatusLapsePending {
+			continue
+		}
+
+		graceEndsOn, renewalDue, err := lapseGraceEnd(ctx, config, &policy, now)
+		if err != nil {
+			return nil, err
+		}
+
+		previous := policy
+		switch {
+		case graceEndsOn == "" && policy.Status == PolicyStatusLapsePending:
+			// nothing is overdue any more
+			policy.Status = PolicyStatusActive
+			policy.GraceEndsOn = ""
+			result.Restored = append(result.Restored, policy.PolicyID)
+		case graceEndsOn == "":
+			continue
+		case today > graceEndsOn && renewalDue:
+			// the term ended and was not renewed in time
+			policy.Status = PolicyStatusExpired
+			policy.GraceEndsOn = graceEndsOn
+			result.Expired = append(result.Expired, policy.PolicyID)
+		case today > graceEndsOn:
+			policy.Status = PolicyStatusLapsed
+			policy.GraceEndsOn = graceEndsOn
+			result.Lapsed = append(result.Lapsed, policy.PolicyID)
+		case policy.Status == PolicyStatusActive:
+			policy.Status = PolicyStatusLapsePending
+			policy.GraceEndsOn = graceEndsOn
+			result.LapsePending = append(result.LapsePending, policy.PolicyID)
+		default:
+			// still within the grace period it was given
+			continue
+		}
+
+		policy.Version++
+		stats.trackPolicyChange(&previous, &policy)
+
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return nil, NewLedgerError("marshal updated policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return nil, NewLedgerError("store updated policy", err)
+		}
+	}
+
+	changed := len(result.LapsePending) + len(result.Lapsed) + len(result.Expired) + len(result.Restored)
+	if changed == 0 {
+		return result, nil
+	}
+
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return nil, err
+	}
+
+	// fabric keeps one event per transaction, so every moved policy goes into a single event
+	eventJSON, err := json.Marshal(map[string]interface{}{
+		"lapsePending": result.LapsePending,
+		"lapsed":       result.Lapsed,
+		"expired":      result.Expired,
+		"restored":     result.Restored,
+		"timestamp":    now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("PoliciesLapsed", eventJSON); err != nil {
+		return nil, NewLedgerError("set event", err)
+	}
+
+	return result, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////////
+// LAST DAY OF GRACE FOR AN OVERDUE POLICY, AND WHETHER IT IS THE RENEWAL WINDOW //
+// /////////////////////////////////////////////////////////////////////////////////
+func lapseGraceEnd(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig, policy *Policy, now time.Time) (string, bool, error) {
+	// empty when nothing is overdue
+	graceEndsOn := ""
+	renewalDue := false
+
+	// a policy not renewed by its end date gets the renewal grace window
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return "", false, err
+	}
+	if !now.Before(end.AddDate(0, 0, 1)) {
+		graceEndsOn = end.AddDate(0, 0, config.RenewalGraceDays).Format("2006-01-02")
+		renewalDue = true
+	}
+
+	// a missed premium gets the premium grace period from its due date, the oldest one counts
+	overdue, err := getOverduePremiums(ctx, policy.PolicyID, now)
+	if err != nil {
+		return "", false, err
+	}
+	if len(overdue) > 0 {
+		oldest := overdue[0]
+		for _, dueDate := range overdue[1:] {
+			if dueDate < oldest {
+				oldest = dueDate
+			}
+		}
+
+		due, err := parsePolicyDate("due date", oldest)
+		if err != nil {
+			return "", false, err
+		}
+
+		// YYYY-MM-DD dates compare as strings, the earlier grace end wins
+		premiumGraceEnd := due.AddDate(0, 0, config.PremiumGraceDays).Format("2006-01-02")
+		if graceEndsOn == "" || premiumGraceEnd < graceEndsOn {
+			graceEndsOn = premiumGraceEnd
+			renewalDue = false
+		}
+	}
+
+	return graceEndsOn, renewalDue, nil
+}
+
+// //////////////////////////////////////////////////////////////////////////////////////
+// WHETHER A POLICY IN FORCE IS PAST ITS END DATE BUT STILL WITHIN ITS RENEWAL WINDOW //
+// //////////////////////////////////////////////////////////////////////////////////////
+func inRenewalGrace(config *ChaincodeConfig, policy *Policy, now time.Time) (bool, error) {
+	// the lapse sweep looks after these until the window closes
+	if policy.Status != PolicyStatusActive && policy.Status != PolicyStatusLapsePending {
+		return false, nil
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return false, err
+	}
+
+	// the end date is inclusive, the grace window runs from the day after it
+	return now.Before(end.AddDate(0, 0, 1+config.RenewalGraceDays)), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR PERMISSION TO FILE A CLAIM AFTER THE FILING WINDOW
+type LateClaimException struct {
+	ObjectType      string `json:"docType"`
+	PolicyID        string `json:"policyID"`
+	DateOfAdmission string `json:"dateOfAdmission"` // YYYY-MM-DD, the stay the exception is for
+	Reason          string `json:"reason"`
+	GrantedBy       string `json:"grantedBy"` // client ID of the insurer admin
+	GrantedAt       string `json:"grantedAt"` // RFC3339, UTC
+	ClaimID         string `json:"claimID,omitempty"`
+}
+
+// //////////////////////////////////////////////////////////////////////
+// ALLOW A CLAIM FOR A STAY TO BE FILED AFTER THE CLAIM FILING WINDOW //
+// //////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GrantLateClaimException(ctx contractapi.TransactionContextInterface, policyID string, dateOfAdmission string, reason string) error {
+	// exceptions are reserved for administrators of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "exception reason must not be empty")
+	}
+
+	admittedOn, err := parsePolicyDate("dateOfAdmission", dateOfAdmission)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return err
+	}
+
+	existing, err := getLateClaimException(ctx, policyID, admittedOn.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return NewConflictError(fmt.Sprintf("a late claim exception for the admission on %s under policy %s already exists", admittedOn.Format("2006-01-02"), policyID))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	exception := &LateClaimException{
+		ObjectType:      "lateClaimException",
+		PolicyID:        policyID,
+		DateOfAdmission: admittedOn.Format("2006-01-02"),
+		Reason:          reason,
+		GrantedBy:       clientID,
+		GrantedAt:       txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	if err := putLateClaimException(ctx, exception); err != nil {
+		return err
+	}
+
+	// the access log is append-only, so the exception stays on record
+	if err := logAccessEvent(ctx, policyID, fmt.Sprintf("granted late claim exception for admission on %s: %s", exception.DateOfAdmission, reason), clientID, "admin"); err != nil {
+		return err
+	}
+
+	return setChaincodeEvent(ctx, "LateClaimExceptionGranted", policyID, "")
+}
+
+// /////////////////////////////////////////////////////////////////////////////////
+// REFUSE A CLAIM FILED AFTER THE WINDOW, RETURNING THE EXCEPTION IT NOW USES UP //
+// /////////////////////////////////////////////////////////////////////////////////
+func checkClaimFilingWindow(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig, claim *Claim, now time.Time) (*LateClaimException, error) {
+	if config.ClaimFilingWindowDays == 0 {
+		return nil, nil
+	}
+
+	_, dischargedOn, ok := claimStay(claim)
+	if !ok {
+		return nil, nil
+	}
+
+	// the window runs until the end of its last day
+	deadline := dischargedOn.AddDate(0, 0, config.ClaimFilingWindowDays+1)
+	if now.Before(deadline) {
+		return nil, nil
+	}
+
+	exception, err := getLateClaimException(ctx, claim.PolicyID, claim.DateOfAdmission)
+	if err != nil {
+		return nil, err
+	}
+	if exception == nil {
+		return nil, NewValidationError("dateOfDischarge", fmt.Sprintf("claims must be filed within %d days of discharge, the window closed on %s", config.ClaimFilingWindowDays, deadline.AddDate(0, 0, -1).Format("2006-01-02")))
+	}
+	if exception.ClaimID != "" {
+		return nil, NewConflictError(fmt.Sprintf("the late claim exception for the admission on %s is already used by claim %s", claim.DateOfAdmission, exception.ClaimID))
+	}
+
+	// an exception covers a single claim, the caller stores it once the claim is accepted
+	exception.ClaimID = claim.ClaimID
+
+	return exception, nil
+}
+
+// ////////////////////////////////////////////////////////
+// READ A LATE CLAIM EXCEPTION, NIL IF NONE WAS GRANTED //
+// ////////////////////////////////////////////////////////
+func getLateClaimException(ctx contractapi.TransactionContextInterface, policyID string, dateOfAdmission string) (*LateClaimException, error) {
+	exceptionKey, err := ctx.GetStub().CreateCompositeKey("lateclaim", []string{policyID, dateOfAdmission})
+	if err != nil {
+		return nil, NewLedgerError("create late claim exception key", err)
+	}
+
+	exceptionJSON, err := ctx.GetStub().GetState(exceptionKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if exceptionJSON == nil {
+		return nil, nil
+	}
+
+	var exception LateClaimException
+	if err := json.Unmarshal(exceptionJSON, &exception); err != nil {
+		return nil, NewLedgerError("unmarshal late claim exception", err)
+	}
+
+	return &exception, nil
+}
+
+// ///////////////////////////////////////////////////
+// STORE A LATE CLAIM EXCEPTION IN THE WORLD STATE //
+// ///////////////////////////////////////////////////
+func putLateClaimException(ctx contractapi.TransactionContextInterface, exception *LateClaimException) error {
+	exceptionKey, err := ctx.GetStub().CreateCompositeKey("lateclaim", []string{exception.PolicyID, exception.DateOfAdmission})
+	if err != nil {
+		return NewLedgerError("create late claim exception key", err)
+	}
+
+	exceptionJSON, err := json.Marshal(exception)
+	if err != nil {
+		return NewLedgerError("marshal late claim exception", err)
+	}
+
+	if err := ctx.GetStub().PutState(exceptionKey, exceptionJSON); err != nil {
+		return NewLedgerError("store late claim exception", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// categories a line of a hospital bill can fall under
+var lineItemCategories = []string{"room", "pharmacy", "surgery", "diagnostics", "consumables", "other"}
+
+// STRUCTURE FOR ONE LINE OF THE HOSPITAL BILL BEHIND A CLAIM
+type ClaimLineItem struct {
+	Category        string `json:"category"` // one of lineItemCategories
+	Description     string `json:"description"`
+	Amount          int    `json:"amount"`                    // amount billed
+	PayableAmount   int    `json:"payableAmount"`             // part of the amount the policy pays, before co-pay
+	DeductionReason string `json:"deductionReason,omitempty"` // why the payable amount is below the amount billed
+}
+
+// ////////////////////////////////////////////////////////////////////
+// PARSE THE LINE ITEMS OF A CLAIM AND APPLY THE PER-CATEGORY RULES //
+// ////////////////////////////////////////////////////////////////////
+func parseLineItems(config *ChaincodeConfig, lineItemsJSON string, claimAmount int) ([]ClaimLineItem, error) {
+	items := []ClaimLineItem{}
+	if strings.TrimSpace(lineItemsJSON) == "" {
+		return items, nil
+	}
+
+	if err := json.Unmarshal([]byte(lineItemsJSON), &items); err != nil {
+		return nil, NewValidationError("lineItems", fmt.Sprintf("invalid line items, expected a JSON array of {category, description, amount} objects: %v", err))
+	}
+
+	billed := 0
+	for i := range items {
+		category := strings.ToLower(strings.TrimSpace(items[i].Category))
+		if !containsFold(lineItemCategories, category) {
+			return nil, NewValidationError("lineItems", fmt.Sprintf("invalid line item category %q: must be one of %s", items[i].Category, strings.Join(lineItemCategories, ", ")))
+		}
+		if items[i].Amount <= 0 {
+			return nil, NewValidationError("lineItems", fmt.Sprintf("amount of line item %d must be greater than zero", i+1))
+		}
+		if err := checkAmount("lineItems", items[i].Amount); err != nil {
+			return nil, err
+		}
+
+		items[i].Category = category
+		items[i].Description = strings.TrimSpace(items[i].Description)
+		items[i].PayableAmount = items[i].Amount
+		items[i].DeductionReason = ""
+
+		// some categories, typically consumables, are never paid under any policy
+		if containsFold(config.NonPayableCategories, category) {
+			items[i].PayableAmount = 0
+			items[i].DeductionReason = fmt.Sprintf("%s are not payable", category)
+		}
+
+		var err error
+		if billed, err = addAmounts("lineItems", billed, items[i].Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// the lines make up the whole bill, so they must add up to the amount claimed
+	if len(items) > 0 && billed != claimAmount {
+		return nil, NewValidationError("lineItems", fmt.Sprintf("line items add up to %d, not the claimed %d", billed, claimAmount))
+	}
+
+	return items, nil
+}
+
+// ///////////////////////////////////////////////////
+// TOTAL OF THE LINE ITEMS THE POLICY WILL PAY FOR //
+// ///////////////////////////////////////////////////
+func payableLineTotal(items []ClaimLineItem) int {
+	total := 0
+	for _, item := range items {
+		total += item.PayableAmount
+	}
+
+	return total
+}
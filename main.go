@@ -1,364 +1,2154 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
-)
-
-//////////////////////////////////////////////////
-// SMART CONTRACT FOR A HEALTH INSURANCE POLICY //
-//////////////////////////////////////////////////
-
-type HealthInsurance struct {
-	contractapi.Contract
-}
-
-// STRUCTURE FOR A HEALTH INSURANCE POLICY
-type Policy struct {
-	ObjectType   string `json:"docType"`
-	PolicyID     string `json:"policyID"`
-	SumAssured   int    `json:"sumAssured"`
-	PersonName   string `json:"personName"`
-	DateOfBirth  string `json:"dateOfBirth"`
-	Gender       string `json:"gender"`
-	StartDate    string `json:"startDate"` // start date of the policy
-	EndDate      string `json:"endDate"`   // end date of the policy
-	CoPay        int    `json:"coPay"`     // co-pay percentage for the policy
-	Coverages    string `json:"coverages"`
-	Benefits     string `json:"benefits"`
-	Exclusions   string `json:"exclusions"`
-	ClaimedTotal int    `json:"claimedTotal"` // total amount claimed so far
-
-	// sensitive data, such as diseases and treatments
-	MedicalCondition string `json:"medicalConditions,omitempty"`
-}
-
-// STRUCTURE FOR AN INSURANCE CLAIM
-type Claim struct {
-	ClaimID         string `json:"claimID"`
-	PolicyID        string `json:"policyID"`
-	ClaimAmount     int    `json:"claimAmount"`
-	ClaimReason     string `json:"claimReason"`
-	HospitalName    string `json:"hospitalName"`
-	DateOfAdmission string `json:"dateOfAdmission"`
-	DateOfDischarge string `json:"dateOfDischarge"`
-	TreatmentDate   string `json:"treatmentDate"`
-	Documents       string `json:"documents"`
-	Status          string `json:"status"` // pending/approved/rejected
-}
-
-// //////////////////////////////////////
-// CREATE NEW HEALTH INSURANCE POLICY //
-// //////////////////////////////////////
-func (c *HealthInsurance) CreatePolicy(ctx contractapi.TransactionContextInterface, policyID string, sumAssured int, personName string, dateOfBirth string, gender string, startDate string, endDate string, coPay int, coverages string, benefits string, exclusions string, medicalConditions string) error {
-	// non-sensitive data
-	policy := Policy{
-		ObjectType:       "policy",
-		PolicyID:         policyID,
-		SumAssured:       sumAssured,
-		PersonName:       personName,
-		DateOfBirth:      dateOfBirth,
-		Gender:           gender,
-		StartDate:        startDate,
-		EndDate:          endDate,
-		CoPay:            coPay,
-		Coverages:        coverages,
-		Benefits:         benefits,
-		Exclusions:       exclusions,
-		ClaimedTotal:     0,
-		MedicalCondition: medicalConditions,
-	}
-
-	// convert non-sensitive data to json format
-	policyJSON, err := json.Marshal(policy)
-	if err != nil {
-		return fmt.Errorf("failed to marshal policy: %v", err)
-	}
-
-	// store non-sensitive data in the ledger
-	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
-		return fmt.Errorf("failed to store policy: %v", err)
-	}
-
-	// sensitive data
-	sensitiveData := map[string]string{
-		"medicalConditions": medicalConditions,
-	}
-
-	// store sensitive data in the private collection
-	privateDataJSON, err := json.Marshal(sensitiveData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal sensitive data: %v", err)
-	}
-
-	// store sensitive data in the private collection using the policyID as the key
-	if err := ctx.GetStub().PutPrivateData("medical-conditions-collection", policyID, privateDataJSON); err != nil {
-		return fmt.Errorf("failed to store sensitive data: %v", err)
-	}
-
-	return nil
-}
-
-// ///////////////////////////////////////////
-// RETRIEVE POLICY DETAILS USING POLICY-ID //
-// ///////////////////////////////////////////
-func (c *HealthInsurance) GetPolicy(ctx contractapi.TransactionContextInterface, policyID string) (*Policy, error) {
-	policyJSON, err := ctx.GetStub().GetState(policyID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if policyJSON == nil {
-		return nil, fmt.Errorf("policy does not exist")
-	}
-
-	var policy Policy
-	// convert the JSON data to a policy struct
-	if err := json.Unmarshal(policyJSON, &policy); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal policy: %v", err)
-	}
-
-	return &policy, nil
-}
-
-// ///////////////////////////////
-// SUBMIT A CLAIM FOR A POLICY //
-// ///////////////////////////////
-func (c *HealthInsurance) SubmitClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, claimReason string, hospitalName string, dateOfAdmission string, dateOfDischarge string, treatmentDate string, documents string) error {
-	// retrieve the policy details
-	policy, err := c.GetPolicy(ctx, policyID)
-	if err != nil {
-		return err
-	}
-
-	// check if the claim amount exceeds the sum assured
-	if policy.ClaimedTotal+claimAmount > policy.SumAssured {
-		return fmt.Errorf("claim amount exceeds sum assured")
-	}
-
-	// update the claimed total
-	policy.ClaimedTotal += claimAmount
-
-	// get transaction timestamp
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
-	}
-
-	// log the claim details
-	claimDetails := map[string]string{
-		"policyID":        policyID,
-		"claimAmount":     fmt.Sprintf("%d", claimAmount),
-		"claimReason":     claimReason,
-		"hospitalName":    hospitalName,
-		"dateOfAdmission": dateOfAdmission,
-		"dateOfDischarge": dateOfDischarge,
-		"treatmentDate":   treatmentDate,
-		"documents":       documents,
-		"timestamp":       fmt.Sprintf("%d", txTimestamp.Seconds),
-	}
-
-	claimDetailsJSON, err := json.Marshal(claimDetails)
-	if err != nil {
-		return fmt.Errorf("failed to marshal claim details: %v", err)
-	}
-
-	// store the claim details in a private collection
-	if err := ctx.GetStub().PutPrivateData("claims-collection", policyID, claimDetailsJSON); err != nil {
-		return fmt.Errorf("failed to store claim details: %v", err)
-	}
-
-	// convert the updated policy struct to JSON format
-	policyJSON, err := json.Marshal(policy)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated policy: %v", err)
-	}
-
-	// store the updated policy in the ledger
-	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
-		return fmt.Errorf("failed to store updated policy: %v", err)
-	}
-
-	return nil
-}
-
-// //////////////////////////////////
-// UPDATE THE DETAILS OF A POLICY //
-// //////////////////////////////////
-func (c *HealthInsurance) UpdatePolicy(ctx contractapi.TransactionContextInterface, policyID string, sumAssured int, personName string, dateOfBirth string, gender string, startDate string, endDate string, coPay int, coverages string, benefits string, exclusions string) error {
-	// retrieve the policy details
-	policy, err := c.GetPolicy(ctx, policyID)
-	if err != nil {
-		return err
-	}
-
-	// update with the new values
-	policy.SumAssured = sumAssured
-	policy.PersonName = personName
-	policy.DateOfBirth = dateOfBirth
-	policy.Gender = gender
-	policy.StartDate = startDate
-	policy.EndDate = endDate
-	policy.CoPay = coPay
-	policy.Coverages = coverages
-	policy.Benefits = benefits
-	policy.Exclusions = exclusions
-
-	// convert the updated policy struct to JSON format
-	policyJSON, err := json.Marshal(policy)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated policy: %v", err)
-	}
-
-	// store the updated policy in the ledger
-	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
-		return fmt.Errorf("failed to store updated policy: %v", err)
-	}
-
-	return nil
-}
-
-// ////////////////////////////////////////////////////////////////
-// RETRIEVE SENSITIVE MEDICAL DATA, FOR AUTHORISED PARTIES ONLY //
-// ////////////////////////////////////////////////////////////////
-func (c *HealthInsurance) GetMedicalConditions(ctx contractapi.TransactionContextInterface, policyID string) (string, error) {
-	// get the client's identity
-	clientIdentity := ctx.GetClientIdentity()
-
-	// check permissions/role
-	role, found, err := clientIdentity.GetAttributeValue("role")
-	if err != nil {
-		return "", fmt.Errorf("failed to get client role attribute: %v", err)
-	}
-
-	if !found {
-		return "", fmt.Errorf("client role attribute not found")
-	}
-
-	// ENSURE THAT ONLY AUTHORISED USERS CAN ACCESS SENSITIVE DATA
-	// Role-Based Access Control (RBAC)
-	if role != "doctor" && role != "patient" {
-		return "", fmt.Errorf("unauthorized access: only doctors or patients can access medical conditions")
-	}
-
-	clientID, err := clientIdentity.GetID()
-	if err != nil {
-		return "", fmt.Errorf("failed to get client ID: %v", err)
-	}
-
-	// only patient can access their own data
-	if role == "patient" {
-		clientID, err := ctx.GetClientIdentity().GetID()
-		if err != nil {
-			return "", fmt.Errorf("failed to get client ID: %v", err)
-		}
-
-		policy, err := c.GetPolicy(ctx, policyID)
-		if err != nil {
-			return "", err
-		}
-
-		if policy.PersonName != clientID {
-			return "", fmt.Errorf("user is not authorised to access medical data for this policy")
-		}
-		err = logAccessEvent(ctx, policyID, "accessed medical conditions", clientID)
-		if err != nil {
-			return "", fmt.Errorf("failed to log access event: %v", err)
-		}
-	}
-
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
-		return "", fmt.Errorf("failed to get transaction timestamp: %v", err)
-	}
-
-	timestampStr := txTimestamp.AsTime().String()
-
-	logEntry := map[string]string{
-		"userID":        clientID,
-		"role":          role,
-		"policyID":      policyID,
-		"timestamp":     timestampStr,
-		"accessGranted": "true",
-	}
-
-	logEntryJSON, err := json.Marshal(logEntry)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal access log: %v", err)
-	}
-
-	err = ctx.GetStub().PutPrivateData("access-log-collection", policyID, logEntryJSON)
-	if err != nil {
-		return "", fmt.Errorf("failed to store access log: %v", err)
-	}
-
-	// retrieve private data from the private collection
-	privateDataJSON, err := ctx.GetStub().GetPrivateData("medical-conditions-collection", policyID)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to read from private data collection: %v", err)
-	}
-
-	if privateDataJSON == nil {
-		return "", fmt.Errorf("no sensitive data available for the policy")
-	}
-
-	var sensitiveData map[string]string
-	err = json.Unmarshal(privateDataJSON, &sensitiveData)
-	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal private data: %v", err)
-	}
-
-	return sensitiveData["medicalConditions"], nil
-}
-
-// ///////////////////////////////////////////
-// LOG ACCESS EVENTS FOR AUDITING PURPOSES //
-// ///////////////////////////////////////////
-func logAccessEvent(ctx contractapi.TransactionContextInterface, policyID string, action string, userID string) error {
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
-	}
-
-	// Convert the timestamp to seconds
-	timestamp := fmt.Sprintf("%d", txTimestamp.GetSeconds())
-
-	accessLog := map[string]string{
-		"policyID":  policyID,
-		"action":    action,
-		"userID":    userID,
-		"timestamp": timestamp,
-	}
-
-	logJSON, err := json.Marshal(accessLog)
-	if err != nil {
-		return err
-	}
-
-	// store the log in a separate collection for auditing purposes
-	err = ctx.GetStub().PutPrivateData("access-logs", policyID, logJSON)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// /////////////////
-// MAIN FUNCTION //
-// /////////////////
-func main() {
-	// create a new instance of the chaincode
-	chaincode, err := contractapi.NewChaincode(&HealthInsurance{})
-	if err != nil {
-		fmt.Printf("error creating health insurance chaincode: %v\n", err)
-		return
-	}
-
-	// start the chaincode server
-	if err := chaincode.Start(); err != nil {
-		fmt.Printf("error starting health insurance chaincode: %v\n", err)
-	}
-}
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//////////////////////////////////////////////////
+// SMART CONTRACT FOR A HEALTH INSURANCE POLICY //
+//////////////////////////////////////////////////
+
+type HealthInsurance struct {
+	contractapi.Contract
+}
+
+// STRUCTURE FOR A HEALTH INSURANCE POLICY
+type Policy struct {
+	ObjectType  string `json:"docType"`
+	PolicyID    string `json:"policyID"`
+	SumAssured  int    `json:"sumAssured"`         // in the network amount unit, as are all amounts, see amount.go
+	Currency    string `json:"currency,omitempty"` // ISO 4217 code, empty on policies created before it was recorded
+	PersonName  string `json:"personName"`
+	DateOfBirth string `json:"dateOfBirth"`
+	Gender      string `json:"gender"`
+	StartDate   string `json:"startDate"` // start date of the policy, YYYY-MM-DD
+	EndDate     string `json:"endDate"`   // end date of the policy, YYYY-MM-DD, inclusive
+	CoPay       int    `json:"coPay"`     // co-pay percentage for the policy
+	// claims above this amount need an approved pre-authorization, 0 disables the check
+	PreAuthThreshold int `json:"preAuthThreshold"`
+	// days after the start date before admissions can be claimed
+	WaitingPeriodDays int `json:"waitingPeriodDays"`
+	// waiting period for claims related to pre-existing medical conditions
+	PreExistingWaitingDays int                `json:"preExistingWaitingDays"`
+	Coverages              []CoverageCategory `json:"coverages"` // coverage types the policy pays for, with the codes under each
+	Benefits               []string           `json:"benefits"`
+	Exclusions             []CoverageCategory `json:"exclusions"`                  // coverage types and codes the policy never pays for
+	ClaimedTotal           int                `json:"claimedTotal"`                // total amount claimed so far
+	SubLimits              []SubLimit         `json:"subLimits"`                   // caps per coverage type, within the sum assured
+	SubLimitUtilized       map[string]int     `json:"subLimitUtilized"`            // amount claimed so far per sub-limited coverage type
+	RoomRentLimit          int                `json:"roomRentLimit"`               // daily room rent cap as a percentage of the sum assured, 0 for no cap
+	Deductible             int                `json:"deductible"`                  // borne by the policyholder each policy year before the insurer pays
+	CoInsurers             []CoInsurer        `json:"coInsurers"`                  // insurers sharing every claim, empty when the insurer organisation carries it alone
+	PrimaryPolicyID        string             `json:"primaryPolicyID,omitempty"`   // policy that pays first for the same person, see LinkPolicies
+	SecondaryPolicyID      string             `json:"secondaryPolicyID,omitempty"` // policy claimed for what this one leaves unpaid
+	Status                 string             `json:"status"`                      // one of the PolicyStatus constants, see policystatus.go
+	PortedClaimedTotal     int                `json:"portedClaimedTotal"`          // amount claimed under the policy this one was ported from
+	NoClaimBonus           int                `json:"noClaimBonus,omitempty"`      // percentage added to the sum assured for claim-free terms, see RenewPolicy
+	BaseSumAssured         int                `json:"baseSumAssured,omitempty"`    // sum assured before the no-claim bonus, zero until the first renewal
+	CancellationDate       string             `json:"cancellationDate,omitempty"`  // YYYY-MM-DD, last day of cover of a policy cancelled by CancelPolicy
+	GraceEndsOn            string             `json:"graceEndsOn,omitempty"`       // YYYY-MM-DD, last day of grace of a LAPSE_PENDING policy
+	ConsolidatedInto       string             `json:"consolidatedInto,omitempty"`  // policy a duplicate was merged into, see ConsolidatePolicies
+	OwnerCertID            string             `json:"ownerCertID"`                 // client ID of the identity that created the policy
+	Version                int                `json:"version"`                     // incremented on every write, for optimistic locking
+
+	// Deprecated: medical conditions are only kept in the private medical-conditions-collection,
+	// this is set on policies created before that change
+	MedicalCondition string `json:"medicalConditions,omitempty"`
+}
+
+// STRUCTURE FOR AN INSURANCE CLAIM
+type Claim struct {
+	ObjectType           string            `json:"docType"`
+	ClaimID              string            `json:"claimID"`
+	PolicyID             string            `json:"policyID"`
+	ClaimAmount          int               `json:"claimAmount"`                  // insured portion, after the policy's co-pay
+	GrossAmount          int               `json:"grossAmount"`                  // full amount claimed, including the co-pay
+	Currency             string            `json:"currency,omitempty"`           // ISO 4217 code of the amounts, that of the policy
+	DiagnosisCodes       []string          `json:"diagnosisCodes,omitempty"`     // ICD-10 codes, checked against the on-ledger code table
+	ProcedureCodes       []ProcedureCharge `json:"procedureCodes,omitempty"`     // procedures billed, priced against the tariff table
+	OverTariffAmount     int               `json:"overTariffAmount,omitempty"`   // billed above agreed package rates, needs an adjuster's review
+	LineItems            []ClaimLineItem   `json:"lineItems,omitempty"`          // the hospital bill line by line, adding up to the gross amount
+	RoomRentDeduction    int               `json:"roomRentDeduction,omitempty"`  // insured amount deducted for room rent above the policy's limit
+	UnmatchedCoverage    bool              `json:"unmatchedCoverage,omitempty"`  // no diagnosis falls under a covered category, needs an adjuster's review
+	RiskScore            int               `json:"riskScore"`                    // 0 to 100, computed on submission from signals on the ledger
+	RiskSignals          []string          `json:"riskSignals,omitempty"`        // what contributed to the risk score
+	UnderInvestigation   bool              `json:"underInvestigation,omitempty"` // risk score reached the fraud review threshold
+	CountedPolicyYear    string            `json:"countedPolicyYear,omitempty"`  // policy year whose claim limit the claim counts against
+	PayoutBreakdown      *PayoutBreakdown  `json:"payoutBreakdown,omitempty"`    // how the approved amount was worked out, nil for older approvals
+	CoverageType         string            `json:"coverageType"`
+	HospitalName         string            `json:"hospitalName"`
+	HospitalID           string            `json:"hospitalID,omitempty"`           // approved hospital the name resolved to, empty when the check was overridden
+	DateOfAdmission      string            `json:"dateOfAdmission"`                // YYYY-MM-DD, so dates sort and compare as strings
+	DateOfDischarge      string            `json:"dateOfDischarge"`                // YYYY-MM-DD
+	TreatmentDate        string            `json:"treatmentDate"`                  // YYYY-MM-DD
+	DocumentRefs         []DocumentRef     `json:"documentRefs"`                   // supporting documents, anchored by their SHA-256 hash
+	Status               string            `json:"status"`                         // one of the ClaimStatus or PreAuthStatus constants, see claimstatus.go
+	PreAuthID            string            `json:"preAuthID,omitempty"`            // pre-authorization the claim was made under
+	SanctionedAmount     int               `json:"sanctionedAmount,omitempty"`     // amount the pre-authorization sanctioned, caps the claim
+	SuspectedDuplicateOf string            `json:"suspectedDuplicateOf,omitempty"` // earlier claim this one likely repeats, see DUPLICATE_SUSPECT
+	IntimationID         string            `json:"intimationID,omitempty"`         // notice of admission the claim was filed under
+	LateIntimation       bool              `json:"lateIntimation,omitempty"`       // admission was not notified within the intimation window
+	Timestamp            string            `json:"timestamp"`
+	SubmittedAt          string            `json:"submittedAt,omitempty"`     // RFC3339, UTC, turnaround timestamps from here on
+	SubmittedBy          string            `json:"submittedBy,omitempty"`     // client ID of the hospital or policyholder who filed it
+	ReviewStartedAt      string            `json:"reviewStartedAt,omitempty"` // when an adjuster was first assigned
+	DecidedAt            string            `json:"decidedAt,omitempty"`       // when the claim was approved or rejected
+	EscalatedAt          string            `json:"escalatedAt,omitempty"`     // when the claim breached the turnaround SLA
+	RejectionReason      string            `json:"rejectionReason,omitempty"`
+	RejectedAt           string            `json:"rejectedAt,omitempty"`       // RFC3339, UTC, starts the appeal window
+	ApprovedAmount       int               `json:"approvedAmount,omitempty"`   // amount the insurer agreed to pay, at most the claim amount
+	DeductionReasons     []string          `json:"deductionReasons,omitempty"` // why the approved amount is below the claim amount
+	Remarks              string            `json:"remarks,omitempty"`          // insurer's notes on the approval
+
+	// information the insurer asked for before deciding, oldest first, see RaiseClaimQuery
+	Queries []ClaimQuery `json:"queries,omitempty"`
+
+	// approvals above the second-approval threshold are confirmed by a second adjuster, see dualcontrol.go
+	FirstApproval    *ClaimApproval `json:"firstApproval,omitempty"`    // decision of the first adjuster, held until confirmed
+	SecondApproverID string         `json:"secondApproverID,omitempty"` // client ID of the adjuster who confirmed it
+
+	// adjuster currently responsible for reviewing the claim
+	AssignedAdjusterID string `json:"assignedAdjusterID,omitempty"`
+	RoutingRationale   string `json:"routingRationale,omitempty"` // why the claim went to its adjuster's tier, see AutoRouteClaim
+
+	// cashless claims are paid to the hospital, reimbursement claims to the policyholder after they paid
+	ClaimType        string `json:"claimType,omitempty"`        // CASHLESS/REIMBURSEMENT, empty for claims filed before claim types
+	PaymentProofHash string `json:"paymentProofHash,omitempty"` // SHA-256 hash of the receipt, reimbursement claims only
+	BankReference    string `json:"bankReference,omitempty"`    // reference of the transfer that reimbursed the policyholder
+
+	// batch the claim is paid to its hospital in, see CreateSettlementBatch
+	SettlementBatchID string `json:"settlementBatchID,omitempty"`
+
+	// bills that surfaced after settlement are claimed on a supplementary claim, see ReopenClaim
+	ParentClaimID         string   `json:"parentClaimID,omitempty"`         // settled claim this one supplements
+	ReopenReason          string   `json:"reopenReason,omitempty"`          // why the settled claim was reopened
+	SupplementaryClaimIDs []string `json:"supplementaryClaimIDs,omitempty"` // claims that supplement this settled one
+
+	// claims on linked policies reference each other, see LinkPolicies
+	PrimaryClaimID   string `json:"primaryClaimID,omitempty"`   // claim on the primary policy this one pays the rest of
+	SecondaryClaimID string `json:"secondaryClaimID,omitempty"` // claim on the secondary policy for what this one left unpaid
+
+	// what each co-insurer pays, set when a claim on a co-insured policy is approved
+	CoInsuranceBreakdown []CoInsuranceShare `json:"coInsuranceBreakdown,omitempty"`
+
+	// Deprecated: free-text reason of claims submitted before diagnosis codes, see DiagnosisCodes,
+	// pre-authorizations still record their provisional diagnosis here
+	ClaimReason string `json:"claimReason,omitempty"`
+
+	// Deprecated: hashes of claims submitted before document references, see DocumentRefs
+	DocumentHashes []string `json:"documentHashes,omitempty"`
+
+	// Deprecated: free-form document reference of claims submitted before document hashes
+	Documents string `json:"documents,omitempty"`
+}
+
+// STRUCTURE FOR A SUPPORTING DOCUMENT KEPT OFF-CHAIN, ANCHORED BY ITS HASH
+type DocumentRef struct {
+	DocType    string `json:"docType"`       // kind of document, e.g. discharge summary or invoice
+	SHA256     string `json:"sha256"`        // hex-encoded, lower case
+	URI        string `json:"uri,omitempty"` // where the file is kept off-chain
+	UploadedBy string `json:"uploadedBy"`    // client ID of the submitter
+}
+
+// STRUCTURE FOR A SINGLE PAGE OF POLICIES
+type PaginatedPoliciesResult struct {
+	Policies []*Policy `json:"policies"`
+	Bookmark string    `json:"bookmark"` // pass back in to fetch the next page
+}
+
+// STRUCTURE FOR A SINGLE PAGE OF CLAIMS
+type PaginatedClaimsResult struct {
+	Claims   []*Claim `json:"claims"`
+	Bookmark string   `json:"bookmark"` // pass back in to fetch the next page
+}
+
+// STRUCTURE FOR THE TERM OF A POLICY THAT ENDED WITH A RENEWAL
+type PolicyRenewalRecord struct {
+	PolicyID             string `json:"policyID"`
+	PreviousEndDate      string `json:"previousEndDate"`
+	PreviousSumAssured   int    `json:"previousSumAssured"`
+	PreviousClaimedTotal int    `json:"previousClaimedTotal"`
+	RenewedAt            string `json:"renewedAt"`
+}
+
+// STRUCTURE FOR THE RESULT OF A PRE-CLAIM POLICY VALIDATION
+type PolicyValidationResult struct {
+	PolicyID string   `json:"policyID"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"` // every failed check, not just the first
+}
+
+// STRUCTURE FOR AN AUDIT LOG ENTRY OF ACCESS TO MEDICAL DATA
+type AccessLogEntry struct {
+	PolicyID  string `json:"policyID"`
+	TxID      string `json:"txID"`
+	Action    string `json:"action"`
+	UserID    string `json:"userID"`
+	Role      string `json:"role"`
+	Timestamp string `json:"timestamp"` // RFC3339, UTC
+}
+
+// STRUCTURE FOR A SINGLE HISTORICAL VERSION OF A POLICY
+type PolicyHistoryEntry struct {
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDeleted bool      `json:"isDeleted"`
+	Policy    *Policy   `json:"policy,omitempty"` // nil when the version is a deletion
+}
+
+// //////////////////////////////////////
+// CREATE NEW HEALTH INSURANCE POLICY //
+// //////////////////////////////////////
+func (c *HealthInsurance) CreatePolicy(ctx contractapi.TransactionContextInterface, policyID string, sumAssured int, personName string, dateOfBirth string, gender string, startDate string, endDate string, coPay int, preAuthThreshold int, waitingPeriodDays int, preExistingWaitingDays int, coveragesJSON string, benefitsJSON string, exclusionsJSON string, subLimitsJSON string, roomRentLimit int, deductible int, coInsurersJSON string, medicalConditions string) error {
+	// coverages and exclusions are given as JSON arrays of names or {name, codes} objects, benefits as strings
+	coverages, benefits, exclusions, err := parsePolicyTerms(coveragesJSON, benefitsJSON, exclusionsJSON)
+	if err != nil {
+		return err
+	}
+
+	subLimits, err := parseSubLimits(subLimitsJSON)
+	if err != nil {
+		return err
+	}
+
+	coInsurers, err := parseCoInsurers(coInsurersJSON)
+	if err != nil {
+		return err
+	}
+
+	input := &PolicyInput{
+		PolicyID:               policyID,
+		SumAssured:             sumAssured,
+		PersonName:             personName,
+		DateOfBirth:            dateOfBirth,
+		Gender:                 gender,
+		StartDate:              startDate,
+		EndDate:                endDate,
+		CoPay:                  coPay,
+		PreAuthThreshold:       preAuthThreshold,
+		WaitingPeriodDays:      waitingPeriodDays,
+		PreExistingWaitingDays: preExistingWaitingDays,
+		Coverages:              coverages,
+		Benefits:               benefits,
+		Exclusions:             exclusions,
+		SubLimits:              subLimits,
+		RoomRentLimit:          roomRentLimit,
+		Deductible:             deductible,
+		CoInsurers:             coInsurers,
+		MedicalConditions:      medicalConditions,
+	}
+
+	stats := newNetworkStats()
+	if err := c.createPolicy(ctx, input, stats); err != nil {
+		return err
+	}
+
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return err
+	}
+
+	// notify off-chain listeners of the new policy
+	return setChaincodeEvent(ctx, "PolicyCreated", policyID, "")
+}
+
+// /////////////////////////////////////////////////////
+// VALIDATE AND STORE A SINGLE NEW POLICY, NO EVENTS //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) createPolicy(ctx contractapi.TransactionContextInterface, input *PolicyInput, stats *NetworkStats) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// -1 selects the network-wide default
+	if input.WaitingPeriodDays == -1 {
+		input.WaitingPeriodDays = config.DefaultWaitingPeriodDays
+	}
+	if input.PreAuthThreshold == -1 {
+		input.PreAuthThreshold = config.DefaultPreAuthThreshold
+	}
+
+	if err := validatePolicyInput(input); err != nil {
+		return err
+	}
+
+	// dates are stored as YYYY-MM-DD, so CouchDB range queries compare them correctly
+	input.StartDate = normalizePolicyDate(input.StartDate)
+	input.EndDate = normalizePolicyDate(input.EndDate)
+
+	// the applicant must be of insurable age when the policy starts
+	if err := validateAge(input.DateOfBirth, input.StartDate, config.MinInsurableAge, config.MaxInsurableAge); err != nil {
+		return err
+	}
+
+	exists, err := c.PolicyExists(ctx, input.PolicyID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return NewConflictError(fmt.Sprintf("policy %s already exists", input.PolicyID))
+	}
+
+	// a draft is issued for review and only put in force by ActivatePolicy
+	status := PolicyStatusActive
+	if input.Draft {
+		status = PolicyStatusDraft
+	}
+
+	// the creating identity owns the policy and may read its medical data
+	ownerCertID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// non-sensitive data
+	policy := Policy{
+		ObjectType:             "policy",
+		PolicyID:               input.PolicyID,
+		SumAssured:             input.SumAssured,
+		Currency:               config.Currency,
+		PersonName:             input.PersonName,
+		DateOfBirth:            input.DateOfBirth,
+		Gender:                 input.Gender,
+		StartDate:              input.StartDate,
+		EndDate:                input.EndDate,
+		CoPay:                  input.CoPay,
+		PreAuthThreshold:       input.PreAuthThreshold,
+		WaitingPeriodDays:      input.WaitingPeriodDays,
+		PreExistingWaitingDays: input.PreExistingWaitingDays,
+		Coverages:              input.Coverages,
+		Benefits:               input.Benefits,
+		Exclusions:             input.Exclusions,
+		ClaimedTotal:           0,
+		SubLimits:              input.SubLimits,
+		SubLimitUtilized:       map[string]int{},
+		RoomRentLimit:          input.RoomRentLimit,
+		Deductible:             input.Deductible,
+		CoInsurers:             input.CoInsurers,
+		Status:                 status,
+		OwnerCertID:            ownerCertID,
+		Version:                1,
+	}
+
+	// convert non-sensitive data to json format
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal policy", err)
+	}
+
+	// store non-sensitive data in the ledger
+	if err := ctx.GetStub().PutState(input.PolicyID, policyJSON); err != nil {
+		return NewLedgerError("store policy", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// sensitive data, stored in the private collection using the policyID as the key
+	if err := putMedicalConditionsRecord(ctx, input.PolicyID, &MedicalConditionsRecord{
+		Conditions:  splitConditions(input.MedicalConditions),
+		LastUpdated: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}); err != nil {
+		return err
+	}
+
+	// the caller writes the statistics once, after all policies of the transaction
+	stats.trackPolicyChange(nil, &policy)
+	return nil
+}
+
+// ///////////////////////////////////////////
+// RETRIEVE POLICY DETAILS USING POLICY-ID //
+// ///////////////////////////////////////////
+func (c *HealthInsurance) GetPolicy(ctx contractapi.TransactionContextInterface, policyID string) (*Policy, error) {
+	policyJSON, err := ctx.GetStub().GetState(policyID)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if policyJSON == nil {
+		return nil, NewNotFoundError("policy", policyID)
+	}
+
+	var policy Policy
+	// convert the JSON data to a policy struct
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, NewLedgerError("unmarshal policy", err)
+	}
+
+	return &policy, nil
+}
+
+// /////////////////////////////////////////////
+// RETRIEVE ALL POLICIES, ONE PAGE AT A TIME //
+// /////////////////////////////////////////////
+func (c *HealthInsurance) GetPoliciesByPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedPoliciesResult, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// never fetch an unbounded amount of data in a single call
+	if pageSize <= 0 || pageSize > config.MaxPageSize {
+		return nil, NewValidationError("pageSize", fmt.Sprintf("invalid page size %d: must be between 1 and %d", pageSize, config.MaxPageSize))
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", pageSize, bookmark)
+	if err != nil {
+		return nil, NewLedgerError("read policies from world state", err)
+	}
+	defer iterator.Close()
+
+	policies := []*Policy{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate policies", err)
+		}
+
+		// other documents can share the key namespace, only keep policies
+		var policy Policy
+		if err := json.Unmarshal(result.Value, &policy); err != nil || policy.ObjectType != "policy" {
+			continue
+		}
+		policies = append(policies, &policy)
+	}
+
+	return &PaginatedPoliciesResult{
+		Policies: policies,
+		Bookmark: metadata.GetBookmark(),
+	}, nil
+}
+
+// /////////////////////////////////////////////////////////
+// RETRIEVE ALL POLICIES HELD BY A PERSON (COUCHDB ONLY) //
+// /////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetPoliciesByPerson(ctx contractapi.TransactionContextInterface, personName string) ([]*Policy, error) {
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]string{
+			"docType":    "policy",
+			"personName": personName,
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build policy query", err)
+	}
+
+	iterator, err := ctx.GetStub().GetQueryResult(string(query))
+	if err != nil {
+		return nil, NewLedgerError("query policies", err)
+	}
+	defer iterator.Close()
+
+	policies := []*Policy{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate policies", err)
+		}
+
+		var policy Policy
+		if err := json.Unmarshal(result.Value, &policy); err != nil {
+			return nil, NewLedgerError("unmarshal policy", err)
+		}
+		policies = append(policies, &policy)
+	}
+
+	return policies, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////
+// RETRIEVE ACTIVE POLICIES ENDING WITHIN A DATE RANGE, SOONEST FIRST (COUCHDB ONLY) //
+// /////////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetPoliciesExpiringBetween(ctx contractapi.TransactionContextInterface, startDate string, endDate string) ([]*Policy, error) {
+	// only insurers and auditors plan renewals
+	if err := assertRole(ctx, "insurer", "auditor"); err != nil {
+		return nil, err
+	}
+
+	from, err := parsePolicyDate("startDate", startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parsePolicyDate("endDate", endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if to.Before(from) {
+		return nil, NewValidationError("endDate", "endDate must not be before startDate")
+	}
+
+	// YYYY-MM-DD strings compare in date order, served by the docType/status/endDate index
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "policy",
+			"status":  map[string][]string{"$in": {PolicyStatusActive, "active"}}, // policies not rewritten since the state machine keep the old name
+			"endDate": map[string]string{
+				"$gte": from.Format("2006-01-02"),
+				"$lte": to.Format("2006-01-02"),
+			},
+		},
+	})
+	if err != nil {
+		return nil, NewLedgerError("build policy query", err)
+	}
+
+	iterator, err := ctx.GetStub().GetQueryResult(string(query))
+	if err != nil {
+		return nil, NewLedgerError("query policies", err)
+	}
+	defer iterator.Close()
+
+	policies := []*Policy{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate policies", err)
+		}
+
+		var policy Policy
+		if err := json.Unmarshal(result.Value, &policy); err != nil {
+			return nil, NewLedgerError("unmarshal policy", err)
+		}
+		policies = append(policies, &policy)
+	}
+
+	// soonest expiry first, ties broken by ID so the order is deterministic
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].EndDate != policies[j].EndDate {
+			return policies[i].EndDate < policies[j].EndDate
+		}
+		return policies[i].PolicyID < policies[j].PolicyID
+	})
+
+	return policies, nil
+}
+
+// ////////////////////////////////////////////////////
+// RETRIEVE EVERY VERSION OF A POLICY, OLDEST FIRST //
+// ////////////////////////////////////////////////////
+func (c *HealthInsurance) GetPolicyHistory(ctx contractapi.TransactionContextInterface, policyID string) ([]*PolicyHistoryEntry, error) {
+	role, err := getClientRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "insurer" && role != "auditor" && role != "patient" {
+		return nil, NewUnauthorizedError(fmt.Sprintf("unauthorized access: role %q is not permitted, requires one of insurer, auditor, patient", role))
+	}
+
+	// patients can only see the history of their own policy
+	if role == "patient" {
+		policy, err := c.GetPolicy(ctx, policyID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	historyIterator, err := ctx.GetStub().GetHistoryForKey(policyID)
+	if err != nil {
+		return nil, NewLedgerError("read history for policy "+policyID, err)
+	}
+	defer historyIterator.Close()
+
+	history := []*PolicyHistoryEntry{}
+	for historyIterator.HasNext() {
+		modification, err := historyIterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate policy history", err)
+		}
+
+		entry := &PolicyHistoryEntry{
+			TxID:      modification.GetTxId(),
+			Timestamp: modification.GetTimestamp().AsTime().UTC(),
+			IsDeleted: modification.GetIsDelete(),
+		}
+
+		// deletions carry no value
+		if !entry.IsDeleted {
+			var policy Policy
+			if err := json.Unmarshal(modification.GetValue(), &policy); err != nil {
+				return nil, NewLedgerError("unmarshal policy", err)
+			}
+			entry.Policy = &policy
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// /////////////////////////////////
+// CHECK WHETHER A POLICY EXISTS //
+// /////////////////////////////////
+func (c *HealthInsurance) PolicyExists(ctx contractapi.TransactionContextInterface, policyID string) (bool, error) {
+	policyJSON, err := ctx.GetStub().GetState(policyID)
+	if err != nil {
+		return false, NewLedgerError("read from world state", err)
+	}
+
+	return policyJSON != nil, nil
+}
+
+// /////////////////////////////////////////////////////////
+// VERIFY THAT A POLICY EXISTS ON ANOTHER FABRIC CHANNEL //
+// /////////////////////////////////////////////////////////
+func (c *HealthInsurance) VerifyPolicyOnChannel(ctx contractapi.TransactionContextInterface, channelID string, policyID string) (bool, error) {
+	// query the health insurance chaincode deployed on the other channel, no data is replicated
+	response := ctx.GetStub().InvokeChaincode("health_insurance", [][]byte{[]byte("PolicyExists"), []byte(policyID)}, channelID)
+	if response.Status != shim.OK {
+		return false, NewLedgerError("verify policy on channel "+channelID, errors.New(response.Message))
+	}
+
+	exists, err := strconv.ParseBool(string(response.Payload))
+	if err != nil {
+		return false, NewLedgerError("parse policy verification response", err)
+	}
+
+	return exists, nil
+}
+
+// ///////////////////////////////////////////////////////
+// VALIDATE A POLICY BEFORE A CLAIM IS SUBMITTED ON IT //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) ValidatePolicyForClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, diagnosisCode string, hospitalID string) (*PolicyValidationResult, error) {
+	return c.validatePolicyForClaim(ctx, policyID, claimAmount, "", diagnosisCode, hospitalID)
+}
+
+// //////////////////////////////////////////////////////////
+// VALIDATE A POLICY FOR A CLAIM OF A GIVEN COVERAGE TYPE //
+// //////////////////////////////////////////////////////////
+func (c *HealthInsurance) validatePolicyForClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, coverageType string, diagnosisCode string, hospitalID string) (*PolicyValidationResult, error) {
+	result := &PolicyValidationResult{
+		PolicyID: policyID,
+		Errors:   []string{},
+	}
+
+	// the policy must exist, otherwise none of the other checks can run
+	policyJSON, err := ctx.GetStub().GetState(policyID)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if policyJSON == nil {
+		result.Errors = append(result.Errors, "policy does not exist")
+		return result, nil
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, NewLedgerError("unmarshal policy", err)
+	}
+
+	// claims can only be made against active policies
+	switch policy.Status {
+	case PolicyStatusActive:
+	case PolicyStatusDraft:
+		result.Errors = append(result.Errors, "policy is a draft, claims can be made once it is activated")
+	case PolicyStatusLapsePending:
+		result.Errors = append(result.Errors, fmt.Sprintf("policy is in its grace period until %s, claims can be made again once it is renewed or its premiums are paid", policy.GraceEndsOn))
+	case PolicyStatusLapsed:
+		result.Errors = append(result.Errors, "policy has lapsed, claims can be made again once its premiums are paid and it is reactivated")
+	case PolicyStatusSuspended:
+		result.Errors = append(result.Errors, "policy is suspended, claims can be made again once it is reinstated")
+	case PolicyStatusCancelled:
+		// a policy cancelled through CancelPolicy still pays for admissions up to its cancellation date
+		if policy.CancellationDate == "" {
+			result.Errors = append(result.Errors, "policy is cancelled, claims can no longer be made against it")
+		}
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("policy is not active, current status is %q", policy.Status))
+	}
+
+	// the claim must be made while the policy is in force
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+	if err := checkPolicyPeriod(&policy, txTimestamp.AsTime()); err != nil {
+		result.Errors = append(result.Errors, errorMessage(err))
+	}
+
+	// claims are refused while any premium is overdue
+	overduePremiums, err := getOverduePremiums(ctx, policyID, txTimestamp.AsTime())
+	if err != nil {
+		return nil, err
+	}
+	if len(overduePremiums) > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("policy has overdue premiums due on %s", strings.Join(overduePremiums, ", ")))
+	}
+
+	// the hospital, when given, must be in the approved network
+	if hospitalID != "" {
+		hospital, err := getHospital(ctx, hospitalID)
+		if err != nil {
+			return nil, err
+		}
+		if hospital == nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("hospital %s is not registered", hospitalID))
+		} else if !hospital.Active {
+			result.Errors = append(result.Errors, fmt.Sprintf("hospital %s is no longer an approved hospital", hospitalID))
+		}
+	}
+
+	// the insured portion of the claim, capped by any sub-limit, must not exceed the remaining sum assured
+	if claimAmount <= 0 {
+		result.Errors = append(result.Errors, "claim amount must be greater than zero")
+	} else if err := checkAmount("claimAmount", claimAmount); err != nil {
+		result.Errors = append(result.Errors, errorMessage(err))
+	} else if payable, err := subLimitedAmount(&policy, insuredPortion(claimAmount, policy.CoPay), coverageType); err != nil {
+		result.Errors = append(result.Errors, errorMessage(err))
+	} else if policy.ClaimedTotal+payable > policy.SumAssured {
+		result.Errors = append(result.Errors, "claim amount exceeds sum assured")
+	}
+
+	result.Valid = len(result.Errors) == 0
+
+	return result, nil
+}
+
+// ///////////////////////////////
+// SUBMIT A CLAIM FOR A POLICY //
+// ///////////////////////////////
+func (c *HealthInsurance) SubmitClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, diagnosisCodesJSON string, coverageType string, hospitalName string, dateOfAdmission string, dateOfDischarge string, treatmentDate string, documentsJSON string, preAuthID string, claimType string, paymentProofHash string, intimationID string, proceduresJSON string, lineItemsJSON string) (string, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkHighValueThreshold(config, claimAmount); err != nil {
+		return "", err
+	}
+
+	return c.submitClaim(ctx, policyID, claimAmount, diagnosisCodesJSON, coverageType, hospitalName, dateOfAdmission, dateOfDischarge, treatmentDate, documentsJSON, preAuthID, claimType, paymentProofHash, intimationID, proceduresJSON, lineItemsJSON)
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// REFUSE A CLAIM THAT NEEDS A WITNESS SIGNATURE ON AN UNWITNESSED PATH //
+// ////////////////////////////////////////////////////////////////////////
+func checkHighValueThreshold(config *ChaincodeConfig, claimAmount int) error {
+	// large claims need a second party to sign off, see SubmitHighValueClaim
+	if config.HighValueClaimThreshold > 0 && claimAmount > config.HighValueClaimThreshold {
+		return NewValidationError("claimAmount", fmt.Sprintf("claim amount %d exceeds the high-value threshold %d, submit it with a witness signature through SubmitHighValueClaim", claimAmount, config.HighValueClaimThreshold))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////
+// VALIDATE AND STORE A NEW CLAIM, WHATEVER ITS AMOUNT //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) submitClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, diagnosisCodesJSON string, coverageType string, hospitalName string, dateOfAdmission string, dateOfDischarge string, treatmentDate string, documentsJSON string, preAuthID string, claimType string, paymentProofHash string, intimationID string, proceduresJSON string, lineItemsJSON string) (string, error) {
+	// every claim gets its own ID, derived from the transaction that submitted it
+	claimID := ctx.GetStub().GetTxID()
+
+	claim, err := c.fileClaim(ctx, claimID, policyID, claimAmount, diagnosisCodesJSON, coverageType, hospitalName, dateOfAdmission, dateOfDischarge, treatmentDate, documentsJSON, preAuthID, claimType, paymentProofHash, intimationID, proceduresJSON, lineItemsJSON)
+	if err != nil {
+		return "", err
+	}
+
+	// with auto-assignment on, the new claim goes straight to an adjuster of the right tier
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	router, err := c.newClaimRouter(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	adjuster, rationale, err := router.routeNewClaim(ctx, claim)
+	if err != nil {
+		return "", err
+	}
+
+	// fabric keeps one event per transaction, so the routing outcome goes into the submission event
+	event := &ChaincodeEvent{EventType: "ClaimSubmitted", PolicyID: policyID, ClaimID: claimID, Amount: claimAmount}
+	if adjuster != nil {
+		event.AdjusterID = adjuster.AdjusterID
+		event.AdjusterTier = adjuster.Tier
+		event.RoutingRationale = rationale
+	}
+	if err := putChaincodeEvent(ctx, event); err != nil {
+		return "", err
+	}
+
+	return claimID, nil
+}
+
+// ////////////////////////////////////////////////////////////////////
+// VALIDATE A NEW CLAIM AND, ONLY ONCE EVERY CHECK PASSED, STORE IT //
+// ////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) fileClaim(ctx contractapi.TransactionContextInterface, claimID string, policyID string, claimAmount int, diagnosisCodesJSON string, coverageType string, hospitalName string, dateOfAdmission string, dateOfDischarge string, treatmentDate string, documentsJSON string, preAuthID string, claimType string, paymentProofHash string, intimationID string, proceduresJSON string, lineItemsJSON string) (*Claim, error) {
+	// claims are filed by policyholders or by hospitals on their behalf
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := assertMSP(ctx, config.AllowedPatientMSP, config.AllowedHospitalMSP); err != nil {
+		return nil, err
+	}
+
+	// a claim made under a pre-authorization inherits its hospital and stays within the sanctioned amount
+	var preAuth *Claim
+	sanctionedAmount := 0
+	if preAuthID != "" {
+		preAuth, err = c.getClaimablePreAuth(ctx, policyID, preAuthID)
+		if err != nil {
+			return nil, err
+		}
+		sanctionedAmount = payableAmount(preAuth)
+
+		if strings.TrimSpace(hospitalName) == "" {
+			hospitalName = preAuth.HospitalName
+		} else if !strings.EqualFold(strings.TrimSpace(hospitalName), strings.TrimSpace(preAuth.HospitalName)) {
+			return nil, NewValidationError("hospitalName", fmt.Sprintf("pre-authorization %s was granted for hospital %q, not %q", preAuthID, preAuth.HospitalName, hospitalName))
+		}
+		if claimAmount > sanctionedAmount {
+			return nil, NewValidationError("claimAmount", fmt.Sprintf("claim amount %d exceeds the %d sanctioned by pre-authorization %s", claimAmount, sanctionedAmount, preAuthID))
+		}
+	}
+
+	// the hospital must be in the approved network, unless an insurer explicitly overrides the check
+	hospitalID := ""
+	overrideHospitalCheck, err := hasHospitalCheckOverride(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !overrideHospitalCheck {
+		hospital, err := findApprovedHospital(ctx, hospitalName)
+		if err != nil {
+			return nil, err
+		}
+		if hospital == nil {
+			return nil, NewValidationError("hospitalName", fmt.Sprintf("hospital %q is not an approved hospital", hospitalName))
+		}
+		hospitalID = hospital.HospitalID
+	}
+
+	// run all pre-flight checks on the policy before accepting the claim
+	validation, err := c.validatePolicyForClaim(ctx, policyID, claimAmount, coverageType, "", hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	if !validation.Valid {
+		return nil, NewStateError(fmt.Sprintf("claim validation failed: %s", strings.Join(validation.Errors, "; ")))
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnosisCodes, err := parseDiagnosisCodes(ctx, diagnosisCodesJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// the treatment must be covered and not excluded, diagnoses outside every covered category are flagged
+	coverageMatched, err := checkEligibility(policy, coverageType, diagnosisCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// only admissions within the policy's term are covered
+	if err := checkAdmissionPeriod(policy, dateOfAdmission); err != nil {
+		return nil, err
+	}
+
+	// stays at a blacklisted hospital are refused, stays before the blacklist took effect are only flagged
+	blacklistEntry, err := findBlacklistEntry(ctx, hospitalID, hospitalName)
+	if err != nil {
+		return nil, err
+	}
+	if blacklistEntry != nil {
+		admittedOn, err := parsePolicyDate("dateOfAdmission", dateOfAdmission)
+		if err != nil {
+			return nil, err
+		}
+		if blacklistEntry.inEffect(admittedOn) {
+			return nil, NewValidationError("hospitalName", fmt.Sprintf("hospital %q is blacklisted from %s: %s", blacklistEntry.HospitalName, blacklistEntry.EffectiveFrom, blacklistEntry.Reason))
+		}
+	}
+
+	// admissions during the waiting period are not covered, pre-existing conditions come from the private record
+	medicalRecord, err := getMedicalConditionsRecord(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+	medicalConditions := []string{}
+	if medicalRecord != nil {
+		medicalConditions = medicalRecord.Conditions
+	}
+
+	if err := checkWaitingPeriod(policy, medicalConditions, dateOfAdmission, diagnosisCodes, coverageType); err != nil {
+		return nil, err
+	}
+
+	// the policyholder must still be of insurable age when admitted
+	if err := validateAge(policy.DateOfBirth, dateOfAdmission, 0, config.MaxInsurableAge); err != nil {
+		return nil, err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, NewLedgerError("get client ID", err)
+	}
+
+	// documents stay off-chain, only their hashes and locations are recorded
+	documentRefs, err := parseDocumentRefs(documentsJSON, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// a cashless claim is settled with a network hospital, a reimbursement with the policyholder who paid it
+	var payee *PayeeDetails
+	claimType = strings.ToUpper(strings.TrimSpace(claimType))
+	switch claimType {
+	case ClaimTypeCashless:
+		if preAuth == nil {
+			return nil, NewValidationError("preAuthID", "cashless claims require an approved pre-authorization")
+		}
+		if hospitalID == "" {
+			return nil, NewValidationError("hospitalName", "cashless claims must be made at a hospital in the approved network")
+		}
+		paymentProofHash = ""
+	case ClaimTypeReimbursement:
+		if paymentProofHash == "" {
+			return nil, NewValidationError("paymentProofHash", "reimbursement claims require a payment proof hash")
+		}
+		paymentProofHash, err = validateDocumentHash("paymentProofHash", paymentProofHash)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDocumentChecklist(config, documentRefs); err != nil {
+			return nil, err
+		}
+		payee, err = readPayeeDetails(ctx)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, NewValidationError("claimType", fmt.Sprintf("invalid claim type %q: must be %s or %s", claimType, ClaimTypeCashless, ClaimTypeReimbursement))
+	}
+
+	// procedures billed above their agreed package rate are flagged for review during adjudication
+	procedureCharges, overTariffAmount, err := parseProcedureCharges(ctx, proceduresJSON, claimAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// large claims need an approved pre-authorization for the same policy
+	if policy.PreAuthThreshold > 0 && claimAmount > policy.PreAuthThreshold && preAuth == nil {
+		return nil, NewValidationError("preAuthID", fmt.Sprintf("claim amount %d exceeds the pre-authorization threshold %d, an approved pre-authorization is required", claimAmount, policy.PreAuthThreshold))
+	}
+
+	// a pre-authorization covers a single claim
+	if preAuth != nil {
+		if err := transitionClaim(preAuth, PreAuthStatusClaimed); err != nil {
+			return nil, err
+		}
+	}
+
+	// a bill given line by line is only paid for its payable lines
+	lineItems, err := parseLineItems(config, lineItemsJSON, claimAmount)
+	if err != nil {
+		return nil, err
+	}
+	billedAmount := claimAmount
+	if len(lineItems) > 0 {
+		billedAmount = payableLineTotal(lineItems)
+		if billedAmount == 0 {
+			return nil, NewValidationError("lineItems", "none of the line items are payable")
+		}
+	}
+
+	// the policyholder pays the co-pay, only the rest counts against the sum assured
+	insuredAmount := insuredPortion(billedAmount, policy.CoPay)
+
+	// a sub-limit caps what is paid for its coverage type
+	insuredAmount, err = subLimitedAmount(policy, insuredAmount, coverageType)
+	if err != nil {
+		return nil, err
+	}
+
+	// the claimed total and sub-limit usage are only charged once the claim is paid out
+
+	// get transaction timestamp
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+
+	// log the claim details
+	claim := Claim{
+		ObjectType:        "claim",
+		ClaimID:           claimID,
+		PolicyID:          policyID,
+		ClaimAmount:       insuredAmount,
+		GrossAmount:       claimAmount,
+		Currency:          config.Currency,
+		DiagnosisCodes:    diagnosisCodes,
+		ProcedureCodes:    procedureCharges,
+		OverTariffAmount:  overTariffAmount,
+		LineItems:         lineItems,
+		UnmatchedCoverage: !coverageMatched,
+		CoverageType:      coverageType,
+		PreAuthID:         preAuthID,
+		SanctionedAmount:  sanctionedAmount,
+		HospitalName:      hospitalName,
+		HospitalID:        hospitalID,
+		DateOfAdmission:   normalizePolicyDate(dateOfAdmission),
+		DateOfDischarge:   normalizePolicyDate(dateOfDischarge),
+		TreatmentDate:     normalizePolicyDate(treatmentDate),
+		DocumentRefs:      documentRefs,
+		Status:            ClaimStatusSubmitted,
+		Timestamp:         fmt.Sprintf("%d", txTimestamp.Seconds),
+		SubmittedAt:       txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		SubmittedBy:       clientID,
+
+		ClaimType:        claimType,
+		PaymentProofHash: paymentProofHash,
+	}
+
+	// high-risk claims are routed to manual investigation
+	claim.RiskScore, claim.RiskSignals, err = scoreClaimRisk(ctx, policy, &claim, txTimestamp.AsTime().UTC())
+	if err != nil {
+		return nil, err
+	}
+	claim.UnderInvestigation = claim.RiskScore >= config.FraudReviewThreshold
+
+	// claims filed after the filing window need an exception granted by an insurer admin
+	lateClaimException, err := checkClaimFilingWindow(ctx, config, &claim, txTimestamp.AsTime().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	// a coverage type may only be claimed a limited number of times per policy year
+	claimCounter, err := countClaim(ctx, policy, &claim)
+	if err != nil {
+		return nil, err
+	}
+
+	// insurers expect notice of the admission before the claim, late or missing notice is flagged
+	var intimation *Intimation
+	claim.LateIntimation = true
+	if intimationID != "" {
+		intimation, err = useIntimation(ctx, &claim, intimationID)
+		if err != nil {
+			return nil, err
+		}
+		claim.IntimationID = intimationID
+		claim.LateIntimation = !intimation.Timely
+	}
+
+	// a likely repeat of an earlier claim is rejected or held for review, as configured
+	duplicate, err := findDuplicateClaim(ctx, config, &claim)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate != nil {
+		if config.DuplicateClaimAction == "reject" {
+			return nil, NewConflictError(fmt.Sprintf("claim likely duplicates claim %s on policy %s", duplicate.ClaimID, policyID))
+		}
+		claim.Status = ClaimStatusDuplicateSuspect
+		claim.SuspectedDuplicateOf = duplicate.ClaimID
+	}
+
+	// nothing is written until every check passed, so a batch can skip a failed claim without a trace
+	if preAuth != nil {
+		if err := putClaim(ctx, preAuth); err != nil {
+			return nil, err
+		}
+	}
+	if lateClaimException != nil {
+		if err := putLateClaimException(ctx, lateClaimException); err != nil {
+			return nil, err
+		}
+	}
+	if claimCounter != nil {
+		if err := putClaimCounter(ctx, claimCounter); err != nil {
+			return nil, err
+		}
+	}
+	if intimation != nil {
+		if err := putIntimation(ctx, intimation); err != nil {
+			return nil, err
+		}
+	}
+
+	// store the claim under its own composite key so earlier claims are never overwritten
+	if err := putClaim(ctx, &claim); err != nil {
+		return nil, err
+	}
+
+	// index the claim ID so the claim can be found without knowing its policy
+	if err := indexClaimID(ctx, claimID, policyID); err != nil {
+		return nil, err
+	}
+
+	// the bank details go to the insurer's private collection, never into the claim itself
+	if payee != nil {
+		payee.ClaimID = claimID
+		payee.PolicyID = policyID
+		if err := putPayeeDetails(ctx, payee); err != nil {
+			return nil, err
+		}
+	}
+
+	return &claim, nil
+}
+
+// //////////////////////////////////
+// UPDATE THE DETAILS OF A POLICY //
+// //////////////////////////////////
+func (c *HealthInsurance) UpdatePolicy(ctx contractapi.TransactionContextInterface, policyID string, sumAssured int, personName string, dateOfBirth string, gender string, startDate string, endDate string, coPay int, preAuthThreshold int, coveragesJSON string, benefitsJSON string, exclusionsJSON string, subLimitsJSON string, roomRentLimit int, deductible int, expectedVersion int, changeReason string) error {
+	// every update must say why it was made, for the change log
+	if strings.TrimSpace(changeReason) == "" {
+		return NewValidationError("changeReason", "change reason must not be empty")
+	}
+
+	if err := validatePolicyDates(startDate, endDate); err != nil {
+		return err
+	}
+	startDate = normalizePolicyDate(startDate)
+	endDate = normalizePolicyDate(endDate)
+
+	if err := checkAmount("sumAssured", sumAssured); err != nil {
+		return err
+	}
+
+	// co-pay is a percentage of each claim
+	if coPay < 0 || coPay > 100 {
+		return NewValidationError("coPay", fmt.Sprintf("invalid co-pay %d: must be between 0 and 100", coPay))
+	}
+
+	if err := checkAmount("preAuthThreshold", preAuthThreshold); err != nil {
+		return err
+	}
+
+	if err := validateRoomRentLimit(roomRentLimit); err != nil {
+		return err
+	}
+
+	if err := checkAmount("deductible", deductible); err != nil {
+		return err
+	}
+
+	// coverages and exclusions are given as JSON arrays of names or {name, codes} objects, benefits as strings
+	coverages, benefits, exclusions, err := parsePolicyTerms(coveragesJSON, benefitsJSON, exclusionsJSON)
+	if err != nil {
+		return err
+	}
+
+	subLimits, err := parseSubLimits(subLimitsJSON)
+	if err != nil {
+		return err
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	// only active policies can be changed
+	if policy.Status != PolicyStatusActive {
+		return NewStateError(fmt.Sprintf("cannot update policy %s, current status is %q", policyID, policy.Status))
+	}
+
+	// optimistic locking: refuse to overwrite changes the caller has not seen
+	if policy.Version != expectedVersion {
+		return NewConflictError(fmt.Sprintf("policy %s has been modified, expected version %d but found %d", policyID, expectedVersion, policy.Version))
+	}
+
+	previous := *policy
+
+	// update with the new values, the no-claim bonus is added on the new sum assured at the next renewal
+	policy.SumAssured = sumAssured
+	policy.BaseSumAssured = 0
+	policy.PersonName = personName
+	policy.DateOfBirth = dateOfBirth
+	policy.Gender = gender
+	policy.StartDate = startDate
+	policy.EndDate = endDate
+	policy.CoPay = coPay
+	policy.PreAuthThreshold = preAuthThreshold
+	policy.Coverages = coverages
+	policy.Benefits = benefits
+	policy.Exclusions = exclusions
+	policy.SubLimits = subLimits
+	policy.RoomRentLimit = roomRentLimit
+	policy.Deductible = deductible
+	policy.Version++
+
+	if err := recordPolicyChange(ctx, &previous, policy, changeReason); err != nil {
+		return err
+	}
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	// convert the updated policy struct to JSON format
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	// emit the lifecycle event before the final write
+	if err := setChaincodeEvent(ctx, "PolicyUpdated", policyID, ""); err != nil {
+		return err
+	}
+
+	// store the updated policy in the ledger
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////
+// CANCEL A POLICY, KEEPING ITS HISTORY ON THE LEDGER //
+// //////////////////////////////////////////////////////
+func (c *HealthInsurance) DeletePolicy(ctx contractapi.TransactionContextInterface, policyID string) error {
+	// cancellation is restricted to insurers and admins of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer", "admin"); err != nil {
+		return err
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	if policy.Status == PolicyStatusCancelled {
+		return NewStateError(fmt.Sprintf("policy %s is already cancelled", policyID))
+	}
+	if err := validatePolicyTransition(policy, PolicyStatusCancelled); err != nil {
+		return err
+	}
+
+	// soft delete: the record stays in the world state so its history is preserved,
+	// and the medical conditions stay in the private collection for audits
+	previous := *policy
+	policy.Status = PolicyStatusCancelled
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	// convert the updated policy struct to JSON format
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	// emit the lifecycle event before the final write
+	if err := setChaincodeEvent(ctx, "PolicyCancelled", policyID, ""); err != nil {
+		return err
+	}
+
+	// store the updated policy in the ledger
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return nil
+}
+
+// /////////////////////////////////
+// RENEW A POLICY FOR A NEW TERM //
+// /////////////////////////////////
+func (c *HealthInsurance) RenewPolicy(ctx contractapi.TransactionContextInterface, policyID string, newEndDate string, newSumAssured int, newPremium int) error {
+	// only the insurer renews policies
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	if policy.Status != PolicyStatusActive && policy.Status != PolicyStatusExpired && policy.Status != PolicyStatusLapsePending {
+		return NewStateError(fmt.Sprintf("cannot renew policy %s, current status is %q", policyID, policy.Status))
+	}
+
+	currentEnd, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return err
+	}
+
+	newEnd, err := parsePolicyDate("new end date", newEndDate)
+	if err != nil {
+		return err
+	}
+
+	if !newEnd.After(currentEnd) {
+		return NewValidationError("newEndDate", fmt.Sprintf("new end date %s must be after the current end date %s", newEndDate, policy.EndDate))
+	}
+
+	// a zero sum assured keeps the current one
+	if err := checkAmount("newSumAssured", newSumAssured); err != nil {
+		return err
+	}
+	if newPremium <= 0 {
+		return NewValidationError("newPremium", "premium for the new term must be greater than zero")
+	}
+	if err := checkAmount("newPremium", newPremium); err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// the end date is inclusive, the grace window runs from the day after it
+	graceEnd := currentEnd.AddDate(0, 0, 1+config.RenewalGraceDays)
+	if !now.Before(graceEnd) {
+		return NewStateError(fmt.Sprintf("cannot renew policy %s, its grace window closed on %s", policyID, graceEnd.AddDate(0, 0, -1).Format("2006-01-02")))
+	}
+
+	// keep the closing figures of the previous term for auditing
+	renewal := PolicyRenewalRecord{
+		PolicyID:             policyID,
+		PreviousEndDate:      policy.EndDate,
+		PreviousSumAssured:   policy.SumAssured,
+		PreviousClaimedTotal: policy.ClaimedTotal,
+		RenewedAt:            now.Format(time.RFC3339),
+	}
+
+	renewalJSON, err := json.Marshal(renewal)
+	if err != nil {
+		return NewLedgerError("marshal renewal record", err)
+	}
+
+	renewalKey, err := ctx.GetStub().CreateCompositeKey("renewal", []string{policyID, policy.EndDate})
+	if err != nil {
+		return NewLedgerError("create renewal key", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData("policy-renewal-history", renewalKey, renewalJSON); err != nil {
+		return NewLedgerError("store renewal record", err)
+	}
+
+	// a claim-free term earns a no-claim bonus on the sum assured, a term with claims gives some of it back
+	baseSumAssured := policy.BaseSumAssured
+	if baseSumAssured == 0 {
+		baseSumAssured = policy.SumAssured
+	}
+	if newSumAssured > 0 {
+		baseSumAssured = newSumAssured
+	}
+	noClaimBonus := nextNoClaimBonus(config, policy)
+	sumAssured, err := addAmounts("newSumAssured", baseSumAssured, scaleAmount(baseSumAssured, noClaimBonus, 100))
+	if err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// the new term starts the day after the old one ended, even when renewed late in the grace window
+	term := &PolicyRenewal{
+		ObjectType:         "policyRenewal",
+		PolicyID:           policyID,
+		TermStartDate:      currentEnd.AddDate(0, 0, 1).Format("2006-01-02"),
+		TermEndDate:        normalizePolicyDate(newEndDate),
+		PreviousEndDate:    policy.EndDate,
+		PreviousSumAssured: policy.SumAssured,
+		SumAssured:         sumAssured,
+		NoClaimBonus:       noClaimBonus,
+		Premium:            newPremium,
+		RenewedBy:          clientID,
+		RenewedAt:          now.Format(time.RFC3339),
+	}
+	if err := putPolicyRenewal(ctx, term); err != nil {
+		return err
+	}
+
+	// the premium for the new term falls due on its first day
+	premium := &Premium{
+		ObjectType: "premium",
+		PremiumID:  policyID + "~" + term.TermStartDate,
+		PolicyID:   policyID,
+		Amount:     newPremium,
+		DueDate:    term.TermStartDate,
+		Status:     "due",
+	}
+	if err := putPremium(ctx, premium); err != nil {
+		return err
+	}
+
+	// the sum assured resets for every new term
+	previous := *policy
+	policy.EndDate = term.TermEndDate
+	policy.BaseSumAssured = baseSumAssured
+	policy.SumAssured = sumAssured
+	policy.NoClaimBonus = noClaimBonus
+	policy.GraceEndsOn = ""
+	policy.ClaimedTotal = 0
+	policy.SubLimitUtilized = map[string]int{}
+	policy.Status = PolicyStatusActive
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	// convert the updated policy struct to JSON format
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	// emit the lifecycle event before the final write
+	if err := setChaincodeEvent(ctx, "PolicyRenewed", policyID, ""); err != nil {
+		return err
+	}
+
+	// store the updated policy in the ledger
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////
+// MARK POLICIES PAST THEIR END DATE AS EXPIRED //
+// ////////////////////////////////////////////////
+func (c *HealthInsurance) ExpirePolicies(ctx contractapi.TransactionContextInterface) (int, error) {
+	// only the insurance organisation runs the expiry sweep
+	config, err := getConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := assertMSP(ctx, config.AllowedInsuranceMSP); err != nil {
+		return 0, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	iterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, NewLedgerError("read policies from world state", err)
+	}
+	defer iterator.Close()
+
+	expired := 0
+	stats := newNetworkStats()
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return 0, NewLedgerError("iterate policies", err)
+		}
+
+		// other documents can share the key namespace, only keep policies
+		var policy Policy
+		if err := json.Unmarshal(result.Value, &policy); err != nil || policy.ObjectType != "policy" {
+			continue
+		}
+
+		// only policies in force, in their grace period, suspended or lapsed can expire
+		if validatePolicyTransition(&policy, PolicyStatusExpired) != nil {
+			continue
+		}
+
+		// the end date is inclusive, so the policy expires the day after
+		end, err := parsePolicyDate("end date", policy.EndDate)
+		if err != nil {
+			return 0, err
+		}
+		if now.Before(end.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		// a policy in force is left to the lapse sweep until its renewal grace window closes
+		inGrace, err := inRenewalGrace(config, &policy, now)
+		if err != nil {
+			return 0, err
+		}
+		if inGrace {
+			continue
+		}
+
+		previous := policy
+		policy.Status = PolicyStatusExpired
+		policy.Version++
+		stats.trackPolicyChange(&previous, &policy)
+
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return 0, NewLedgerError("marshal updated policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return 0, NewLedgerError("store updated policy", err)
+		}
+		expired++
+	}
+
+	if expired > 0 {
+		if err := applyNetworkStats(ctx, stats); err != nil {
+			return 0, err
+		}
+	}
+
+	return expired, nil
+}
+
+// ////////////////////////////////////////////////////////////////
+// RETRIEVE SENSITIVE MEDICAL DATA, FOR AUTHORISED PARTIES ONLY //
+// ////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetMedicalConditions(ctx contractapi.TransactionContextInterface, policyID string) (string, error) {
+	role, err := c.authorizeMedicalAccess(ctx, policyID, "accessed medical conditions")
+	if err != nil {
+		return "", err
+	}
+
+	// retrieve private data from the private collection
+	record, err := getMedicalConditionsRecord(ctx, policyID)
+	if err != nil {
+		return "", err
+	}
+	if record == nil {
+		return "", NewNotFoundError("medical record", policyID)
+	}
+
+	// only return the conditions the caller is cleared to see
+	visibleConditions, err := filterVisibleConditions(ctx, policyID, role, record.Conditions)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(visibleConditions, ", "), nil
+}
+
+// ////////////////////////////////////////////////////////
+// CHECK AND LOG ACCESS TO THE MEDICAL DATA OF A POLICY //
+// ////////////////////////////////////////////////////////
+func (c *HealthInsurance) authorizeMedicalAccess(ctx contractapi.TransactionContextInterface, policyID string, action string) (string, error) {
+	// get the client's identity
+	clientIdentity := ctx.GetClientIdentity()
+
+	// check permissions/role
+	role, found, err := clientIdentity.GetAttributeValue("role")
+	if err != nil {
+		return "", NewLedgerError("get client role attribute", err)
+	}
+
+	if !found {
+		return "", NewUnauthorizedError("client role attribute not found")
+	}
+
+	// ENSURE THAT ONLY AUTHORISED USERS CAN ACCESS SENSITIVE DATA
+	// Role-Based Access Control (RBAC)
+	if role != "doctor" && role != "senior_doctor" && role != "patient" {
+		return "", NewUnauthorizedError("unauthorized access: only doctors or patients can access medical conditions")
+	}
+
+	clientID, err := clientIdentity.GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	// only patient can access their own data
+	if role == "patient" {
+		policy, err := c.GetPolicy(ctx, policyID)
+		if err != nil {
+			return "", err
+		}
+
+		// compare certificate identities, the person name is not an identity
+		if policy.OwnerCertID != clientID {
+			return "", NewUnauthorizedError("user is not authorised to access medical data for this policy")
+		}
+	}
+
+	err = logAccessEvent(ctx, policyID, action, clientID, role)
+	if err != nil {
+		return "", NewLedgerError("log access event", err)
+	}
+
+	return role, nil
+}
+
+// /////////////////////////////////////////////////////
+// RETRIEVE THE ACCESS LOG OF A POLICY, OLDEST FIRST //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) GetAccessLog(ctx contractapi.TransactionContextInterface, policyID string) ([]*AccessLogEntry, error) {
+	// only insurers, admins and auditors can audit access to medical data
+	if err := assertRole(ctx, "insurer", "admin", "auditor"); err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey("access-log-collection", "accesslog", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read access log from private data collection", err)
+	}
+	defer iterator.Close()
+
+	entries := []*AccessLogEntry{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate access log", err)
+		}
+
+		var entry AccessLogEntry
+		if err := json.Unmarshal(result.Value, &entry); err != nil {
+			return nil, NewLedgerError("unmarshal access log entry", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	// keys are ordered by transaction ID, so sort by time for a chronological trail
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Timestamp != entries[j].Timestamp {
+			return entries[i].Timestamp < entries[j].Timestamp
+		}
+		return entries[i].TxID < entries[j].TxID
+	})
+
+	return entries, nil
+}
+
+// /////////////////////////////////////////////////////
+// PARSE AND VALIDATE THE COVERAGE TERMS OF A POLICY //
+// /////////////////////////////////////////////////////
+func parsePolicyTerms(coveragesJSON string, benefitsJSON string, exclusionsJSON string) ([]CoverageCategory, []string, []CoverageCategory, error) {
+	coverages, err := parseCoverageCategories("coverages", coveragesJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	benefits, err := parseStringList("benefits", benefitsJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	exclusions, err := parseCoverageCategories("exclusions", exclusionsJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := validatePolicyTerms(coverages, exclusions); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return coverages, benefits, exclusions, nil
+}
+
+// ////////////////////////////////////////////////
+// CHECK THAT NO COVERAGE TYPE IS ALSO EXCLUDED //
+// ////////////////////////////////////////////////
+func validatePolicyTerms(coverages []CoverageCategory, exclusions []CoverageCategory) error {
+	if err := normalizeCoverageCategories("coverages", coverages); err != nil {
+		return err
+	}
+	if err := normalizeCoverageCategories("exclusions", exclusions); err != nil {
+		return err
+	}
+
+	// a coverage type cannot be covered and excluded at the same time
+	for _, exclusion := range exclusions {
+		if findCoverageCategory(coverages, exclusion.Name) != nil {
+			return NewValidationError("exclusions", fmt.Sprintf("%q is listed as both a coverage and an exclusion", exclusion.Name))
+		}
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////
+// VALIDATE THE FIELDS OF A NEW POLICY //
+// ///////////////////////////////////////
+func validatePolicyInput(input *PolicyInput) error {
+	if strings.TrimSpace(input.PolicyID) == "" {
+		return NewValidationError("policyID", "policy ID must not be empty")
+	}
+
+	// dates are given as YYYY-MM-DD and the policy must end after it starts
+	if err := validatePolicyDates(input.StartDate, input.EndDate); err != nil {
+		return err
+	}
+
+	if err := checkAmount("sumAssured", input.SumAssured); err != nil {
+		return err
+	}
+
+	// co-pay is a percentage of each claim
+	if input.CoPay < 0 || input.CoPay > 100 {
+		return NewValidationError("coPay", fmt.Sprintf("invalid co-pay %d: must be between 0 and 100", input.CoPay))
+	}
+
+	if err := checkAmount("preAuthThreshold", input.PreAuthThreshold); err != nil {
+		return err
+	}
+
+	if input.WaitingPeriodDays < 0 || input.PreExistingWaitingDays < 0 {
+		return NewValidationError("waitingPeriodDays", "invalid waiting period: days must not be negative")
+	}
+
+	if err := validatePolicyTerms(input.Coverages, input.Exclusions); err != nil {
+		return err
+	}
+
+	if err := validateSubLimits(input.SubLimits); err != nil {
+		return err
+	}
+
+	if err := validateRoomRentLimit(input.RoomRentLimit); err != nil {
+		return err
+	}
+
+	if err := checkAmount("deductible", input.Deductible); err != nil {
+		return err
+	}
+
+	return validateCoInsurers(input.CoInsurers)
+}
+
+// /////////////////////////////////////////
+// PARSE A JSON-ENCODED ARRAY OF STRINGS //
+// /////////////////////////////////////////
+func parseStringList(field string, listJSON string) ([]string, error) {
+	list := []string{}
+	if strings.TrimSpace(listJSON) == "" {
+		return list, nil
+	}
+
+	if err := json.Unmarshal([]byte(listJSON), &list); err != nil {
+		return nil, NewValidationError(field, fmt.Sprintf("invalid %s, expected a JSON array of strings: %v", field, err))
+	}
+
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+
+	return list, nil
+}
+
+// //////////////////////////////////////////////////////////////
+// PARSE AND VALIDATE A JSON ARRAY OF SHA-256 DOCUMENT HASHES //
+// //////////////////////////////////////////////////////////////
+func parseDocumentHashes(documentHashesJSON string) ([]string, error) {
+	hashes, err := parseStringList("document hashes", documentHashesJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, hash := range hashes {
+		hashes[i], err = validateDocumentHash("documentHashes", hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+// //////////////////////////////////////////////////////////
+// PARSE AND VALIDATE A JSON ARRAY OF DOCUMENT REFERENCES //
+// //////////////////////////////////////////////////////////
+func parseDocumentRefs(documentsJSON string, uploadedBy string) ([]DocumentRef, error) {
+	documentRefs := []DocumentRef{}
+	if strings.TrimSpace(documentsJSON) == "" {
+		return documentRefs, nil
+	}
+
+	if err := json.Unmarshal([]byte(documentsJSON), &documentRefs); err != nil {
+		// clients written before document references still send a plain array of hashes
+		hashes, hashErr := parseDocumentHashes(documentsJSON)
+		if hashErr != nil {
+			return nil, NewValidationError("documents", fmt.Sprintf("invalid documents, expected a JSON array of {docType, sha256, uri} objects: %v", err))
+		}
+		for _, hash := range hashes {
+			documentRefs = append(documentRefs, DocumentRef{SHA256: hash})
+		}
+	}
+
+	for i := range documentRefs {
+		hash, err := validateDocumentHash("documents", strings.TrimSpace(documentRefs[i].SHA256))
+		if err != nil {
+			return nil, err
+		}
+		documentRefs[i].SHA256 = hash
+		documentRefs[i].DocType = strings.TrimSpace(documentRefs[i].DocType)
+		documentRefs[i].URI = strings.TrimSpace(documentRefs[i].URI)
+
+		// the uploader is always the submitting identity, never taken from the input
+		documentRefs[i].UploadedBy = uploadedBy
+	}
+
+	return documentRefs, nil
+}
+
+// ///////////////////////////////////////////////////////
+// VALIDATE A SINGLE SHA-256 DOCUMENT HASH, LOWERCASED //
+// ///////////////////////////////////////////////////////
+func validateDocumentHash(field string, hash string) (string, error) {
+	// a SHA-256 hash is 32 bytes, 64 hex characters
+	if len(hash) != 64 {
+		return "", NewValidationError(field, fmt.Sprintf("invalid document hash %q: must be 64 hex characters", hash))
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", NewValidationError(field, fmt.Sprintf("invalid document hash %q: %v", hash, err))
+	}
+
+	return strings.ToLower(hash), nil
+}
+
+// ////////////////////////////////////////////////////////
+// CHECK WHETHER A LIST CONTAINS A VALUE, IGNORING CASE //
+// ////////////////////////////////////////////////////////
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, strings.TrimSpace(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// //////////////////////////////////////////////////////////
+// PORTION OF A CLAIM COVERED BY THE INSURER AFTER CO-PAY //
+// //////////////////////////////////////////////////////////
+func insuredPortion(claimAmount int, coPay int) int {
+	return claimAmount * (100 - coPay) / 100
+}
+
+// ////////////////////////////////////////////
+// PARSE A POLICY DATE IN YYYY-MM-DD FORMAT //
+// ////////////////////////////////////////////
+func parsePolicyDate(field string, value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, NewValidationError(field, fmt.Sprintf("invalid %s %q, expected YYYY-MM-DD: %v", field, value, err))
+	}
+	if date.IsZero() {
+		return time.Time{}, NewValidationError(field, fmt.Sprintf("%s must not be the zero date", field))
+	}
+
+	return date, nil
+}
+
+// ////////////////////////////////////////////////////////
+// CANONICAL FORM OF A DATE ACCEPTED BY parsePolicyDate //
+// ////////////////////////////////////////////////////////
+func normalizePolicyDate(value string) string {
+	date, err := parsePolicyDate("date", value)
+	if err != nil {
+		return value
+	}
+
+	return date.Format("2006-01-02")
+}
+
+// ////////////////////////////////////////////////
+// VALIDATE THE START AND END DATES OF A POLICY //
+// ////////////////////////////////////////////////
+func validatePolicyDates(startDate string, endDate string) error {
+	start, err := parsePolicyDate("start date", startDate)
+	if err != nil {
+		return err
+	}
+
+	end, err := parsePolicyDate("end date", endDate)
+	if err != nil {
+		return err
+	}
+
+	if !end.After(start) {
+		return NewValidationError("endDate", fmt.Sprintf("end date %s must be after start date %s", endDate, startDate))
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////
+// CHECK THAT A POLICY IS IN FORCE AT THE GIVEN TIME //
+// /////////////////////////////////////////////////////
+func checkPolicyPeriod(policy *Policy, at time.Time) error {
+	start, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return err
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return err
+	}
+
+	if at.Before(start) {
+		return NewStateError(fmt.Sprintf("policy has not started yet, it starts on %s", policy.StartDate))
+	}
+
+	// the end date is inclusive, so the policy covers that whole day
+	if !at.Before(end.AddDate(0, 0, 1)) {
+		return NewStateError(fmt.Sprintf("policy expired on %s", policy.EndDate))
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////
+// ENSURE AN AGE ON A REFERENCE DATE IS WITHIN BOUNDS //
+// //////////////////////////////////////////////////////
+func validateAge(dob string, referenceDate string, min int, max int) error {
+	birth, err := time.Parse("2006-01-02", dob)
+	if err != nil {
+		return NewValidationError("dateOfBirth", fmt.Sprintf("invalid date of birth %q, expected YYYY-MM-DD: %v", dob, err))
+	}
+
+	reference, err := time.Parse("2006-01-02", referenceDate)
+	if err != nil {
+		return NewValidationError("referenceDate", fmt.Sprintf("invalid reference date %q, expected YYYY-MM-DD: %v", referenceDate, err))
+	}
+
+	if reference.Before(birth) {
+		return NewValidationError("dateOfBirth", fmt.Sprintf("date %s is before the date of birth %s", referenceDate, dob))
+	}
+
+	// completed years, one less if the birthday has not come yet that year
+	age := reference.Year() - birth.Year()
+	if reference.Month() < birth.Month() || (reference.Month() == birth.Month() && reference.Day() < birth.Day()) {
+		age--
+	}
+
+	if age < min {
+		return NewValidationError("dateOfBirth", fmt.Sprintf("age %d on %s is below the minimum insurable age of %d", age, referenceDate, min))
+	}
+	if age > max {
+		return NewValidationError("dateOfBirth", fmt.Sprintf("age %d on %s exceeds the maximum insurable age of %d", age, referenceDate, max))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////
+// ENSURE AN ADMISSION IS PAST THE POLICY'S WAITING PERIOD //
+// ///////////////////////////////////////////////////////////
+func checkWaitingPeriod(policy *Policy, medicalConditions []string, dateOfAdmission string, diagnosisCodes []string, coverageType string) error {
+	admissionDate, err := parsePolicyDate("date of admission", dateOfAdmission)
+	if err != nil {
+		return err
+	}
+
+	start, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return err
+	}
+
+	// claims related to a pre-existing condition can have a longer waiting period
+	waitingDays := policy.WaitingPeriodDays
+	if policy.PreExistingWaitingDays > waitingDays && isPreExistingCondition(medicalConditions, diagnosisCodes, coverageType) {
+		waitingDays = policy.PreExistingWaitingDays
+	}
+
+	waitingEnds := start.AddDate(0, 0, waitingDays)
+	if admissionDate.Before(waitingEnds) {
+		return NewValidationError("dateOfAdmission", fmt.Sprintf("admission on %s is within the %d-day waiting period of policy %s, claims are accepted for admissions from %s", dateOfAdmission, waitingDays, policy.PolicyID, waitingEnds.Format("2006-01-02")))
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////
+// REFUSE ADMISSIONS BEFORE THE POLICY STARTED OR AFTER IT ENDED //
+// /////////////////////////////////////////////////////////////////
+func checkAdmissionPeriod(policy *Policy, dateOfAdmission string) error {
+	admissionDate, err := parsePolicyDate("dateOfAdmission", dateOfAdmission)
+	if err != nil {
+		return err
+	}
+
+	start, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return err
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return err
+	}
+
+	// the end date is inclusive
+	if admissionDate.Before(start) || admissionDate.After(end) {
+		return NewPolicyPeriodError(policy, admissionDate.Format("2006-01-02"))
+	}
+
+	// a cancelled policy covers admissions up to and including its cancellation date
+	if policy.CancellationDate != "" && admissionDate.Format("2006-01-02") > policy.CancellationDate {
+		return NewValidationError("dateOfAdmission", fmt.Sprintf("admission on %s is after policy %s was cancelled from %s", dateOfAdmission, policy.PolicyID, policy.CancellationDate))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////
+// CHECK WHETHER A CLAIM RELATES TO A STORED MEDICAL CONDITION //
+// ///////////////////////////////////////////////////////////////
+func isPreExistingCondition(medicalConditions []string, diagnosisCodes []string, coverageType string) bool {
+	for _, condition := range medicalConditions {
+		if strings.EqualFold(coverageType, condition) {
+			return true
+		}
+
+		// conditions recorded as ICD-10 codes or categories match the claim's diagnoses
+		for _, code := range diagnosisCodes {
+			if matchesDiagnosisCode(code, condition) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ////////////////////////////////////////////////////////
+// RETRIEVE THE ROLE ATTRIBUTE FROM THE CLIENT IDENTITY //
+// ////////////////////////////////////////////////////////
+func getClientRole(ctx contractapi.TransactionContextInterface) (string, error) {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return "", NewLedgerError("get client role attribute", err)
+	}
+
+	if !found {
+		return "", NewUnauthorizedError("client role attribute not found")
+	}
+
+	return role, nil
+}
+
+// ////////////////////////////////////////////////
+// ENSURE THAT THE CLIENT HOLDS AN ALLOWED ROLE //
+// ////////////////////////////////////////////////
+func assertRole(ctx contractapi.TransactionContextInterface, allowedRoles ...string) error {
+	role, err := getClientRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, allowed := range allowedRoles {
+		if role == allowed {
+			return nil
+		}
+	}
+
+	return NewUnauthorizedError(fmt.Sprintf("unauthorized access: role %q is not permitted, requires one of %s", role, strings.Join(allowedRoles, ", ")))
+}
+
+// ////////////////////////////////////////////////
+// ENSURE THAT THE CLIENT OWNS THE GIVEN POLICY //
+// ////////////////////////////////////////////////
+func assertPolicyOwner(ctx contractapi.TransactionContextInterface, policy *Policy) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// compare certificate identities, the person name is not an identity
+	if policy.OwnerCertID != clientID {
+		return NewUnauthorizedError(fmt.Sprintf("user is not authorised to access policy %s", policy.PolicyID))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////
+// LOG ACCESS EVENTS FOR AUDITING PURPOSES //
+// ///////////////////////////////////////////
+func logAccessEvent(ctx contractapi.TransactionContextInterface, policyID string, action string, userID string, role string) error {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	accessLog := AccessLogEntry{
+		PolicyID:  policyID,
+		TxID:      txID,
+		Action:    action,
+		UserID:    userID,
+		Role:      role,
+		Timestamp: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	logJSON, err := json.Marshal(accessLog)
+	if err != nil {
+		return NewLedgerError("marshal access log", err)
+	}
+
+	// one entry per transaction, so earlier entries are never overwritten
+	logKey, err := ctx.GetStub().CreateCompositeKey("accesslog", []string{policyID, txID})
+	if err != nil {
+		return NewLedgerError("create access log key", err)
+	}
+
+	// store the log in a separate collection for auditing purposes
+	err = ctx.GetStub().PutPrivateData("access-log-collection", logKey, logJSON)
+	if err != nil {
+		return NewLedgerError("store access log", err)
+	}
+
+	return nil
+}
+
+// /////////////////
+// MAIN FUNCTION //
+// /////////////////
+func main() {
+	// create a new instance of the chaincode
+	chaincode, err := contractapi.NewChaincode(&HealthInsurance{})
+	if err != nil {
+		fmt.Printf("error creating health insurance chaincode: %v\n", err)
+		return
+	}
+
+	// start the chaincode server
+	if err := chaincode.Start(); err != nil {
+		fmt.Printf("error starting health insurance chaincode: %v\n", err)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A CLIENT IDENTITY WITH A FIXED ID, MSP AND ATTRIBUTES
+type mockClientIdentity struct {
+	id         string
+	mspID      string
+	attributes map[string]string
+}
+
+func (m *mockClientIdentity) GetID() (string, error) {
+	return m.id, nil
+}
+
+func (m *mockClientIdentity) GetMSPID() (string, error) {
+	return m.mspID, nil
+}
+
+func (m *mockClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := m.attributes[attrName]
+	return value, found, nil
+}
+
+func (m *mockClientIdentity) AssertAttributeValue(attrName string, attrValue string) error {
+	if value, found := m.attributes[attrName]; !found || value != attrValue {
+		return errors.New("attribute " + attrName + " does not match")
+	}
+	return nil
+}
+
+func (m *mockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// //////////////////////////////////////////////////////////////////////
+// A TRANSACTION CONTEXT ON A MOCK STUB, CALLED BY THE GIVEN IDENTITY //
+// //////////////////////////////////////////////////////////////////////
+func newTestContext(stub *shimtest.MockStub, id string, role string) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(&mockClientIdentity{
+		id:         id,
+		mspID:      defaultConfig().AllowedInsuranceMSP,
+		attributes: map[string]string{"role": role},
+	})
+	return ctx
+}
+
+// /////////////////////////////////////////////////////////////////
+// A MOCK STUB IN AN OPEN TRANSACTION, HOLDING ONE ACTIVE POLICY //
+// /////////////////////////////////////////////////////////////////
+func newPolicyStub(t *testing.T, policy *Policy) *shimtest.MockStub {
+	t.Helper()
+
+	stub := shimtest.NewMockStub("health_insurance", nil)
+	stub.MockTransactionStart("tx1")
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatalf("marshal policy: %v", err)
+	}
+	if err := stub.PutState(policy.PolicyID, policyJSON); err != nil {
+		t.Fatalf("store policy: %v", err)
+	}
+
+	return stub
+}
+
+func TestAuthorizeMedicalAccessAllowsOwner(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "owner", "patient")
+
+	role, err := new(HealthInsurance).authorizeMedicalAccess(ctx, "P1", "accessed medical conditions")
+	if err != nil {
+		t.Fatalf("owner was denied: %v", err)
+	}
+	if role != "patient" {
+		t.Errorf("role = %q, want patient", role)
+	}
+}
+
+func TestAuthorizeMedicalAccessDeniesOtherPatient(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "someone-else", "patient")
+
+	_, err := new(HealthInsurance).authorizeMedicalAccess(ctx, "P1", "accessed medical conditions")
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("err = %v, want an %s error", err, ErrCodeUnauthorized)
+	}
+}
+
+func TestAuthorizeMedicalAccessAllowsDoctor(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "doctor-1", "doctor")
+
+	role, err := new(HealthInsurance).authorizeMedicalAccess(ctx, "P1", "accessed medical conditions")
+	if err != nil {
+		t.Fatalf("doctor was denied: %v", err)
+	}
+	if role != "doctor" {
+		t.Errorf("role = %q, want doctor", role)
+	}
+}
+
+func TestUpdatePolicyRejectsStaleVersion(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{
+		ObjectType:  "policy",
+		PolicyID:    "P1",
+		OwnerCertID: "owner",
+		PersonName:  "Asha Rao",
+		SumAssured:  500000,
+		StartDate:   "2026-01-01",
+		EndDate:     "2026-12-31",
+		Status:      PolicyStatusActive,
+		Version:     3,
+	})
+	ctx := newTestContext(stub, "insurer-1", "insurer")
+	contract := new(HealthInsurance)
+
+	update := func(expectedVersion int) error {
+		return contract.UpdatePolicy(ctx, "P1", 600000, "Asha Rao", "1990-01-01", "F", "2026-01-01", "2026-12-31", 10, 0, "[]", "[]", "[]", "", 0, 0, expectedVersion, "sum assured raised")
+	}
+
+	// a caller that read version 2 has not seen the latest change
+	err := update(2)
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeConflict {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeConflict)
+	}
+
+	if err := update(3); err != nil {
+		t.Fatalf("update at the current version failed: %v", err)
+	}
+
+	policy, err := contract.GetPolicy(ctx, "P1")
+	if err != nil {
+		t.Fatalf("read policy: %v", err)
+	}
+	if policy.Version != 4 {
+		t.Errorf("version = %d, want 4", policy.Version)
+	}
+	if policy.SumAssured != 600000 {
+		t.Errorf("sum assured = %d, want 600000", policy.SumAssured)
+	}
+}
+
+func TestConsolidatePoliciesRejectsOtherOwner(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", PersonName: "Asha Rao", OwnerCertID: "owner", Status: PolicyStatusActive})
+	duplicateJSON, err := json.Marshal(&Policy{ObjectType: "policy", PolicyID: "P2", PersonName: "Asha Rao", OwnerCertID: "someone-else", Status: PolicyStatusActive})
+	if err != nil {
+		t.Fatalf("marshal policy: %v", err)
+	}
+	if err := stub.PutState("P2", duplicateJSON); err != nil {
+		t.Fatalf("store policy: %v", err)
+	}
+	ctx := newTestContext(stub, "admin-1", "admin")
+
+	// the same name on another holder's policy is not a duplicate
+	err = new(HealthInsurance).ConsolidatePolicies(ctx, "P1", "P2", true)
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+}
+
+func TestSplitPolicyKeepsCoverOnTheSource(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", PersonName: "Asha Rao", OwnerCertID: "owner", SumAssured: 500000, Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "insurer-1", "insurer")
+
+	// moving the whole sum assured would leave the source without cover
+	err := new(HealthInsurance).SplitPolicy(ctx, "P1", "P2", "Ravi Rao", "ravi", "1990-05-01", "M", 500000, "[]")
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+}
+
+func TestAutoRouteClaimSendsLargeClaimsToSeniors(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", SumAssured: 500000, Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "insurer-1", "insurer")
+	contract := new(HealthInsurance)
+
+	config := defaultConfig()
+	config.AutoRouteJuniorMax = 50000
+	config.AutoRouteSeniorMin = 200000
+	if err := putConfig(ctx, config); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	for _, adjuster := range []*Adjuster{
+		{AdjusterID: "A1", Tier: AdjusterTierJunior},
+		{AdjusterID: "A2", Tier: AdjusterTierSenior, ActiveClaimCount: 3},
+		{AdjusterID: "A3", Tier: AdjusterTierSenior, ActiveClaimCount: 1},
+	} {
+		if err := putAdjuster(ctx, adjuster); err != nil {
+			t.Fatalf("store adjuster: %v", err)
+		}
+	}
+	if err := putClaim(ctx, &Claim{ObjectType: "claim", ClaimID: "C1", PolicyID: "P1", ClaimAmount: 250000, Status: ClaimStatusSubmitted}); err != nil {
+		t.Fatalf("store claim: %v", err)
+	}
+	if err := indexClaimID(ctx, "C1", "P1"); err != nil {
+		t.Fatalf("index claim: %v", err)
+	}
+
+	if err := contract.AutoRouteClaim(ctx, "P1", "C1"); err != nil {
+		t.Fatalf("route claim: %v", err)
+	}
+
+	// the less loaded of the two seniors takes it
+	claim, err := getClaim(ctx, "C1")
+	if err != nil {
+		t.Fatalf("read claim: %v", err)
+	}
+	if claim.AssignedAdjusterID != "A3" {
+		t.Errorf("assigned adjuster = %q, want A3", claim.AssignedAdjusterID)
+	}
+	if claim.Status != ClaimStatusUnderReview {
+		t.Errorf("status = %s, want %s", claim.Status, ClaimStatusUnderReview)
+	}
+}
+
+func TestTokenPremiumPaymentWaitsForTheInsurer(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", Status: PolicyStatusActive})
+	ctx := newTestContext(stub, "owner", "patient")
+	insurerCtx := newTestContext(stub, "insurer-1", "insurer")
+	contract := new(HealthInsurance)
+
+	// one token is worth 100 minor units, one rupee
+	config := defaultConfig()
+	config.TokenRates = map[string]int{"e-INR": 100}
+	if err := putConfig(ctx, config); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+	for _, dueDate := range []string{"2026-01-01", "2026-07-01"} {
+		if err := putPremium(ctx, &Premium{ObjectType: "premium", PremiumID: "P1~" + dueDate, PolicyID: "P1", Amount: 250000, DueDate: dueDate, Status: "due"}); err != nil {
+			t.Fatalf("store premium: %v", err)
+		}
+	}
+
+	payment := TokenPayment{
+		TokenType:       "e-INR",
+		TransactionHash: "0x" + strings.Repeat("ab", 32),
+		NetworkID:       "cbdc-mainnet",
+		TokenAmount:     2500,
+	}
+	if err := contract.RecordTokenPremiumPayment(ctx, "P1", "P1~2026-01-01", payment); err != nil {
+		t.Fatalf("record payment: %v", err)
+	}
+
+	// the policyholder's own report does not pay the premium
+	premium, err := getPremium(ctx, "P1~2026-01-01")
+	if err != nil {
+		t.Fatalf("read premium: %v", err)
+	}
+	if premium.Status != "due" {
+		t.Errorf("premium status = %q, want due", premium.Status)
+	}
+
+	if err := contract.ConfirmTokenPremiumPayment(insurerCtx, "P1", "P1~2026-01-01"); err != nil {
+		t.Fatalf("confirm payment: %v", err)
+	}
+	premium, err = getPremium(ctx, "P1~2026-01-01")
+	if err != nil {
+		t.Fatalf("read premium: %v", err)
+	}
+	if premium.Status != "paid" || premium.PaidAmount != 250000 {
+		t.Errorf("premium = %+v, want paid with 250000 received", premium)
+	}
+
+	history, err := contract.GetTokenPaymentHistory(insurerCtx, "P1")
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != TokenPaymentConfirmed || history[0].ReviewedBy != "insurer-1" {
+		t.Fatalf("history = %+v, want one payment confirmed by insurer-1", history)
+	}
+
+	// the same transfer cannot pay a second premium
+	err = contract.RecordTokenPremiumPayment(ctx, "P1", "P1~2026-07-01", payment)
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeConflict {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeConflict)
+	}
+}
+
+func TestPayPremiumRecordsAmountReceived(t *testing.T) {
+	stub := newPolicyStub(t, &Policy{ObjectType: "policy", PolicyID: "P1", OwnerCertID: "owner", Status: PolicyStatusActive})
+	if err := putPremium(newTestContext(stub, "insurer-1", "insurer"), &Premium{ObjectType: "premium", PremiumID: "P1~2026-01-01", PolicyID: "P1", Amount: 250000, DueDate: "2026-01-01", Status: "due"}); err != nil {
+		t.Fatalf("store premium: %v", err)
+	}
+	contract := new(HealthInsurance)
+
+	// a policyholder cannot mark their own premium paid
+	err := contract.PayPremium(newTestContext(stub, "owner", "patient"), "P1~2026-01-01", 250000)
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeUnauthorized)
+	}
+
+	// nor can the insurer record less than the installment
+	ctx := newTestContext(stub, "insurer-1", "insurer")
+	err = contract.PayPremium(ctx, "P1~2026-01-01", 200000)
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+
+	if err := contract.PayPremium(ctx, "P1~2026-01-01", 260000); err != nil {
+		t.Fatalf("pay premium: %v", err)
+	}
+	premium, err := getPremium(ctx, "P1~2026-01-01")
+	if err != nil {
+		t.Fatalf("read premium: %v", err)
+	}
+	if premium.Status != "paid" || premium.PaidAmount != 260000 {
+		t.Errorf("premium = %+v, want paid with 260000 received", premium)
+	}
+}
+
+func TestParseAmountKeepsWholeUnitsOnOlderNetworks(t *testing.T) {
+	stub := shimtest.NewMockStub("health_insurance", nil)
+	stub.MockTransactionStart("tx1")
+	ctx := newTestContext(stub, "insurer-1", "insurer")
+	contract := new(HealthInsurance)
+
+	// a configuration stored before the amount unit was recorded
+	if err := stub.PutState(chaincodeConfigKey, []byte(`{"allowedInsuranceMSP":"InsuranceMSP","currency":"INR"}`)); err != nil {
+		t.Fatalf("store config: %v", err)
+	}
+
+	amount, err := contract.ParseAmount(ctx, "1234 INR")
+	if err != nil {
+		t.Fatalf("parse amount: %v", err)
+	}
+	if amount != 1234 {
+		t.Errorf("amount = %d, want 1234", amount)
+	}
+
+	// whole units cannot hold paisa
+	_, err = contract.ParseAmount(ctx, "1234.50")
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+
+	// nor can the unit be switched under the stored amounts
+	err = contract.InitLedger(ctx, `{"amountUnit":"minor"}`)
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+}
+
+func TestClaimRouterSpreadsClaimsOfOneTransaction(t *testing.T) {
+	stub := shimtest.NewMockStub("health_insurance", nil)
+	stub.MockTransactionStart("tx1")
+	ctx := newTestContext(stub, "hospital-1", "hospital")
+	contract := new(HealthInsurance)
+
+	for _, adjuster := range []*Adjuster{
+		{AdjusterID: "A1", Tier: AdjusterTierJunior},
+		{AdjusterID: "A2", Tier: AdjusterTierJunior},
+	} {
+		if err := putAdjuster(ctx, adjuster); err != nil {
+			t.Fatalf("store adjuster: %v", err)
+		}
+	}
+	config := defaultConfig()
+	config.AutoAssignClaims = true
+
+	router, err := contract.newClaimRouter(ctx, config)
+	if err != nil {
+		t.Fatalf("prepare router: %v", err)
+	}
+
+	// the first assignment counts against A1 before the second claim is routed
+	for i, want := range []string{"A1", "A2"} {
+		claim := &Claim{ObjectType: "claim", ClaimID: "C" + strconv.Itoa(i), PolicyID: "P" + strconv.Itoa(i), ClaimAmount: 10000, Status: ClaimStatusSubmitted}
+		adjuster, _, err := router.routeNewClaim(ctx, claim)
+		if err != nil {
+			t.Fatalf("route claim: %v", err)
+		}
+		if adjuster == nil || adjuster.AdjusterID != want {
+			t.Errorf("claim %s routed to %+v, want %s", claim.ClaimID, adjuster, want)
+		}
+	}
+}
+
+func TestInitLedgerRejectsPaymentChaincodeOnAnotherChannel(t *testing.T) {
+	stub := shimtest.NewMockStub("health_insurance", nil)
+	stub.ChannelID = "health"
+	stub.MockTransactionStart("tx1")
+	ctx := newTestContext(stub, "deployer", "admin")
+
+	// a transfer on the payments channel would run as a query and never commit
+	err := new(HealthInsurance).InitLedger(ctx, `{"paymentChaincodeName":"payment","paymentChannel":"payments"}`)
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) || contractErr.Code != ErrCodeInvalidInput {
+		t.Fatalf("err = %v, want a %s error", err, ErrCodeInvalidInput)
+	}
+}
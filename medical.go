@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR THE SENSITIVITY CLASSIFICATION OF A MEDICAL CONDITION
+type MedicalConditionClassification struct {
+	PolicyID         string `json:"policyID"`
+	Condition        string `json:"condition"`
+	SensitivityLevel string `json:"sensitivityLevel"` // standard/sensitive/highly_sensitive
+}
+
+// STRUCTURE FOR THE PRIVATE MEDICAL CONDITIONS OF A POLICYHOLDER
+type MedicalConditionsRecord struct {
+	Conditions  []string `json:"conditions"`  // every diagnosed condition, oldest first
+	LastUpdated string   `json:"lastUpdated"` // RFC3339, UTC
+}
+
+// //////////////////////////////////////////////////////////////
+// ADD A NEWLY DIAGNOSED CONDITION TO A POLICYHOLDER'S RECORD //
+// //////////////////////////////////////////////////////////////
+func (c *HealthInsurance) UpdateMedicalConditions(ctx contractapi.TransactionContextInterface, policyID string, newCondition string) error {
+	// only doctors can record diagnoses
+	if err := assertRole(ctx, "doctor", "senior_doctor"); err != nil {
+		return err
+	}
+
+	newCondition = strings.TrimSpace(newCondition)
+	if newCondition == "" || strings.Contains(newCondition, ",") {
+		return NewValidationError("condition", fmt.Sprintf("invalid condition %q: must be a single, non-empty condition", newCondition))
+	}
+
+	// the diagnosis must belong to an existing patient policy
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return err
+	}
+
+	record, err := getMedicalConditionsRecord(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &MedicalConditionsRecord{Conditions: []string{}}
+	}
+
+	// conditions are only ever appended, earlier diagnoses are kept
+	if containsFold(record.Conditions, newCondition) {
+		return NewConflictError(fmt.Sprintf("condition %q is already recorded for policy %s", newCondition, policyID))
+	}
+	record.Conditions = append(record.Conditions, newCondition)
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	record.LastUpdated = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	if err := putMedicalConditionsRecord(ctx, policyID, record); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	role, err := getClientRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	return logAccessEvent(ctx, policyID, "updated medical conditions", clientID, role)
+}
+
+// //////////////////////////////////////////////////////////////////
+// RETRIEVE EVERY RECORDED CONDITION THE CALLER IS CLEARED TO SEE //
+// //////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetMedicalConditionsHistory(ctx contractapi.TransactionContextInterface, policyID string) (*MedicalConditionsRecord, error) {
+	role, err := c.authorizeMedicalAccess(ctx, policyID, "accessed medical conditions history")
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := getMedicalConditionsRecord(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, NewNotFoundError("medical record", policyID)
+	}
+
+	// sensitivity classifications apply to the full list as well
+	record.Conditions, err = filterVisibleConditions(ctx, policyID, role, record.Conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// ////////////////////////////////////////////////////////////
+// CLASSIFY THE SENSITIVITY OF A POLICY'S MEDICAL CONDITION //
+// ////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ClassifyMedicalCondition(ctx contractapi.TransactionContextInterface, policyID string, condition string, sensitivityLevel string) error {
+	// only insurers and admins can classify medical data
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer", "admin"); err != nil {
+		return err
+	}
+
+	if sensitivityLevel != "standard" && sensitivityLevel != "sensitive" && sensitivityLevel != "highly_sensitive" {
+		return NewValidationError("sensitivityLevel", fmt.Sprintf("invalid sensitivity level %q: must be standard, sensitive or highly_sensitive", sensitivityLevel))
+	}
+
+	condition = strings.TrimSpace(condition)
+	if condition == "" {
+		return NewValidationError("condition", "condition must not be empty")
+	}
+
+	// the policy must exist before its conditions can be classified
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return err
+	}
+
+	classificationKey, err := conditionClassificationKey(ctx, policyID, condition)
+	if err != nil {
+		return err
+	}
+
+	classificationJSON, err := json.Marshal(MedicalConditionClassification{
+		PolicyID:         policyID,
+		Condition:        condition,
+		SensitivityLevel: sensitivityLevel,
+	})
+	if err != nil {
+		return NewLedgerError("marshal classification", err)
+	}
+
+	// the classification lives next to the conditions it describes
+	if err := ctx.GetStub().PutPrivateData("medical-conditions-collection", classificationKey, classificationJSON); err != nil {
+		return NewLedgerError("store classification", err)
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////
+// READ THE SENSITIVITY OF A CONDITION, STANDARD IF UNCLASSIFIED //
+// /////////////////////////////////////////////////////////////////
+func getConditionSensitivity(ctx contractapi.TransactionContextInterface, policyID string, condition string) (string, error) {
+	classificationKey, err := conditionClassificationKey(ctx, policyID, condition)
+	if err != nil {
+		return "", err
+	}
+
+	classificationJSON, err := ctx.GetStub().GetPrivateData("medical-conditions-collection", classificationKey)
+	if err != nil {
+		return "", NewLedgerError("read from private data collection", err)
+	}
+	if classificationJSON == nil {
+		return "standard", nil
+	}
+
+	var classification MedicalConditionClassification
+	if err := json.Unmarshal(classificationJSON, &classification); err != nil {
+		return "", NewLedgerError("unmarshal classification", err)
+	}
+
+	return classification.SensitivityLevel, nil
+}
+
+// //////////////////////////////////////////////////////////////////
+// READ THE MEDICAL CONDITIONS OF A POLICY, NIL IF THERE ARE NONE //
+// //////////////////////////////////////////////////////////////////
+func getMedicalConditionsRecord(ctx contractapi.TransactionContextInterface, policyID string) (*MedicalConditionsRecord, error) {
+	recordJSON, err := ctx.GetStub().GetPrivateData("medical-conditions-collection", policyID)
+	if err != nil {
+		return nil, NewLedgerError("read from private data collection", err)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+
+	// older policies stored the conditions as a single comma-separated string
+	var stored struct {
+		MedicalConditionsRecord
+		LegacyConditions string `json:"medicalConditions"`
+	}
+	if err := json.Unmarshal(recordJSON, &stored); err != nil {
+		return nil, NewLedgerError("unmarshal private data", err)
+	}
+
+	record := stored.MedicalConditionsRecord
+	if record.Conditions == nil {
+		record.Conditions = splitConditions(stored.LegacyConditions)
+	}
+
+	return &record, nil
+}
+
+// //////////////////////////////////////////////////////////////////////
+// STORE THE MEDICAL CONDITIONS OF A POLICY IN THE PRIVATE COLLECTION //
+// //////////////////////////////////////////////////////////////////////
+func putMedicalConditionsRecord(ctx contractapi.TransactionContextInterface, policyID string, record *MedicalConditionsRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return NewLedgerError("marshal sensitive data", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData("medical-conditions-collection", policyID, recordJSON); err != nil {
+		return NewLedgerError("store sensitive data", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////
+// SPLIT A COMMA-SEPARATED LIST OF CONDITIONS //
+// //////////////////////////////////////////////
+func splitConditions(medicalConditions string) []string {
+	conditions := []string{}
+	for _, condition := range strings.Split(medicalConditions, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition != "" {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	return conditions
+}
+
+// /////////////////////////////////////////////////////
+// KEEP ONLY THE CONDITIONS A ROLE IS CLEARED TO SEE //
+// /////////////////////////////////////////////////////
+func filterVisibleConditions(ctx contractapi.TransactionContextInterface, policyID string, role string, conditions []string) ([]string, error) {
+	visibleConditions := []string{}
+	for _, condition := range conditions {
+		sensitivityLevel, err := getConditionSensitivity(ctx, policyID, condition)
+		if err != nil {
+			return nil, err
+		}
+
+		if canAccessSensitivity(role, sensitivityLevel) {
+			visibleConditions = append(visibleConditions, condition)
+		}
+	}
+
+	return visibleConditions, nil
+}
+
+// ///////////////////////////////////////////////////////////
+// CHECK WHETHER A ROLE IS CLEARED FOR A SENSITIVITY LEVEL //
+// ///////////////////////////////////////////////////////////
+func canAccessSensitivity(role string, sensitivityLevel string) bool {
+	switch sensitivityLevel {
+	case "highly_sensitive":
+		// e.g. HIV or psychiatric conditions
+		return role == "senior_doctor" || role == "patient"
+	case "sensitive":
+		return role == "doctor" || role == "senior_doctor" || role == "patient"
+	default:
+		return true
+	}
+}
+
+// ///////////////////////////////////////////////////////////////
+// BUILD THE PRIVATE DATA KEY FOR A CONDITION'S CLASSIFICATION //
+// ///////////////////////////////////////////////////////////////
+func conditionClassificationKey(ctx contractapi.TransactionContextInterface, policyID string, condition string) (string, error) {
+	// conditions are matched case-insensitively
+	key, err := ctx.GetStub().CreateCompositeKey("condition", []string{policyID, strings.ToLower(strings.TrimSpace(condition))})
+	if err != nil {
+		return "", NewLedgerError("create classification key", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A NOMINEE WHO RECEIVES A SHARE OF THE POLICY BENEFIT
+type Nominee struct {
+	ObjectType   string `json:"docType"`
+	NomineeID    string `json:"nomineeID"`
+	PolicyID     string `json:"policyID"`
+	NomineeName  string `json:"nomineeName"`
+	Relationship string `json:"relationship"`
+	DateOfBirth  string `json:"dateOfBirth"`
+	ContactInfo  string `json:"contactInfo"`
+	Percentage   int    `json:"percentage"` // share of the benefit, all nominees together make up 100
+}
+
+// /////////////////////////////
+// ADD A NOMINEE TO A POLICY //
+// /////////////////////////////
+func (c *HealthInsurance) AddNominee(ctx contractapi.TransactionContextInterface, policyID string, nomineeName string, relationship string, dob string, contact string, percentage int) (string, error) {
+	if err := assertRole(ctx, "patient", "insurer"); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(nomineeName) == "" {
+		return "", NewValidationError("name", "nominee name must not be empty")
+	}
+
+	if _, err := parsePolicyDate("nominee date of birth", dob); err != nil {
+		return "", err
+	}
+
+	if percentage <= 0 || percentage > 100 {
+		return "", NewValidationError("percentage", fmt.Sprintf("invalid percentage %d: must be between 1 and 100", percentage))
+	}
+
+	// nominees can only be added to existing policies
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return "", err
+	}
+
+	nominees, err := c.GetNomineesForPolicy(ctx, policyID)
+	if err != nil {
+		return "", err
+	}
+
+	// the shares of all nominees may never exceed the whole benefit
+	total := percentage
+	for _, nominee := range nominees {
+		total += nominee.Percentage
+	}
+	if total > 100 {
+		return "", NewValidationError("percentage", fmt.Sprintf("adding %d%% would bring the nominee total for policy %s to %d%%, it must not exceed 100%%", percentage, policyID, total))
+	}
+
+	nomineeID := ctx.GetStub().GetTxID()
+	nominee := &Nominee{
+		ObjectType:   "nominee",
+		NomineeID:    nomineeID,
+		PolicyID:     policyID,
+		NomineeName:  nomineeName,
+		Relationship: relationship,
+		DateOfBirth:  dob,
+		ContactInfo:  contact,
+		Percentage:   percentage,
+	}
+
+	if err := putNominee(ctx, nominee); err != nil {
+		return "", err
+	}
+
+	// index the nominee ID so it can be removed without knowing its policy
+	nomineeIndexKey, err := ctx.GetStub().CreateCompositeKey("nomineeid", []string{nomineeID})
+	if err != nil {
+		return "", NewLedgerError("create nominee index key", err)
+	}
+
+	if err := setChaincodeEvent(ctx, "NomineeAdded", policyID, ""); err != nil {
+		return "", err
+	}
+
+	if err := ctx.GetStub().PutState(nomineeIndexKey, []byte(policyID)); err != nil {
+		return "", NewLedgerError("store nominee index", err)
+	}
+
+	return nomineeID, nil
+}
+
+// //////////////////////////////////
+// REMOVE A NOMINEE FROM A POLICY //
+// //////////////////////////////////
+func (c *HealthInsurance) RemoveNominee(ctx contractapi.TransactionContextInterface, nomineeID string) error {
+	if err := assertRole(ctx, "patient", "insurer"); err != nil {
+		return err
+	}
+
+	nomineeIndexKey, err := ctx.GetStub().CreateCompositeKey("nomineeid", []string{nomineeID})
+	if err != nil {
+		return NewLedgerError("create nominee index key", err)
+	}
+
+	policyID, err := ctx.GetStub().GetState(nomineeIndexKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+	if policyID == nil {
+		return NewNotFoundError("nominee", nomineeID)
+	}
+
+	nomineeKey, err := ctx.GetStub().CreateCompositeKey("nominee", []string{string(policyID), nomineeID})
+	if err != nil {
+		return NewLedgerError("create nominee key", err)
+	}
+
+	if err := setChaincodeEvent(ctx, "NomineeRemoved", string(policyID), ""); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().DelState(nomineeKey); err != nil {
+		return NewLedgerError("delete nominee", err)
+	}
+
+	if err := ctx.GetStub().DelState(nomineeIndexKey); err != nil {
+		return NewLedgerError("delete nominee index", err)
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////
+// RETRIEVE ALL NOMINEES FOR A POLICY //
+// //////////////////////////////////////
+func (c *HealthInsurance) GetNomineesForPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*Nominee, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("nominee", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read nominees from world state", err)
+	}
+	defer iterator.Close()
+
+	nominees := []*Nominee{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate nominees", err)
+		}
+
+		var nominee Nominee
+		if err := json.Unmarshal(result.Value, &nominee); err != nil {
+			return nil, NewLedgerError("unmarshal nominee", err)
+		}
+		nominees = append(nominees, &nominee)
+	}
+
+	return nominees, nil
+}
+
+// //////////////////////////////////////
+// STORE A NOMINEE IN THE WORLD STATE //
+// //////////////////////////////////////
+func putNominee(ctx contractapi.TransactionContextInterface, nominee *Nominee) error {
+	nomineeKey, err := ctx.GetStub().CreateCompositeKey("nominee", []string{nominee.PolicyID, nominee.NomineeID})
+	if err != nil {
+		return NewLedgerError("create nominee key", err)
+	}
+
+	nomineeJSON, err := json.Marshal(nominee)
+	if err != nil {
+		return NewLedgerError("marshal nominee", err)
+	}
+
+	if err := ctx.GetStub().PutState(nomineeKey, nomineeJSON); err != nil {
+		return NewLedgerError("store nominee", err)
+	}
+
+	return nil
+}
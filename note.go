@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A NOTE EXCHANGED ON A CLAIM
+type ClaimNote struct {
+	NoteID     string `json:"noteID"`
+	ClaimID    string `json:"claimID"`
+	Text       string `json:"text"`
+	Visibility string `json:"visibility"` // internal notes are only shown to insurers, public ones to every party
+	AuthorID   string `json:"authorID"`   // client ID of the author
+	AuthorRole string `json:"authorRole,omitempty"`
+	CreatedAt  string `json:"createdAt"` // RFC3339, UTC
+}
+
+// ///////////////////////////////////////
+// ADD A NOTE TO THE THREAD OF A CLAIM //
+// ///////////////////////////////////////
+func (c *HealthInsurance) AddClaimNote(ctx contractapi.TransactionContextInterface, claimID string, text string, visibility string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", NewValidationError("text", "note text must not be empty")
+	}
+	if visibility != "internal" && visibility != "public" {
+		return "", NewValidationError("visibility", fmt.Sprintf("invalid visibility %q: must be internal or public", visibility))
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return "", err
+	}
+
+	insurer, err := c.canReadInternalNotes(ctx, claim)
+	if err != nil {
+		return "", err
+	}
+	if visibility == "internal" && !insurer {
+		return "", NewUnauthorizedError("only insurers can add internal notes")
+	}
+
+	authorID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	// hospitals may not carry a role attribute, so a missing one is not an error here
+	authorRole, _, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return "", NewLedgerError("get client role attribute", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", NewLedgerError("get transaction timestamp", err)
+	}
+
+	note := ClaimNote{
+		NoteID:     ctx.GetStub().GetTxID(),
+		ClaimID:    claimID,
+		Text:       text,
+		Visibility: visibility,
+		AuthorID:   authorID,
+		AuthorRole: authorRole,
+		CreatedAt:  txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	noteJSON, err := json.Marshal(note)
+	if err != nil {
+		return "", NewLedgerError("marshal claim note", err)
+	}
+
+	// one key per transaction, so notes are only ever appended
+	noteKey, err := ctx.GetStub().CreateCompositeKey("claimnote", []string{claimID, note.NoteID})
+	if err != nil {
+		return "", NewLedgerError("create claim note key", err)
+	}
+
+	if err := ctx.GetStub().PutState(noteKey, noteJSON); err != nil {
+		return "", NewLedgerError("store claim note", err)
+	}
+
+	if err := setChaincodeEvent(ctx, "ClaimNoteAdded", claim.PolicyID, claimID); err != nil {
+		return "", err
+	}
+
+	return note.NoteID, nil
+}
+
+// //////////////////////////////////////////////////////////
+// RETRIEVE THE NOTES ON A CLAIM THAT THE CALLER MAY READ //
+// //////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetClaimNotes(ctx contractapi.TransactionContextInterface, claimID string) ([]*ClaimNote, error) {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return nil, err
+	}
+
+	insurer, err := c.canReadInternalNotes(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("claimnote", []string{claimID})
+	if err != nil {
+		return nil, NewLedgerError("read claim notes from world state", err)
+	}
+	defer iterator.Close()
+
+	notes := []*ClaimNote{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claim notes", err)
+		}
+
+		var note ClaimNote
+		if err := json.Unmarshal(result.Value, &note); err != nil {
+			return nil, NewLedgerError("unmarshal claim note", err)
+		}
+
+		// internal notes stay between the insurer's staff
+		if note.Visibility == "internal" && !insurer {
+			continue
+		}
+		notes = append(notes, &note)
+	}
+
+	// oldest first, ties broken by ID so the order is deterministic
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].CreatedAt != notes[j].CreatedAt {
+			return notes[i].CreatedAt < notes[j].CreatedAt
+		}
+		return notes[i].NoteID < notes[j].NoteID
+	})
+
+	return notes, nil
+}
+
+// //////////////////////////////////////////////////////////////////////////
+// ENSURE THE CLIENT TAKES PART IN THE CLAIM, TRUE IF THEY ARE AN INSURER //
+// //////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) canReadInternalNotes(ctx contractapi.TransactionContextInterface, claim *Claim) (bool, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	if requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer") == nil {
+		return true, nil
+	}
+
+	// hospitals follow up on the claims filed with them
+	if assertMSP(ctx, config.AllowedHospitalMSP) == nil {
+		return false, nil
+	}
+
+	// everyone else must hold the policy the claim was filed against
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return false, err
+	}
+	if err := assertPolicyOwner(ctx, policy); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
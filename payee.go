@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// private collection of the insurer organisation holding the bank details reimbursements are paid to
+const payeeCollection = "payee-collection"
+
+// STRUCTURE FOR THE BANK ACCOUNT A REIMBURSEMENT CLAIM IS PAID TO
+type PayeeDetails struct {
+	ClaimID       string `json:"claimID"`
+	PolicyID      string `json:"policyID"`
+	AccountHolder string `json:"accountHolder"`
+	AccountNumber string `json:"accountNumber"`
+	BankCode      string `json:"bankCode"` // IFSC or routing code of the branch
+}
+
+// //////////////////////////////////////////////////////
+// RETRIEVE THE BANK DETAILS OF A REIMBURSEMENT CLAIM //
+// //////////////////////////////////////////////////////
+func (c *HealthInsurance) GetPayeeDetails(ctx contractapi.TransactionContextInterface, claimID string) (*PayeeDetails, error) {
+	// bank details are only seen by the insurer that makes the transfer
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, err
+	}
+
+	payee, err := getPayeeDetails(ctx, claimID)
+	if err != nil {
+		return nil, err
+	}
+	if payee == nil {
+		return nil, NewNotFoundError("payee details", claimID)
+	}
+
+	return payee, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////
+// READ THE PAYEE BANK DETAILS FROM THE "payeeBankDetails" TRANSIENT FIELD //
+// ///////////////////////////////////////////////////////////////////////////
+func readPayeeDetails(ctx contractapi.TransactionContextInterface) (*PayeeDetails, error) {
+	// bank details never go into the transaction arguments, which every peer records
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, NewLedgerError("get transient data", err)
+	}
+
+	payeeJSON, ok := transientMap["payeeBankDetails"]
+	if !ok || len(payeeJSON) == 0 {
+		return nil, NewValidationError("payeeBankDetails", "reimbursement claims require payee bank details in the payeeBankDetails transient field")
+	}
+
+	var payee PayeeDetails
+	if err := json.Unmarshal(payeeJSON, &payee); err != nil {
+		return nil, NewValidationError("payeeBankDetails", fmt.Sprintf("invalid payee bank details, expected a {accountHolder, accountNumber, bankCode} object: %v", err))
+	}
+
+	payee.AccountHolder = strings.TrimSpace(payee.AccountHolder)
+	payee.AccountNumber = strings.TrimSpace(payee.AccountNumber)
+	payee.BankCode = strings.ToUpper(strings.TrimSpace(payee.BankCode))
+	if payee.AccountHolder == "" || payee.AccountNumber == "" || payee.BankCode == "" {
+		return nil, NewValidationError("payeeBankDetails", "account holder, account number and bank code must not be empty")
+	}
+
+	return &payee, nil
+}
+
+// ///////////////////////////////////////////////////////////////
+// CHECK A REIMBURSEMENT CLAIM CARRIES EVERY REQUIRED DOCUMENT //
+// ///////////////////////////////////////////////////////////////
+func checkDocumentChecklist(config *ChaincodeConfig, documentRefs []DocumentRef) error {
+	missing := []string{}
+	for _, required := range config.ReimbursementDocuments {
+		found := false
+		for _, documentRef := range documentRefs {
+			if strings.EqualFold(documentRef.DocType, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+
+	if len(missing) > 0 {
+		return NewValidationError("documents", fmt.Sprintf("reimbursement claims require documents of type %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////
+// READ THE PAYEE DETAILS OF A CLAIM, NIL IF NONE ARE STORED //
+// /////////////////////////////////////////////////////////////
+func getPayeeDetails(ctx contractapi.TransactionContextInterface, claimID string) (*PayeeDetails, error) {
+	payeeKey, err := ctx.GetStub().CreateCompositeKey("payee", []string{claimID})
+	if err != nil {
+		return nil, NewLedgerError("create payee key", err)
+	}
+
+	payeeJSON, err := ctx.GetStub().GetPrivateData(payeeCollection, payeeKey)
+	if err != nil {
+		return nil, NewLedgerError("read from private data collection", err)
+	}
+	if payeeJSON == nil {
+		return nil, nil
+	}
+
+	var payee PayeeDetails
+	if err := json.Unmarshal(payeeJSON, &payee); err != nil {
+		return nil, NewLedgerError("unmarshal payee details", err)
+	}
+
+	return &payee, nil
+}
+
+// //////////////////////////////////////////////////////
+// STORE PAYEE DETAILS IN THE PRIVATE DATA COLLECTION //
+// //////////////////////////////////////////////////////
+func putPayeeDetails(ctx contractapi.TransactionContextInterface, payee *PayeeDetails) error {
+	payeeKey, err := ctx.GetStub().CreateCompositeKey("payee", []string{payee.ClaimID})
+	if err != nil {
+		return NewLedgerError("create payee key", err)
+	}
+
+	payeeJSON, err := json.Marshal(payee)
+	if err != nil {
+		return NewLedgerError("marshal payee details", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData(payeeCollection, payeeKey, payeeJSON); err != nil {
+		return NewLedgerError("store payee details", err)
+	}
+
+	return nil
+}
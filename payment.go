@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// account the insurer pays approved claims from on the payment chaincode
+const insurerPaymentAccount = "insurer-account"
+
+// STRUCTURE FOR A FUND TRANSFER REQUEST SENT TO THE PAYMENT CHAINCODE
+type PaymentInstruction struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    int    `json:"amount"`
+	Reference string `json:"reference"` // claim ID the payment settles
+}
+
+// ///////////////////////////////////////////////////////////////////
+// VALIDATE A CLAIM APPROVAL WITHOUT CALLING THE PAYMENT CHAINCODE //
+// ///////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) DryRunApproveClaim(ctx contractapi.TransactionContextInterface, claimID string, approvedAmount int) ([]PaymentInstruction, error) {
+	claim, _, err := c.getDecidableClaim(ctx, claimID, ClaimStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return nil, err
+	}
+	applyRoomRentLimit(policy, claim)
+
+	// without an amount, the claim is approved for everything the policy pays
+	if approvedAmount == 0 {
+		approvedAmount = claim.ClaimAmount - claim.RoomRentDeduction
+	}
+
+	if err := validateApprovedAmount(claim, approvedAmount); err != nil {
+		return nil, err
+	}
+
+	breakdown, _, err := computePayout(ctx, policy, claim, approvedAmount)
+	if err != nil {
+		return nil, err
+	}
+	claim.ApprovedAmount = breakdown.PayableAmount
+
+	// reimbursements are paid by bank transfer and cashless claims with their settlement batch,
+	// so approving either requests no payment
+	if claim.ClaimType == ClaimTypeReimbursement || claim.ClaimType == ClaimTypeCashless {
+		return []PaymentInstruction{}, nil
+	}
+
+	// return the payments that a real approval would request
+	return c.buildPaymentInstructions(ctx, claim)
+}
+
+// ////////////////////////////////////////////////////
+// BUILD THE PAYMENTS THAT SETTLE AN APPROVED CLAIM //
+// ////////////////////////////////////////////////////
+func (c *HealthInsurance) buildPaymentInstructions(ctx contractapi.TransactionContextInterface, claim *Claim) ([]PaymentInstruction, error) {
+	policy, err := c.GetPolicy(ctx, claim.PolicyID)
+	if err != nil {
+		return nil, err
+	}
+
+	payee, err := claimPayee(policy, claim)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policy.CoInsurers) == 0 {
+		return []PaymentInstruction{{
+			From:      insurerPaymentAccount,
+			To:        payee,
+			Amount:    payableAmount(claim),
+			Reference: claim.ClaimID,
+		}}, nil
+	}
+
+	// each co-insurer pays its own share, from the account named after its MSP
+	shares, err := splitClaimAmount(policy, payableAmount(claim))
+	if err != nil {
+		return nil, err
+	}
+
+	payments := []PaymentInstruction{}
+	for _, share := range shares {
+		payments = append(payments, PaymentInstruction{
+			From:      share.MSPID,
+			To:        payee,
+			Amount:    share.Amount,
+			Reference: claim.ClaimID,
+		})
+	}
+
+	return payments, nil
+}
+
+// ///////////////////////////////////////////////////////////////
+// THE ACCOUNT A CLAIM IS PAID TO, CHOSEN BY THE TYPE OF CLAIM //
+// ///////////////////////////////////////////////////////////////
+func claimPayee(policy *Policy, claim *Claim) (string, error) {
+	// a cashless claim is paid to the network hospital that treated the patient, on the account named after it
+	if claim.ClaimType == ClaimTypeCashless {
+		if claim.HospitalID == "" {
+			return "", NewStateError(fmt.Sprintf("cashless claim %s has no network hospital to be paid", claim.ClaimID))
+		}
+		return claim.HospitalID, nil
+	}
+
+	// reimbursements and claims filed before claim types go to the policyholder
+	return policy.OwnerCertID, nil
+}
+
+// ///////////////////////////////////////////////////////////
+// TRANSFER THE CLAIM AMOUNT THROUGH THE PAYMENT CHAINCODE //
+// ///////////////////////////////////////////////////////////
+func (c *HealthInsurance) settleClaimPayment(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig, claim *Claim) error {
+	// without a configured payment chaincode, claims are settled off-chain
+	if config.PaymentChaincodeName == "" {
+		return nil
+	}
+	if err := checkPaymentChannel(ctx, config); err != nil {
+		return err
+	}
+
+	payments, err := c.buildPaymentInstructions(ctx, claim)
+	if err != nil {
+		return err
+	}
+
+	// a failed payment fails the whole transaction, so the claim is not approved or settled either
+	for _, payment := range payments {
+		paymentJSON, err := json.Marshal(payment)
+		if err != nil {
+			return NewLedgerError("marshal payment instruction", err)
+		}
+
+		response := ctx.GetStub().InvokeChaincode(config.PaymentChaincodeName, [][]byte{[]byte("Transfer"), paymentJSON}, config.PaymentChannel)
+		if response.Status != shim.OK {
+			return NewLedgerError("settle payment for claim "+claim.ClaimID+" from "+payment.From, errors.New(response.Message))
+		}
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////
+// ENSURE THE PAYMENT CHAINCODE IS ON THIS CHANNEL, SO ITS TRANSFERS COMMIT //
+// ////////////////////////////////////////////////////////////////////////////
+func checkPaymentChannel(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig) error {
+	// fabric runs a chaincode on another channel as a query, so a transfer there would be silently dropped
+	channelID := ctx.GetStub().GetChannelID()
+	if config.PaymentChannel != "" && config.PaymentChannel != channelID {
+		return NewValidationError("paymentChannel", fmt.Sprintf("payment channel %q is not this channel %q, payments made there would not be committed", config.PaymentChannel, channelID))
+	}
+
+	return nil
+}
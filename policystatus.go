@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// statuses a policy moves through, from issue to the end of its life
+const (
+	PolicyStatusDraft        = "DRAFT" // issued but not yet in force, see ActivatePolicy
+	PolicyStatusActive       = "ACTIVE"
+	PolicyStatusLapsePending = "LAPSE_PENDING" // overdue or past its end date, in the grace period, see LapseOverduePolicies
+	PolicyStatusLapsed       = "LAPSED"        // grace period ran out, revived within its term by ActivatePolicy once premiums are paid
+	PolicyStatusSuspended    = "SUSPENDED"     // held by the insurer, see ReinstatePolicy
+	PolicyStatusCancelled    = "CANCELLED"
+	PolicyStatusExpired      = "EXPIRED" // past its end date, can still be renewed or ported
+	PolicyStatusPorted       = "PORTED"  // moved to a policy with another insurer, see PortPolicy
+)
+
+// returned, wrapped, when a policy cannot move to the requested status
+var ErrInvalidPolicyTransition = errors.New("invalid policy status transition")
+
+// statuses each policy status may move to, final statuses have none
+var policyTransitions = map[string][]string{
+	PolicyStatusDraft:        {PolicyStatusActive, PolicyStatusCancelled},
+	PolicyStatusActive:       {PolicyStatusLapsePending, PolicyStatusLapsed, PolicyStatusSuspended, PolicyStatusCancelled, PolicyStatusExpired, PolicyStatusPorted},
+	PolicyStatusLapsePending: {PolicyStatusActive, PolicyStatusLapsed, PolicyStatusSuspended, PolicyStatusCancelled, PolicyStatusExpired}, // back in force once paid or renewed
+	PolicyStatusLapsed:       {PolicyStatusActive, PolicyStatusCancelled, PolicyStatusExpired},
+	PolicyStatusSuspended:    {PolicyStatusActive, PolicyStatusCancelled, PolicyStatusExpired},
+	PolicyStatusExpired:      {PolicyStatusActive, PolicyStatusCancelled, PolicyStatusPorted}, // a renewal puts it back in force
+	PolicyStatusCancelled:    {},
+	PolicyStatusPorted:       {},
+}
+
+// lowercase statuses written before the state machine, by their current name
+var legacyPolicyStatuses = map[string]string{
+	"active":    PolicyStatusActive,
+	"suspended": PolicyStatusSuspended,
+	"cancelled": PolicyStatusCancelled,
+	"expired":   PolicyStatusExpired,
+	"ported":    PolicyStatusPorted,
+}
+
+// ///////////////////////////////////////////////////////////
+// CHECK THAT A POLICY MAY MOVE FROM ITS STATUS TO ANOTHER //
+// ///////////////////////////////////////////////////////////
+func validatePolicyTransition(policy *Policy, status string) error {
+	for _, allowed := range policyTransitions[policy.Status] {
+		if allowed == status {
+			return nil
+		}
+	}
+
+	return &ContractError{
+		Code:    ErrCodeInvalidState,
+		Message: fmt.Sprintf("cannot move policy %s from %s to %s", policy.PolicyID, policy.Status, status),
+		Details: map[string]string{"from": policy.Status, "to": status},
+		cause:   ErrInvalidPolicyTransition,
+	}
+}
+
+// /////////////////////////////////////////////////////
+// MOVE A POLICY TO A NEW STATUS, IF THAT IS ALLOWED //
+// /////////////////////////////////////////////////////
+func transitionPolicy(policy *Policy, status string) error {
+	if err := validatePolicyTransition(policy, status); err != nil {
+		return err
+	}
+
+	policy.Status = status
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////////
+// READ A POLICY, RENAMING STATUSES WRITTEN BEFORE THE STATE MACHINE //
+// /////////////////////////////////////////////////////////////////////
+func (policy *Policy) UnmarshalJSON(data []byte) error {
+	// the alias has the fields but not this method, so decoding it does not recurse
+	type policyAlias Policy
+	var alias policyAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	*policy = Policy(alias)
+	if status, ok := legacyPolicyStatuses[policy.Status]; ok {
+		policy.Status = status
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////
+// PUT A DRAFT POLICY IN FORCE, OR REVIVE A LAPSED ONE ONCE PAID //
+// /////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ActivatePolicy(ctx contractapi.TransactionContextInterface, policyID string) error {
+	// only insurers can put policies in force
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	// suspended and expired policies come back through ReinstatePolicy and RenewPolicy
+	if policy.Status != PolicyStatusDraft && policy.Status != PolicyStatusLapsed {
+		return NewStateError(fmt.Sprintf("cannot activate policy %s, current status is %q", policyID, policy.Status))
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// a lapsed policy is only revived within its term, once every premium that has fallen due is paid
+	if policy.Status == PolicyStatusLapsed {
+		if err := checkPolicyPeriod(policy, txTimestamp.AsTime()); err != nil {
+			return err
+		}
+
+		unpaid, err := getUnpaidPremiums(ctx, policyID, txTimestamp.AsTime())
+		if err != nil {
+			return err
+		}
+		if len(unpaid) > 0 {
+			return NewStateError(fmt.Sprintf("cannot activate policy %s, premiums due on %s are unpaid", policyID, strings.Join(unpaid, ", ")))
+		}
+	}
+
+	policy.GraceEndsOn = ""
+	return c.changePolicyStatus(ctx, policy, PolicyStatusActive, "PolicyActivated")
+}
+
+// //////////////////////////////////////////////////
+// LAPSE A POLICY WHOSE PREMIUMS HAVE GONE UNPAID //
+// //////////////////////////////////////////////////
+func (c *HealthInsurance) LapsePolicy(ctx contractapi.TransactionContextInterface, policyID string) error {
+	// only insurers can lapse policies
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// a policy only lapses for a premium that is actually overdue
+	overdue, err := getOverduePremiums(ctx, policyID, txTimestamp.AsTime())
+	if err != nil {
+		return err
+	}
+	if len(overdue) == 0 {
+		return NewStateError(fmt.Sprintf("cannot lapse policy %s, no premium is overdue", policyID))
+	}
+
+	return c.changePolicyStatus(ctx, policy, PolicyStatusLapsed, "PolicyLapsed")
+}
+
+// /////////////////////////////////////////////////////
+// MARK A SINGLE POLICY PAST ITS END DATE AS EXPIRED //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) ExpirePolicy(ctx contractapi.TransactionContextInterface, policyID string) error {
+	// only insurers can expire policies
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	// the end date is inclusive, so the policy expires the day after
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return err
+	}
+	if txTimestamp.AsTime().UTC().Before(end.AddDate(0, 0, 1)) {
+		return NewStateError(fmt.Sprintf("cannot expire policy %s before its end date %s has passed", policyID, policy.EndDate))
+	}
+
+	// a policy in force keeps its renewal grace window, the lapse sweep expires it once that closes
+	inGrace, err := inRenewalGrace(config, policy, txTimestamp.AsTime().UTC())
+	if err != nil {
+		return err
+	}
+	if inGrace {
+		return NewStateError(fmt.Sprintf("cannot expire policy %s while it can still be renewed, its grace window runs %d days past %s", policyID, config.RenewalGraceDays, policy.EndDate))
+	}
+
+	return c.changePolicyStatus(ctx, policy, PolicyStatusExpired, "PolicyExpired")
+}
+
+// ///////////////////////////////////////////////////////////////////
+// MOVE A POLICY TO A NEW STATUS, STORE IT AND ANNOUNCE THE CHANGE //
+// ///////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) changePolicyStatus(ctx contractapi.TransactionContextInterface, policy *Policy, status string, eventName string) error {
+	previous := *policy
+	if err := transitionPolicy(policy, status); err != nil {
+		return err
+	}
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return setChaincodeEvent(ctx, eventName, policy.PolicyID, "")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A RECORD OF A POLICY PORTED TO ANOTHER PLAN
+type PortingRecord struct {
+	SourcePolicyID     string `json:"sourcePolicyID"`
+	TargetPolicyID     string `json:"targetPolicyID"`
+	PortedClaimedTotal int    `json:"portedClaimedTotal"`
+	PortedBy           string `json:"portedBy"`  // client ID of the policyholder
+	Timestamp          string `json:"timestamp"` // RFC3339, UTC
+}
+
+// //////////////////////////////////////////////////////
+// PORT A POLICY'S HISTORY TO THE HOLDER'S NEW POLICY //
+// //////////////////////////////////////////////////////
+func (c *HealthInsurance) PortPolicy(ctx contractapi.TransactionContextInterface, sourcePolicyID string, targetPolicyID string) error {
+	if sourcePolicyID == targetPolicyID {
+		return NewValidationError("targetPolicyID", "source and target policy must be different")
+	}
+
+	source, err := c.GetPolicy(ctx, sourcePolicyID)
+	if err != nil {
+		return err
+	}
+
+	// only the policyholder can move their own cover
+	if err := assertPolicyOwner(ctx, source); err != nil {
+		return err
+	}
+
+	if source.Status != PolicyStatusActive && source.Status != PolicyStatusExpired {
+		return NewStateError(fmt.Sprintf("cannot port policy %s, current status is %q", sourcePolicyID, source.Status))
+	}
+
+	target, err := c.GetPolicy(ctx, targetPolicyID)
+	if err != nil {
+		return err
+	}
+
+	if target.Status != PolicyStatusActive {
+		return NewStateError(fmt.Sprintf("cannot port to policy %s, current status is %q", targetPolicyID, target.Status))
+	}
+
+	// both policies must cover the same person
+	if target.PersonName != source.PersonName || target.OwnerCertID != source.OwnerCertID {
+		return NewValidationError("targetPolicyID", fmt.Sprintf("policy %s does not belong to the holder of policy %s", targetPolicyID, sourcePolicyID))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	stats := newNetworkStats()
+	stats.trackPolicyChange(source, nil)
+
+	// the new insurer learns how much of the earlier cover has been used
+	target.PortedClaimedTotal, err = addAmounts("portedClaimedTotal", source.ClaimedTotal, source.PortedClaimedTotal)
+	if err != nil {
+		return err
+	}
+	target.Version++
+
+	source.Status = PolicyStatusPorted
+	source.Version++
+	stats.trackPolicyChange(nil, source)
+
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return err
+	}
+
+	for _, policy := range []*Policy{source, target} {
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return NewLedgerError("marshal updated policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return NewLedgerError("store updated policy", err)
+		}
+	}
+
+	// carry the medical history over, keeping anything already recorded on the target
+	sourceRecord, err := getMedicalConditionsRecord(ctx, sourcePolicyID)
+	if err != nil {
+		return err
+	}
+	if sourceRecord != nil {
+		targetRecord, err := getMedicalConditionsRecord(ctx, targetPolicyID)
+		if err != nil {
+			return err
+		}
+		if targetRecord == nil {
+			targetRecord = &MedicalConditionsRecord{Conditions: []string{}}
+		}
+
+		for _, condition := range sourceRecord.Conditions {
+			if !containsFold(targetRecord.Conditions, condition) {
+				targetRecord.Conditions = append(targetRecord.Conditions, condition)
+			}
+		}
+		targetRecord.LastUpdated = now
+
+		if err := putMedicalConditionsRecord(ctx, targetPolicyID, targetRecord); err != nil {
+			return err
+		}
+	}
+
+	record := PortingRecord{
+		SourcePolicyID:     sourcePolicyID,
+		TargetPolicyID:     targetPolicyID,
+		PortedClaimedTotal: target.PortedClaimedTotal,
+		PortedBy:           clientID,
+		Timestamp:          now,
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return NewLedgerError("marshal porting record", err)
+	}
+
+	recordKey, err := ctx.GetStub().CreateCompositeKey("porting", []string{sourcePolicyID, targetPolicyID})
+	if err != nil {
+		return NewLedgerError("create porting key", err)
+	}
+
+	if err := ctx.GetStub().PutState(recordKey, recordJSON); err != nil {
+		return NewLedgerError("store porting record", err)
+	}
+
+	return setChaincodeEvent(ctx, "PolicyPorted", sourcePolicyID, "")
+}
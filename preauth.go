@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// //////////////////////////////////////////////////////////////////////////////////
+// REQUEST CASHLESS PRE-AUTHORIZATION FOR A HOSPITALIZATION AT A NETWORK HOSPITAL //
+// //////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RequestPreAuth(ctx contractapi.TransactionContextInterface, policyID string, hospitalID string, estimatedAmount int, diagnosis string) (string, error) {
+	// pre-authorizations are requested by policyholders or by hospitals on their behalf
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := assertMSP(ctx, config.AllowedPatientMSP, config.AllowedHospitalMSP); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(diagnosis) == "" {
+		return "", NewValidationError("diagnosis", "diagnosis must not be empty")
+	}
+
+	// cashless treatment is only available at hospitals in the approved network
+	hospital, err := getHospital(ctx, hospitalID)
+	if err != nil {
+		return "", err
+	}
+	if hospital == nil || !hospital.Active {
+		return "", NewValidationError("hospitalID", fmt.Sprintf("hospital %s is not an approved hospital", hospitalID))
+	}
+
+	return c.requestPreAuth(ctx, policyID, estimatedAmount, diagnosis, hospital.HospitalName, hospital.HospitalID)
+}
+
+// ///////////////////////////////////////////////////////////
+// REQUEST PRE-AUTHORIZATION FOR A PLANNED HOSPITALIZATION //
+// ///////////////////////////////////////////////////////////
+// Deprecated: use RequestPreAuth, which checks the hospital against the approved network
+func (c *HealthInsurance) PreAuthorizeClaim(ctx contractapi.TransactionContextInterface, policyID string, estimatedAmount int, claimReason string, hospitalName string) (string, error) {
+	return c.requestPreAuth(ctx, policyID, estimatedAmount, claimReason, hospitalName, "")
+}
+
+// ///////////////////////////////////////
+// APPROVE A PENDING PRE-AUTHORIZATION //
+// ///////////////////////////////////////
+func (c *HealthInsurance) ApprovePreAuth(ctx contractapi.TransactionContextInterface, preAuthID string, sanctionedAmount int) error {
+	// only insurers can sign off pre-authorizations
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	preAuth, err := getClaim(ctx, preAuthID)
+	if err != nil {
+		return err
+	}
+
+	// the insurer may sanction less than the hospital's estimate, never more
+	if sanctionedAmount <= 0 || sanctionedAmount > preAuth.ClaimAmount {
+		return NewValidationError("sanctionedAmount", fmt.Sprintf("invalid sanctioned amount %d: must be between 1 and the estimated amount of %d", sanctionedAmount, preAuth.ClaimAmount))
+	}
+
+	if err := transitionClaim(preAuth, PreAuthStatusApproved); err != nil {
+		return err
+	}
+	preAuth.ApprovedAmount = sanctionedAmount
+
+	if err := setChaincodeEvent(ctx, "PreAuthApproved", preAuth.PolicyID, preAuthID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, preAuth)
+}
+
+// //////////////////////////////////////
+// REJECT A PENDING PRE-AUTHORIZATION //
+// //////////////////////////////////////
+func (c *HealthInsurance) RejectPreAuth(ctx contractapi.TransactionContextInterface, preAuthID string, reason string) error {
+	// only insurers can turn down pre-authorizations
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "rejection reason must not be empty")
+	}
+
+	preAuth, err := getClaim(ctx, preAuthID)
+	if err != nil {
+		return err
+	}
+
+	if err := transitionClaim(preAuth, PreAuthStatusRejected); err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	preAuth.RejectionReason = reason
+	preAuth.RejectedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	if err := setChaincodeEvent(ctx, "PreAuthRejected", preAuth.PolicyID, preAuthID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, preAuth)
+}
+
+// //////////////////////////////////////////////////////////////////
+// STORE A NEW PRE-AUTHORIZATION REQUEST AGAINST AN ACTIVE POLICY //
+// //////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) requestPreAuth(ctx contractapi.TransactionContextInterface, policyID string, estimatedAmount int, claimReason string, hospitalName string, hospitalID string) (string, error) {
+	if estimatedAmount <= 0 {
+		return "", NewValidationError("estimatedAmount", "estimated amount must be greater than zero")
+	}
+	if err := checkAmount("estimatedAmount", estimatedAmount); err != nil {
+		return "", err
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return "", err
+	}
+
+	if policy.Status != PolicyStatusActive {
+		return "", NewStateError(fmt.Sprintf("policy is not active, current status is %q", policy.Status))
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", NewLedgerError("get transaction timestamp", err)
+	}
+
+	// no cashless treatment is authorized at a hospital while it is blacklisted
+	blacklistEntry, err := findBlacklistEntry(ctx, hospitalID, hospitalName)
+	if err != nil {
+		return "", err
+	}
+	if blacklistEntry != nil && blacklistEntry.inEffect(txTimestamp.AsTime()) {
+		return "", NewValidationError("hospitalID", fmt.Sprintf("hospital %q is blacklisted from %s: %s", blacklistEntry.HospitalName, blacklistEntry.EffectiveFrom, blacklistEntry.Reason))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	// a pre-authorization is a claim that has not been made yet
+	preAuthID := ctx.GetStub().GetTxID()
+	preAuth := Claim{
+		ObjectType:   "claim",
+		ClaimID:      preAuthID,
+		PolicyID:     policyID,
+		ClaimAmount:  estimatedAmount,
+		GrossAmount:  estimatedAmount,
+		ClaimReason:  claimReason,
+		HospitalName: hospitalName,
+		HospitalID:   hospitalID,
+		Status:       PreAuthStatusRequested,
+		Timestamp:    fmt.Sprintf("%d", txTimestamp.Seconds),
+		SubmittedBy:  clientID,
+	}
+
+	if err := putClaim(ctx, &preAuth); err != nil {
+		return "", err
+	}
+
+	if err := setChaincodeEvent(ctx, "PreAuthRequested", policyID, preAuthID); err != nil {
+		return "", err
+	}
+
+	// index the pre-authorization ID like any other claim ID
+	if err := indexClaimID(ctx, preAuthID, policyID); err != nil {
+		return "", err
+	}
+
+	return preAuthID, nil
+}
+
+// /////////////////////////////////////////////////////////////////////
+// READ AN APPROVED PRE-AUTHORIZATION THAT A CLAIM CAN BE MADE UNDER //
+// /////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) getClaimablePreAuth(ctx contractapi.TransactionContextInterface, policyID string, preAuthID string) (*Claim, error) {
+	preAuth, err := getClaim(ctx, preAuthID)
+	if err != nil {
+		return nil, err
+	}
+
+	if preAuth.PolicyID != policyID {
+		return nil, NewValidationError("preAuthID", fmt.Sprintf("pre-authorization %s does not belong to policy %s", preAuthID, policyID))
+	}
+	if preAuth.Status != PreAuthStatusApproved {
+		return nil, NewStateError(fmt.Sprintf("pre-authorization %s is not approved, current status is %q", preAuthID, preAuth.Status))
+	}
+
+	return preAuth, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A SINGLE PREMIUM INSTALLMENT OF A POLICY
+type Premium struct {
+	ObjectType string `json:"docType"`
+	PremiumID  string `json:"premiumID"` // {policyID}~{dueDate}
+	PolicyID   string `json:"policyID"`
+	Amount     int    `json:"amount"`
+	DueDate    string `json:"dueDate"`              // YYYY-MM-DD
+	PaidDate   string `json:"paidDate"`             // YYYY-MM-DD, empty until paid
+	PaidAmount int    `json:"paidAmount,omitempty"` // amount received, at least the installment
+	TxID       string `json:"txID"`                 // transaction that paid the premium
+	Status     string `json:"status"`               // due/paid, a due premium past its due date is overdue
+}
+
+// ///////////////////////////////////////////////////////
+// GENERATE THE PREMIUM INSTALLMENTS FOR A POLICY TERM //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) GeneratePremiumSchedule(ctx contractapi.TransactionContextInterface, policyID string, annualPremium int, frequencyMonths int) error {
+	// only insurers set premium schedules
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if annualPremium <= 0 {
+		return NewValidationError("annualPremium", "annual premium must be greater than zero")
+	}
+	if err := checkAmount("annualPremium", annualPremium); err != nil {
+		return err
+	}
+
+	// installments must split the year evenly
+	if frequencyMonths != 1 && frequencyMonths != 3 && frequencyMonths != 6 && frequencyMonths != 12 {
+		return NewValidationError("frequencyMonths", fmt.Sprintf("invalid frequency %d: must be 1, 3, 6 or 12 months", frequencyMonths))
+	}
+
+	// retrieve the policy details
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getPremiumsForPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return NewConflictError(fmt.Sprintf("policy %s already has a premium schedule", policyID))
+	}
+
+	start, err := parsePolicyDate("start date", policy.StartDate)
+	if err != nil {
+		return err
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return err
+	}
+
+	// one installment at the start of every period within the policy term
+	installment := annualPremium * frequencyMonths / 12
+	for dueDate := start; !dueDate.After(end); dueDate = dueDate.AddDate(0, frequencyMonths, 0) {
+		premium := &Premium{
+			ObjectType: "premium",
+			PolicyID:   policyID,
+			Amount:     installment,
+			DueDate:    dueDate.Format("2006-01-02"),
+			Status:     "due",
+		}
+		premium.PremiumID = policyID + "~" + premium.DueDate
+
+		if err := putPremium(ctx, premium); err != nil {
+			return err
+		}
+	}
+
+	return setChaincodeEvent(ctx, "PremiumScheduleGenerated", policyID, "")
+}
+
+// ////////////////////////////////////////////////////////
+// RECORD THE PAYMENT OF A PREMIUM INSTALLMENT RECEIVED //
+// ////////////////////////////////////////////////////////
+func (c *HealthInsurance) PayPremium(ctx contractapi.TransactionContextInterface, premiumID string, amount int) error {
+	// payments are recorded by the insurer that received them
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if err := checkAmount("amount", amount); err != nil {
+		return err
+	}
+
+	premium, err := getPremium(ctx, premiumID)
+	if err != nil {
+		return err
+	}
+
+	if premium.Status == "paid" {
+		return NewStateError(fmt.Sprintf("premium %s is already paid", premiumID))
+	}
+
+	// a part payment leaves the installment due
+	if amount < premium.Amount {
+		return NewValidationError("amount", fmt.Sprintf("amount %d is less than the %d due on premium %s", amount, premium.Amount, premiumID))
+	}
+
+	if err := markPremiumPaid(ctx, premium, amount); err != nil {
+		return err
+	}
+
+	return setClaimEvent(ctx, "PremiumPaid", premium.PolicyID, "", amount)
+}
+
+// ///////////////////////////////////////////////////////
+// MARK A PREMIUM PAID WITH THE AMOUNT RECEIVED FOR IT //
+// ///////////////////////////////////////////////////////
+func markPremiumPaid(ctx contractapi.TransactionContextInterface, premium *Premium, amount int) error {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	premium.Status = "paid"
+	premium.PaidDate = txTimestamp.AsTime().UTC().Format("2006-01-02")
+	premium.PaidAmount = amount
+	premium.TxID = ctx.GetStub().GetTxID()
+
+	return putPremium(ctx, premium)
+}
+
+// ////////////////////////////////////////
+// READ A PREMIUM INSTALLMENT BY ITS ID //
+// ////////////////////////////////////////
+func getPremium(ctx contractapi.TransactionContextInterface, premiumID string) (*Premium, error) {
+	// the premium ID ends with the fixed-width due date
+	separator := len(premiumID) - len("~2006-01-02")
+	if separator <= 0 || premiumID[separator] != '~' {
+		return nil, NewValidationError("premiumID", fmt.Sprintf("invalid premium ID %q, expected {policyID}~{dueDate}", premiumID))
+	}
+	policyID, dueDate := premiumID[:separator], premiumID[separator+1:]
+
+	premiumKey, err := ctx.GetStub().CreateCompositeKey("premium", []string{policyID, dueDate})
+	if err != nil {
+		return nil, NewLedgerError("create premium key", err)
+	}
+
+	premiumJSON, err := ctx.GetStub().GetState(premiumKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if premiumJSON == nil {
+		return nil, NewNotFoundError("premium", premiumID)
+	}
+
+	var premium Premium
+	if err := json.Unmarshal(premiumJSON, &premium); err != nil {
+		return nil, NewLedgerError("unmarshal premium", err)
+	}
+
+	return &premium, nil
+}
+
+// //////////////////////////////////////
+// RETRIEVE ALL PREMIUMS FOR A POLICY //
+// //////////////////////////////////////
+func getPremiumsForPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*Premium, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("premium", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read premiums from world state", err)
+	}
+	defer iterator.Close()
+
+	premiums := []*Premium{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate premiums", err)
+		}
+
+		var premium Premium
+		if err := json.Unmarshal(result.Value, &premium); err != nil {
+			return nil, NewLedgerError("unmarshal premium", err)
+		}
+		premiums = append(premiums, &premium)
+	}
+
+	return premiums, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////
+// LIST THE PREMIUMS OF A POLICY THAT HAVE FALLEN DUE BUT ARE NOT PAID //
+// ///////////////////////////////////////////////////////////////////////
+func getUnpaidPremiums(ctx contractapi.TransactionContextInterface, policyID string, at time.Time) ([]string, error) {
+	premiums, err := getPremiumsForPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// unlike an overdue premium, one due today already counts
+	today := at.UTC().Format("2006-01-02")
+
+	unpaid := []string{}
+	for _, premium := range premiums {
+		if premium.Status != "paid" && premium.DueDate <= today {
+			unpaid = append(unpaid, premium.DueDate)
+		}
+	}
+
+	return unpaid, nil
+}
+
+// ///////////////////////////////////////////////////////////
+// LIST THE OVERDUE PREMIUMS OF A POLICY AT THE GIVEN TIME //
+// ///////////////////////////////////////////////////////////
+func getOverduePremiums(ctx contractapi.TransactionContextInterface, policyID string, at time.Time) ([]string, error) {
+	premiums, err := getPremiumsForPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// an unpaid premium becomes overdue the day after its due date
+	today := at.UTC().Format("2006-01-02")
+
+	overdue := []string{}
+	for _, premium := range premiums {
+		if premium.Status != "paid" && premium.DueDate < today {
+			overdue = append(overdue, premium.DueDate)
+		}
+	}
+
+	return overdue, nil
+}
+
+// //////////////////////////////////////
+// STORE A PREMIUM IN THE WORLD STATE //
+// //////////////////////////////////////
+func putPremium(ctx contractapi.TransactionContextInterface, premium *Premium) error {
+	premiumKey, err := ctx.GetStub().CreateCompositeKey("premium", []string{premium.PolicyID, premium.DueDate})
+	if err != nil {
+		return NewLedgerError("create premium key", err)
+	}
+
+	premiumJSON, err := json.Marshal(premium)
+	if err != nil {
+		return NewLedgerError("marshal premium", err)
+	}
+
+	if err := ctx.GetStub().PutState(premiumKey, premiumJSON); err != nil {
+		return NewLedgerError("store premium", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR ONE INFORMATION REQUEST ON A CLAIM AND THE CLAIMANT'S ANSWER
+type ClaimQuery struct {
+	RequestedItems []string      `json:"requestedItems"` // what the claimant was asked to provide
+	RaisedBy       string        `json:"raisedBy"`       // client ID of the adjuster
+	RaisedAt       string        `json:"raisedAt"`       // RFC3339, UTC
+	RespondBy      string        `json:"respondBy"`      // RFC3339, UTC, deadline for the answer
+	Response       string        `json:"response,omitempty"`
+	DocumentRefs   []DocumentRef `json:"documentRefs,omitempty"` // documents added with the answer
+	RespondedBy    string        `json:"respondedBy,omitempty"`  // client ID of the policyholder or hospital
+	RespondedAt    string        `json:"respondedAt,omitempty"`
+	Late           bool          `json:"late,omitempty"` // answered after the deadline
+}
+
+// /////////////////////////////////////////////////////////////////
+// ASK THE CLAIMANT FOR MORE INFORMATION BEFORE DECIDING A CLAIM //
+// /////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RaiseClaimQuery(ctx contractapi.TransactionContextInterface, claimID string, requestedItemsJSON string) error {
+	requestedItems, err := parseStringList("requestedItems", requestedItemsJSON)
+	if err != nil {
+		return err
+	}
+	if len(requestedItems) == 0 {
+		return NewValidationError("requestedItems", "at least one requested item is required")
+	}
+	for i := range requestedItems {
+		requestedItems[i] = strings.TrimSpace(requestedItems[i])
+		if requestedItems[i] == "" {
+			return NewValidationError("requestedItems", "requested items must not be empty")
+		}
+	}
+
+	claim, config, err := c.getDecidableClaim(ctx, claimID, ClaimStatusQueryRaised)
+	if err != nil {
+		return err
+	}
+
+	// an assigned claim is queried by its adjuster
+	if err := assertClaimAssignee(ctx, claim); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	if err := transitionClaim(claim, ClaimStatusQueryRaised); err != nil {
+		return err
+	}
+
+	// every request stays on the claim, so the back-and-forth can be followed later
+	claim.Queries = append(claim.Queries, ClaimQuery{
+		RequestedItems: requestedItems,
+		RaisedBy:       clientID,
+		RaisedAt:       now.Format(time.RFC3339),
+		RespondBy:      now.AddDate(0, 0, config.QueryResponseDays).Format(time.RFC3339),
+	})
+
+	if err := setChaincodeEvent(ctx, "ClaimQueryRaised", claim.PolicyID, claimID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// ///////////////////////////////////////////////////////////////////////
+// ANSWER THE OPEN QUERY ON A CLAIM, WITH A NOTE AND/OR MORE DOCUMENTS //
+// ///////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RespondToClaimQuery(ctx contractapi.TransactionContextInterface, claimID string, response string, docRefsJSON string) error {
+	return c.respondToClaimQuery(ctx, claimID, response, docRefsJSON)
+}
+
+// //////////////////////////////////////////////////////////////////////
+// ANSWER A QUERY WITH MORE DOCUMENTS, KEEPING THE ONES ALREADY FILED //
+// //////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) AddClaimDocuments(ctx contractapi.TransactionContextInterface, claimID string, docRefsJSON string) error {
+	return c.respondToClaimQuery(ctx, claimID, "", docRefsJSON)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////
+// RECORD THE ANSWER TO A CLAIM'S OPEN QUERY AND HAND THE CLAIM BACK FOR REVIEW //
+// ////////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) respondToClaimQuery(ctx contractapi.TransactionContextInterface, claimID string, response string, docRefsJSON string) error {
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	// answers come from the policyholder, or from a hospital for the claims it files
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if assertMSP(ctx, config.AllowedHospitalMSP) != nil {
+		policy, err := c.GetPolicy(ctx, claim.PolicyID)
+		if err != nil {
+			return err
+		}
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return err
+		}
+	}
+
+	if claim.Status != ClaimStatusQueryRaised || len(claim.Queries) == 0 {
+		return NewStateError(fmt.Sprintf("claim %s is %s, it can only be answered while it is %s", claimID, claim.Status, ClaimStatusQueryRaised))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	documentRefs, err := parseDocumentRefs(docRefsJSON, clientID)
+	if err != nil {
+		return err
+	}
+	response = strings.TrimSpace(response)
+	if response == "" && len(documentRefs) == 0 {
+		return NewValidationError("response", "a response or at least one document is required")
+	}
+
+	// the documents filed with the claim stay on record, a document is only anchored once
+	for _, documentRef := range documentRefs {
+		for _, existing := range claim.DocumentRefs {
+			if existing.SHA256 == documentRef.SHA256 {
+				return NewConflictError(fmt.Sprintf("document %s is already filed with claim %s", documentRef.SHA256, claimID))
+			}
+		}
+		claim.DocumentRefs = append(claim.DocumentRefs, documentRef)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// a late answer is still taken, the adjuster sees that it missed the deadline
+	query := &claim.Queries[len(claim.Queries)-1]
+	respondBy, err := time.Parse(time.RFC3339, query.RespondBy)
+	if err != nil {
+		return NewLedgerError("parse query deadline", err)
+	}
+	query.Response = response
+	query.DocumentRefs = documentRefs
+	query.RespondedBy = clientID
+	query.RespondedAt = now.Format(time.RFC3339)
+	query.Late = now.After(respondBy)
+
+	// the answered claim goes back to the insurer for review
+	if err := transitionClaim(claim, ClaimStatusUnderReview); err != nil {
+		return err
+	}
+
+	if err := setChaincodeEvent(ctx, "ClaimQueryAnswered", claim.PolicyID, claimID); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
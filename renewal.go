@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A POLICY TERM STARTED BY A RENEWAL, PUBLIC SO TERM BOUNDARIES CAN BE QUERIED
+type PolicyRenewal struct {
+	ObjectType         string `json:"docType"`
+	PolicyID           string `json:"policyID"`
+	TermStartDate      string `json:"termStartDate"` // YYYY-MM-DD, the day after the previous term ended
+	TermEndDate        string `json:"termEndDate"`   // YYYY-MM-DD, inclusive
+	PreviousEndDate    string `json:"previousEndDate"`
+	PreviousSumAssured int    `json:"previousSumAssured"`
+	SumAssured         int    `json:"sumAssured"`   // for the new term, including the no-claim bonus
+	NoClaimBonus       int    `json:"noClaimBonus"` // percentage carried into the new term
+	Premium            int    `json:"premium"`      // due on the first day of the new term
+	RenewedBy          string `json:"renewedBy"`    // client ID of the insurer
+	RenewedAt          string `json:"renewedAt"`    // RFC3339, UTC
+}
+
+// ///////////////////////////////////////////////////
+// RETRIEVE THE RENEWALS OF A POLICY, OLDEST FIRST //
+// ///////////////////////////////////////////////////
+func (c *HealthInsurance) GetPolicyRenewals(ctx contractapi.TransactionContextInterface, policyID string) ([]*PolicyRenewal, error) {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// renewals are seen by the policyholder and the insurer
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if assertMSP(ctx, config.AllowedInsuranceMSP) != nil {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	// keys end in the term start date, so they come back in date order
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("policyrenewal", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read renewals from world state", err)
+	}
+	defer iterator.Close()
+
+	renewals := []*PolicyRenewal{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate renewals", err)
+		}
+
+		var renewal PolicyRenewal
+		if err := json.Unmarshal(result.Value, &renewal); err != nil {
+			return nil, NewLedgerError("unmarshal renewal", err)
+		}
+		renewals = append(renewals, &renewal)
+	}
+
+	return renewals, nil
+}
+
+// //////////////////////////////////////////////////////////
+// THE NO-CLAIM BONUS A POLICY CARRIES INTO ITS NEXT TERM //
+// //////////////////////////////////////////////////////////
+func nextNoClaimBonus(config *ChaincodeConfig, policy *Policy) int {
+	bonus := policy.NoClaimBonus
+
+	// a claim paid in the ending term takes back one term's worth of bonus
+	if policy.ClaimedTotal > 0 {
+		bonus -= config.NoClaimBonusPercent
+		if bonus < 0 {
+			bonus = 0
+		}
+		return bonus
+	}
+
+	bonus += config.NoClaimBonusPercent
+	if bonus > config.MaxNoClaimBonusPercent {
+		bonus = config.MaxNoClaimBonusPercent
+	}
+	return bonus
+}
+
+// /////////////////////////////////////////////
+// STORE A POLICY RENEWAL IN THE WORLD STATE //
+// /////////////////////////////////////////////
+func putPolicyRenewal(ctx contractapi.TransactionContextInterface, renewal *PolicyRenewal) error {
+	renewalKey, err := ctx.GetStub().CreateCompositeKey("policyrenewal", []string{renewal.PolicyID, renewal.TermStartDate})
+	if err != nil {
+		return NewLedgerError("create renewal key", err)
+	}
+
+	renewalJSON, err := json.Marshal(renewal)
+	if err != nil {
+		return NewLedgerError("marshal renewal", err)
+	}
+
+	if err := ctx.GetStub().PutState(renewalKey, renewalJSON); err != nil {
+		return NewLedgerError("store renewal", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// OPEN A SUPPLEMENTARY CLAIM FOR BILLS THAT SURFACED AFTER A CLAIM WAS PAID //
+// /////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ReopenClaim(ctx contractapi.TransactionContextInterface, claimID string, reason string, additionalAmount int, documentsJSON string) (string, error) {
+	// reopening is reserved for administrators of the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "admin"); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return "", NewValidationError("reason", "reopen reason must not be empty")
+	}
+	if additionalAmount <= 0 {
+		return "", NewValidationError("additionalAmount", "additional amount must be greater than zero")
+	}
+	if err := checkAmount("additionalAmount", additionalAmount); err != nil {
+		return "", err
+	}
+
+	original, err := getClaim(ctx, claimID)
+	if err != nil {
+		return "", err
+	}
+
+	// only paid claims are reopened, open ones are still decided as they are
+	if original.Status != ClaimStatusSettled && original.Status != ClaimStatusReimbursed {
+		return "", NewStateError(fmt.Sprintf("claim %s is %s, only %s or %s claims can be reopened", claimID, original.Status, ClaimStatusSettled, ClaimStatusReimbursed))
+	}
+
+	policy, err := c.GetPolicy(ctx, original.PolicyID)
+	if err != nil {
+		return "", err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", NewLedgerError("get client ID", err)
+	}
+
+	documentRefs, err := parseDocumentRefs(documentsJSON, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	// the co-pay and sub-limit apply to the additional bills as they did to the original ones
+	insuredAmount, err := subLimitedAmount(policy, insuredPortion(additionalAmount, policy.CoPay), original.CoverageType)
+	if err != nil {
+		return "", err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", NewLedgerError("get transaction timestamp", err)
+	}
+
+	// the supplementary claim is decided and paid like any other, the settled one keeps its amounts
+	supplementary := &Claim{
+		ObjectType:      "claim",
+		ClaimID:         ctx.GetStub().GetTxID(),
+		PolicyID:        original.PolicyID,
+		ClaimAmount:     insuredAmount,
+		GrossAmount:     additionalAmount,
+		Currency:        original.Currency,
+		DiagnosisCodes:  original.DiagnosisCodes,
+		CoverageType:    original.CoverageType,
+		HospitalName:    original.HospitalName,
+		HospitalID:      original.HospitalID,
+		DateOfAdmission: original.DateOfAdmission,
+		DateOfDischarge: original.DateOfDischarge,
+		TreatmentDate:   original.TreatmentDate,
+		DocumentRefs:    documentRefs,
+		Status:          ClaimStatusSubmitted,
+		Timestamp:       fmt.Sprintf("%d", txTimestamp.Seconds),
+		SubmittedAt:     txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		ClaimType:       original.ClaimType,
+		SubmittedBy:     original.SubmittedBy,
+		ParentClaimID:   claimID,
+		ReopenReason:    reason,
+	}
+
+	// a reimbursement is paid to the account the original one went to
+	if original.ClaimType == ClaimTypeReimbursement {
+		payee, err := getPayeeDetails(ctx, claimID)
+		if err != nil {
+			return "", err
+		}
+		if payee == nil {
+			return "", NewNotFoundError("payee details", claimID)
+		}
+		payee.ClaimID = supplementary.ClaimID
+		if err := putPayeeDetails(ctx, payee); err != nil {
+			return "", err
+		}
+	}
+
+	original.SupplementaryClaimIDs = append(original.SupplementaryClaimIDs, supplementary.ClaimID)
+
+	if err := putClaim(ctx, original); err != nil {
+		return "", err
+	}
+	if err := putClaim(ctx, supplementary); err != nil {
+		return "", err
+	}
+	if err := indexClaimID(ctx, supplementary.ClaimID, supplementary.PolicyID); err != nil {
+		return "", err
+	}
+
+	// the access log is append-only, so the reopening stays on record
+	if err := logAccessEvent(ctx, original.PolicyID, fmt.Sprintf("reopened claim %s as supplementary claim %s: %s", claimID, supplementary.ClaimID, reason), clientID, "admin"); err != nil {
+		return "", err
+	}
+
+	if err := setChaincodeEvent(ctx, "ClaimReopened", original.PolicyID, supplementary.ClaimID); err != nil {
+		return "", err
+	}
+
+	return supplementary.ClaimID, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ////////////////////////////////////////////////////////////////////
+// ASSIGN A CLAIM TO THE LEAST LOADED ADJUSTER OF THE TIER IT NEEDS //
+// ////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) AutoRouteClaim(ctx contractapi.TransactionContextInterface, policyID string, claimID string) error {
+	// claims are distributed by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer", "admin"); err != nil {
+		return err
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+	if claim.PolicyID != policyID {
+		return NewValidationError("claimID", fmt.Sprintf("claim %s does not belong to policy %s", claimID, policyID))
+	}
+
+	// routing picks a first adjuster, moving an assigned claim is done by hand
+	if claim.AssignedAdjusterID != "" {
+		return NewConflictError(fmt.Sprintf("claim %s is already assigned to adjuster %s, reassign it with AssignClaimToAdjuster", claimID, claim.AssignedAdjusterID))
+	}
+
+	adjusters, err := c.GetAdjusterWorkload(ctx)
+	if err != nil {
+		return err
+	}
+
+	adjuster, rationale := routeClaim(config, claim, adjusters)
+	if adjuster == nil {
+		return NewStateError(fmt.Sprintf("cannot route claim %s, no adjuster of the tier it needs is registered: %s", claimID, rationale))
+	}
+
+	if err := assignClaim(ctx, claim, adjuster); err != nil {
+		return err
+	}
+	claim.RoutingRationale = rationale
+	if err := putClaim(ctx, claim); err != nil {
+		return err
+	}
+
+	// the routing event replaces ClaimAssigned, fabric keeps one event per transaction
+	eventJSON, err := json.Marshal(map[string]string{
+		"policyID":   policyID,
+		"claimID":    claimID,
+		"adjusterID": adjuster.AdjusterID,
+		"tier":       adjuster.Tier,
+		"rationale":  rationale,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("ClaimAutoRouted", eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
+
+// STRUCTURE FOR ROUTING THE CLAIMS SUBMITTED IN ONE TRANSACTION
+type claimRouter struct {
+	config    *ChaincodeConfig
+	adjusters []Adjuster // least loaded first, kept current as claims are assigned
+}
+
+// ///////////////////////////////////////////////////////////////////
+// PREPARE TO ROUTE NEW CLAIMS, WHEN THE CONFIGURATION ASKS FOR IT //
+// ///////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) newClaimRouter(ctx contractapi.TransactionContextInterface, config *ChaincodeConfig) (*claimRouter, error) {
+	router := &claimRouter{config: config}
+	if !config.AutoAssignClaims {
+		return router, nil
+	}
+
+	// a transaction cannot read what it just wrote, so the workloads are read once and then tracked here
+	adjusters, err := c.GetAdjusterWorkload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	router.adjusters = adjusters
+
+	return router, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////
+// ROUTE A CLAIM BEING SUBMITTED, RETURNING THE ADJUSTER, NIL IF NOT ROUTED //
+// ////////////////////////////////////////////////////////////////////////////
+func (r *claimRouter) routeNewClaim(ctx contractapi.TransactionContextInterface, claim *Claim) (*Adjuster, string, error) {
+	if !r.config.AutoAssignClaims {
+		return nil, "", nil
+	}
+
+	// the submitter is not an insurer, so the claim is routed without AutoRouteClaim's role check,
+	// and from the claim in hand since the transaction stored it moments ago
+	adjuster, rationale := routeClaim(r.config, claim, r.adjusters)
+
+	// with no adjuster of the right tier the claim waits to be assigned by hand
+	if adjuster == nil {
+		return nil, rationale, nil
+	}
+
+	if err := assignClaim(ctx, claim, adjuster); err != nil {
+		return nil, "", err
+	}
+	claim.RoutingRationale = rationale
+	if err := putClaim(ctx, claim); err != nil {
+		return nil, "", err
+	}
+
+	// the count went up in place, so the next claim of the transaction sees it
+	assigned := *adjuster
+	sortByWorkload(r.adjusters)
+
+	return &assigned, rationale, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////
+// PICK THE ADJUSTER FOR A CLAIM, NIL WHEN NONE OF THE RIGHT TIER EXISTS //
+// /////////////////////////////////////////////////////////////////////////
+func routeClaim(config *ChaincodeConfig, claim *Claim, adjusters []Adjuster) (*Adjuster, string) {
+	// least loaded first, so the first adjuster of a tier has the lowest workload in it
+	tierGroups, rationale := routingTiers(config, claim)
+	for _, tiers := range tierGroups {
+		for i := range adjusters {
+			if containsFold(tiers, adjusters[i].Tier) {
+				return &adjusters[i], rationale
+			}
+		}
+	}
+
+	return nil, rationale
+}
+
+// ////////////////////////////////////////////////////////////////////////////////
+// TIERS THAT MAY TAKE A CLAIM, IN ORDER OF PREFERENCE, AND THE REASON FOR THEM //
+// ////////////////////////////////////////////////////////////////////////////////
+func routingTiers(config *ChaincodeConfig, claim *Claim) ([][]string, string) {
+	senior := [][]string{{AdjusterTierSenior}}
+
+	switch {
+	case claim.UnderInvestigation:
+		return senior, fmt.Sprintf("risk score %d reached the fraud review threshold", claim.RiskScore)
+	case claim.OverTariffAmount > 0:
+		return senior, fmt.Sprintf("%d is billed above the agreed tariff", claim.OverTariffAmount)
+	case claim.UnmatchedCoverage:
+		return senior, "no diagnosis falls under a covered category"
+	case config.AutoRouteSeniorMin > 0 && claim.ClaimAmount >= config.AutoRouteSeniorMin:
+		return senior, fmt.Sprintf("claim amount %d is at or above the senior threshold %d", claim.ClaimAmount, config.AutoRouteSeniorMin)
+	case config.AutoRouteJuniorMax > 0 && claim.ClaimAmount <= config.AutoRouteJuniorMax:
+		// a senior adjuster takes a small claim when no junior is registered
+		return [][]string{{AdjusterTierJunior}, {AdjusterTierSenior}}, fmt.Sprintf("claim amount %d is within the junior limit %d", claim.ClaimAmount, config.AutoRouteJuniorMax)
+	case config.AutoRouteJuniorMax > 0 && config.AutoRouteSeniorMin == 0:
+		return senior, fmt.Sprintf("claim amount %d is above the junior limit %d", claim.ClaimAmount, config.AutoRouteJuniorMax)
+	default:
+		return [][]string{{AdjusterTierJunior, AdjusterTierSenior}}, fmt.Sprintf("claim amount %d needs no particular tier", claim.ClaimAmount)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// private collection shared by the insurer and hospital organisations
+const settlementCollection = "settlement-collection"
+
+// STRUCTURE FOR THE PAYOUT MADE AGAINST AN APPROVED CLAIM
+type Settlement struct {
+	ClaimID          string `json:"claimID"`
+	PolicyID         string `json:"policyID"`
+	PaymentReference string `json:"paymentReference"` // bank or payment system reference of the payout
+	SettlementDate   string `json:"settlementDate"`   // YYYY-MM-DD, when the money was paid
+	PaidAmount       int    `json:"paidAmount"`
+	SettledBy        string `json:"settledBy"`  // client ID of the insurer that recorded the payout
+	RecordedAt       string `json:"recordedAt"` // RFC3339, UTC
+}
+
+// ///////////////////////////////////////////////////////
+// RECORD THE PAYOUT OF AN APPROVED CLAIM AND CLOSE IT //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) SettleClaim(ctx contractapi.TransactionContextInterface, claimID string, paymentReference string, settlementDate string, paidAmount int) error {
+	// only claims adjusters of the insurer organisation record payouts
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, claimDecisionRoles...); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(paymentReference) == "" {
+		return NewValidationError("paymentReference", "payment reference must not be empty")
+	}
+
+	paidOn, err := parsePolicyDate("settlementDate", settlementDate)
+	if err != nil {
+		return err
+	}
+
+	claim, err := getClaim(ctx, claimID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateClaimTransition(claim, ClaimStatusSettled); err != nil {
+		return err
+	}
+
+	// a batched claim is paid with the rest of its batch
+	if claim.SettlementBatchID != "" {
+		return NewStateError(fmt.Sprintf("claim %s is in settlement batch %s, settle it with MarkBatchPaid", claimID, claim.SettlementBatchID))
+	}
+
+	// a cashless claim is paid to its hospital with a settlement batch, never on its own
+	if claim.ClaimType == ClaimTypeCashless {
+		return NewStateError(fmt.Sprintf("claim %s is cashless, settle it through CreateSettlementBatch and MarkBatchPaid", claimID))
+	}
+
+	// the payout cannot exceed what the insurer agreed to pay
+	if paidAmount <= 0 || paidAmount > payableAmount(claim) {
+		return NewValidationError("paidAmount", fmt.Sprintf("invalid paid amount %d: must be between 1 and the approved amount of %d", paidAmount, payableAmount(claim)))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	if paidOn.After(now) {
+		return NewValidationError("settlementDate", fmt.Sprintf("settlement date %s is in the future", settlementDate))
+	}
+
+	settlement := &Settlement{
+		ClaimID:          claimID,
+		PolicyID:         claim.PolicyID,
+		PaymentReference: strings.TrimSpace(paymentReference),
+		SettlementDate:   paidOn.Format("2006-01-02"),
+		PaidAmount:       paidAmount,
+		SettledBy:        clientID,
+		RecordedAt:       now.Format(time.RFC3339),
+	}
+
+	if err := putSettlement(ctx, settlement); err != nil {
+		return err
+	}
+
+	if err := transitionClaim(claim, ClaimStatusSettled); err != nil {
+		return err
+	}
+
+	if err := setClaimEvent(ctx, "ClaimSettled", claim.PolicyID, claimID, paidAmount); err != nil {
+		return err
+	}
+
+	return putClaim(ctx, claim)
+}
+
+// ////////////////////////////////////////////
+// RETRIEVE THE PAYOUT RECORDED FOR A CLAIM //
+// ////////////////////////////////////////////
+func (c *HealthInsurance) GetSettlement(ctx contractapi.TransactionContextInterface, claimID string) (*Settlement, error) {
+	// the settlement is shared between the insurer and the hospitals only
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := assertMSP(ctx, config.AllowedInsuranceMSP, config.AllowedHospitalMSP); err != nil {
+		return nil, err
+	}
+
+	settlementKey, err := ctx.GetStub().CreateCompositeKey("settlement", []string{claimID})
+	if err != nil {
+		return nil, NewLedgerError("create settlement key", err)
+	}
+
+	settlementJSON, err := ctx.GetStub().GetPrivateData(settlementCollection, settlementKey)
+	if err != nil {
+		return nil, NewLedgerError("read from private data collection", err)
+	}
+	if settlementJSON == nil {
+		return nil, NewNotFoundError("settlement", claimID)
+	}
+
+	var settlement Settlement
+	if err := json.Unmarshal(settlementJSON, &settlement); err != nil {
+		return nil, NewLedgerError("unmarshal settlement", err)
+	}
+
+	return &settlement, nil
+}
+
+// /////////////////////////////////////////////////////
+// STORE A SETTLEMENT IN THE PRIVATE DATA COLLECTION //
+// /////////////////////////////////////////////////////
+func putSettlement(ctx contractapi.TransactionContextInterface, settlement *Settlement) error {
+	settlementKey, err := ctx.GetStub().CreateCompositeKey("settlement", []string{settlement.ClaimID})
+	if err != nil {
+		return NewLedgerError("create settlement key", err)
+	}
+
+	settlementJSON, err := json.Marshal(settlement)
+	if err != nil {
+		return NewLedgerError("marshal settlement", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData(settlementCollection, settlementKey, settlementJSON); err != nil {
+		return NewLedgerError("store settlement", err)
+	}
+
+	return nil
+}
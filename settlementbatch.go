@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// statuses of a settlement batch
+const (
+	SettlementBatchStatusOpen = "OPEN" // created, waiting for the insurer's transfer
+	SettlementBatchStatusPaid = "PAID" // paid in one transfer, every member claim is settled
+)
+
+// STRUCTURE FOR A SET OF APPROVED CLAIMS PAID TO A HOSPITAL IN ONE TRANSFER
+type SettlementBatch struct {
+	ObjectType       string   `json:"docType"`
+	BatchID          string   `json:"batchID"`
+	HospitalID       string   `json:"hospitalID"`
+	HospitalName     string   `json:"hospitalName"`
+	FromDate         string   `json:"fromDate"` // YYYY-MM-DD, claims approved from this day
+	ToDate           string   `json:"toDate"`   // YYYY-MM-DD, up to and including this day
+	ClaimIDs         []string `json:"claimIDs"`
+	TotalPayable     int      `json:"totalPayable"` // sum of the approved amounts of the member claims
+	Status           string   `json:"status"`
+	CreatedBy        string   `json:"createdBy"` // client ID of the insurer
+	CreatedAt        string   `json:"createdAt"` // RFC3339, UTC
+	PaymentReference string   `json:"paymentReference,omitempty"`
+	PaidBy           string   `json:"paidBy,omitempty"` // client ID of the insurer that recorded the transfer
+	PaidAt           string   `json:"paidAt,omitempty"` // RFC3339, UTC
+}
+
+// /////////////////////////////////////////////////////////////////////////
+// GATHER A HOSPITAL'S APPROVED CLAIMS OF A PERIOD INTO ONE PAYOUT BATCH //
+// /////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) CreateSettlementBatch(ctx contractapi.TransactionContextInterface, hospitalID string, fromDate string, toDate string) (*SettlementBatch, error) {
+	// network hospitals are paid by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, err
+	}
+
+	from, err := parsePolicyDate("fromDate", fromDate)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parsePolicyDate("toDate", toDate)
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, NewValidationError("toDate", fmt.Sprintf("to date %s is before from date %s", toDate, fromDate))
+	}
+
+	hospital, err := getHospital(ctx, hospitalID)
+	if err != nil {
+		return nil, err
+	}
+	if hospital == nil {
+		return nil, NewNotFoundError("hospital", hospitalID)
+	}
+
+	claims, err := approvedHospitalClaims(ctx, hospitalID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return nil, NewStateError(fmt.Sprintf("hospital %s has no approved cashless claims from %s to %s that are not already in a batch", hospitalID, fromDate, toDate))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+
+	batch := &SettlementBatch{
+		ObjectType:   "settlementBatch",
+		BatchID:      ctx.GetStub().GetTxID(),
+		HospitalID:   hospitalID,
+		HospitalName: hospital.HospitalName,
+		FromDate:     from.Format("2006-01-02"),
+		ToDate:       to.Format("2006-01-02"),
+		ClaimIDs:     []string{},
+		Status:       SettlementBatchStatusOpen,
+		CreatedBy:    clientID,
+		CreatedAt:    txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	// a claim belongs to one batch at most, so it is never paid twice
+	for _, claim := range claims {
+		claim.SettlementBatchID = batch.BatchID
+		if err := putClaim(ctx, claim); err != nil {
+			return nil, err
+		}
+
+		batch.ClaimIDs = append(batch.ClaimIDs, claim.ClaimID)
+		if batch.TotalPayable, err = addAmounts("totalPayable", batch.TotalPayable, payableAmount(claim)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := putSettlementBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	if err := setSettlementBatchEvent(ctx, "SettlementBatchCreated", batch, batch.CreatedBy, batch.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// RECORD THE TRANSFER THAT PAID A BATCH AND SETTLE ALL ITS CLAIMS //
+// ///////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) MarkBatchPaid(ctx contractapi.TransactionContextInterface, batchID string, paymentRef string) error {
+	// only claims adjusters of the insurer organisation record payouts
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, claimDecisionRoles...); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(paymentRef) == "" {
+		return NewValidationError("paymentRef", "payment reference must not be empty")
+	}
+
+	batch, err := c.GetSettlementBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if batch.Status != SettlementBatchStatusOpen {
+		return NewStateError(fmt.Sprintf("settlement batch %s is %s, only %s batches can be paid", batchID, batch.Status, SettlementBatchStatusOpen))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// one failing claim fails the transaction, so the batch is settled in full or not at all
+	for _, claimID := range batch.ClaimIDs {
+		claim, err := getClaim(ctx, claimID)
+		if err != nil {
+			return err
+		}
+
+		if err := transitionClaim(claim, ClaimStatusSettled); err != nil {
+			return err
+		}
+
+		// the batch is the only payment of a cashless claim, made on-chain when a payment chaincode is configured
+		if err := c.settleClaimPayment(ctx, config, claim); err != nil {
+			return err
+		}
+
+		if err := putSettlement(ctx, &Settlement{
+			ClaimID:          claimID,
+			PolicyID:         claim.PolicyID,
+			PaymentReference: strings.TrimSpace(paymentRef),
+			SettlementDate:   now.Format("2006-01-02"),
+			PaidAmount:       payableAmount(claim),
+			SettledBy:        clientID,
+			RecordedAt:       now.Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+
+		if err := putClaim(ctx, claim); err != nil {
+			return err
+		}
+	}
+
+	batch.Status = SettlementBatchStatusPaid
+	batch.PaymentReference = strings.TrimSpace(paymentRef)
+	batch.PaidBy = clientID
+	batch.PaidAt = now.Format(time.RFC3339)
+
+	if err := putSettlementBatch(ctx, batch); err != nil {
+		return err
+	}
+
+	return setSettlementBatchEvent(ctx, "SettlementBatchPaid", batch, batch.PaidBy, batch.PaidAt)
+}
+
+// //////////////////////////////////////////////////////////////////////////
+// EMIT A SETTLEMENT BATCH EVENT, NAMING THE HOSPITAL AND THE CLAIMS PAID //
+// //////////////////////////////////////////////////////////////////////////
+func setSettlementBatchEvent(ctx contractapi.TransactionContextInterface, eventType string, batch *SettlementBatch, actorID string, timestamp string) error {
+	// a batch spans many policies, so the event is built here rather than by setChaincodeEvent
+	eventJSON, err := json.Marshal(map[string]interface{}{
+		"batchID":          batch.BatchID,
+		"hospitalID":       batch.HospitalID,
+		"claimIDs":         batch.ClaimIDs,
+		"totalPayable":     batch.TotalPayable,
+		"paymentReference": batch.PaymentReference,
+		"timestamp":        timestamp,
+		"actorID":          actorID,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent(eventType, eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
+
+// ///////////////////////////////
+// RETRIEVE A SETTLEMENT BATCH //
+// ///////////////////////////////
+func (c *HealthInsurance) GetSettlementBatch(ctx contractapi.TransactionContextInterface, batchID string) (*SettlementBatch, error) {
+	// batches are shared between the insurer and the hospitals only
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := assertMSP(ctx, config.AllowedInsuranceMSP, config.AllowedHospitalMSP); err != nil {
+		return nil, err
+	}
+
+	batchKey, err := ctx.GetStub().CreateCompositeKey("settlementbatch", []string{batchID})
+	if err != nil {
+		return nil, NewLedgerError("create settlement batch key", err)
+	}
+
+	batchJSON, err := ctx.GetStub().GetState(batchKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if batchJSON == nil {
+		return nil, NewNotFoundError("settlement batch", batchID)
+	}
+
+	var batch SettlementBatch
+	if err := json.Unmarshal(batchJSON, &batch); err != nil {
+		return nil, NewLedgerError("unmarshal settlement batch", err)
+	}
+
+	return &batch, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////////////////////
+// APPROVED CASHLESS CLAIMS OF A HOSPITAL DECIDED IN A PERIOD AND IN NO BATCH, BY CLAIM ID //
+// ///////////////////////////////////////////////////////////////////////////////////////////
+func approvedHospitalClaims(ctx contractapi.TransactionContextInterface, hospitalID string, fromDate string, toDate string) ([]*Claim, error) {
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{})
+	if err != nil {
+		return nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	claims := []*Claim{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		var claim Claim
+		if err := json.Unmarshal(result.Value, &claim); err != nil {
+			return nil, NewLedgerError("unmarshal claim", err)
+		}
+
+		// only cashless claims are paid to the hospital, the others were paid to the policyholder
+		if claim.ClaimType != ClaimTypeCashless || claim.HospitalID != hospitalID || claim.SettlementBatchID != "" {
+			continue
+		}
+		if claim.Status != ClaimStatusApproved && claim.Status != ClaimStatusPartiallyApproved {
+			continue
+		}
+
+		// claims approved before decision times were recorded are settled one by one
+		if len(claim.DecidedAt) < len("2006-01-02") {
+			continue
+		}
+		decidedOn := claim.DecidedAt[:len("2006-01-02")]
+		if decidedOn < fromDate || decidedOn > toDate {
+			continue
+		}
+
+		claims = append(claims, &claim)
+	}
+
+	sort.Slice(claims, func(i, j int) bool {
+		return claims[i].ClaimID < claims[j].ClaimID
+	})
+
+	return claims, nil
+}
+
+// ///////////////////////////////////////////////
+// STORE A SETTLEMENT BATCH IN THE WORLD STATE //
+// ///////////////////////////////////////////////
+func putSettlementBatch(ctx contractapi.TransactionContextInterface, batch *SettlementBatch) error {
+	batchKey, err := ctx.GetStub().CreateCompositeKey("settlementbatch", []string{batch.BatchID})
+	if err != nil {
+		return NewLedgerError("create settlement batch key", err)
+	}
+
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return NewLedgerError("marshal settlement batch", err)
+	}
+
+	if err := ctx.GetStub().PutState(batchKey, batchJSON); err != nil {
+		return NewLedgerError("store settlement batch", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// /////////////////////////////////////////////////////////////////////
+// ESCALATE OPEN CLAIMS OLDER THAN THE TURNAROUND SLA TO SUPERVISORS //
+// /////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) EscalateStaleClaims(ctx contractapi.TransactionContextInterface, maxAgeDays int) (int, error) {
+	// only insurers police their own turnaround times
+	config, err := getConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return 0, err
+	}
+
+	if maxAgeDays <= 0 {
+		return 0, NewValidationError("maxAgeDays", "maximum age must be greater than zero days")
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+	cutoff := now.AddDate(0, 0, -maxAgeDays)
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{})
+	if err != nil {
+		return 0, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	escalated := []string{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return 0, NewLedgerError("iterate claims", err)
+		}
+
+		var claim Claim
+		if err := json.Unmarshal(result.Value, &claim); err != nil {
+			return 0, NewLedgerError("unmarshal claim", err)
+		}
+
+		// escalated claims are already with supervisors, so they are not escalated again,
+		// a claim waiting for its second approval has already been decided once,
+		// and a queried claim is waiting on the claimant rather than the insurer
+		if !isOpenClaimStatus(claim.Status) || claim.Status == ClaimStatusEscalated || claim.Status == ClaimStatusPendingSecondApproval || claim.Status == ClaimStatusQueryRaised {
+			continue
+		}
+
+		submittedAt, ok := claimSubmittedAt(&claim)
+		if !ok || !submittedAt.Before(cutoff) {
+			continue
+		}
+
+		if err := transitionClaim(&claim, ClaimStatusEscalated); err != nil {
+			return 0, err
+		}
+		claim.EscalatedAt = now.Format(time.RFC3339)
+
+		if err := putClaim(ctx, &claim); err != nil {
+			return 0, err
+		}
+		escalated = append(escalated, claim.ClaimID)
+	}
+
+	// fabric keeps one event per transaction, so every escalated claim goes into a single event
+	if len(escalated) > 0 {
+		eventJSON, err := json.Marshal(map[string]interface{}{
+			"claimIDs":   escalated,
+			"maxAgeDays": maxAgeDays,
+			"timestamp":  now.Format(time.RFC3339),
+		})
+		if err != nil {
+			return 0, NewLedgerError("marshal event payload", err)
+		}
+
+		if err := ctx.GetStub().SetEvent("ClaimsEscalated", eventJSON); err != nil {
+			return 0, NewLedgerError("set event", err)
+		}
+	}
+
+	return len(escalated), nil
+}
+
+// //////////////////////////////////////////////////////////////////////
+// WHEN A CLAIM WAS SUBMITTED, FROM THE UNIX TIMESTAMP FOR OLDER ONES //
+// //////////////////////////////////////////////////////////////////////
+func claimSubmittedAt(claim *Claim) (time.Time, bool) {
+	if submittedAt, err := time.Parse(time.RFC3339, claim.SubmittedAt); err == nil {
+		return submittedAt, true
+	}
+
+	seconds, err := strconv.ParseInt(claim.Timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(seconds, 0).UTC(), true
+}
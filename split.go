@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ////////////////////////////////////////////////////////////////////////////
+// SPLIT PART OF A JOINT POLICY'S COVER AND CLAIMS INTO A POLICY OF ITS OWN //
+// ////////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) SplitPolicy(ctx contractapi.TransactionContextInterface, sourcePolicyID string, newPolicyID string, newOwnerName string, newOwnerCertID string, newDateOfBirth string, newGender string, transferredSumAssured int, transferredClaimsJSON string) error {
+	// splits are made by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer", "admin"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(newOwnerName) == "" || strings.TrimSpace(newOwnerCertID) == "" {
+		return NewValidationError("newOwnerCertID", "new owner name and certificate ID must not be empty")
+	}
+
+	transferredClaims, err := parseStringList("transferredClaims", transferredClaimsJSON)
+	if err != nil {
+		return err
+	}
+
+	source, err := c.GetPolicy(ctx, sourcePolicyID)
+	if err != nil {
+		return err
+	}
+
+	// only policies in force are split
+	if source.Status != PolicyStatusActive {
+		return NewStateError(fmt.Sprintf("cannot split policy %s, current status is %q", sourcePolicyID, source.Status))
+	}
+
+	// both policies keep some cover
+	if err := checkAmount("transferredSumAssured", transferredSumAssured); err != nil {
+		return err
+	}
+	if transferredSumAssured == 0 || transferredSumAssured >= source.SumAssured {
+		return NewValidationError("transferredSumAssured", fmt.Sprintf("transferred sum assured must be between 0 and the %d assured by policy %s, exclusive", source.SumAssured, sourcePolicyID))
+	}
+
+	// the new holder is insured in their own right, so must be of insurable age on the policy's start
+	if err := validateAge(newDateOfBirth, source.StartDate, config.MinInsurableAge, config.MaxInsurableAge); err != nil {
+		return err
+	}
+
+	exists, err := c.PolicyExists(ctx, newPolicyID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return NewConflictError(fmt.Sprintf("policy %s already exists", newPolicyID))
+	}
+
+	// the split-off policy has the source's terms, its own holder and no history yet
+	previousSource := *source
+	newPolicy := Policy{
+		ObjectType:             "policy",
+		PolicyID:               newPolicyID,
+		SumAssured:             transferredSumAssured,
+		Currency:               source.Currency,
+		PersonName:             newOwnerName,
+		DateOfBirth:            newDateOfBirth,
+		Gender:                 newGender,
+		StartDate:              source.StartDate,
+		EndDate:                source.EndDate,
+		CoPay:                  source.CoPay,
+		PreAuthThreshold:       source.PreAuthThreshold,
+		WaitingPeriodDays:      source.WaitingPeriodDays,
+		PreExistingWaitingDays: source.PreExistingWaitingDays,
+		Coverages:              source.Coverages,
+		Benefits:               source.Benefits,
+		Exclusions:             source.Exclusions,
+		SubLimits:              source.SubLimits,
+		SubLimitUtilized:       map[string]int{},
+		RoomRentLimit:          source.RoomRentLimit,
+		Deductible:             source.Deductible,
+		CoInsurers:             source.CoInsurers,
+		Status:                 PolicyStatusActive,
+		OwnerCertID:            newOwnerCertID,
+		Version:                1,
+	}
+
+	// a no-claim bonus stays with the source, its base shrinks in proportion
+	if source.BaseSumAssured > 0 {
+		source.BaseSumAssured -= scaleAmount(transferredSumAssured, source.BaseSumAssured, source.SumAssured)
+	}
+	source.SumAssured -= transferredSumAssured
+
+	claims, err := getAllClaimsForPolicy(ctx, sourcePolicyID)
+	if err != nil {
+		return err
+	}
+	claimsByID := map[string]*Claim{}
+	for _, claim := range claims {
+		claimsByID[claim.ClaimID] = claim
+	}
+
+	// what is paid on the moved claims goes with them, to the policy now carrying them
+	moved := map[string]bool{}
+	for _, claimID := range transferredClaims {
+		claim, ok := claimsByID[claimID]
+		if !ok {
+			return NewValidationError("transferredClaims", fmt.Sprintf("claim %s is not filed against policy %s", claimID, sourcePolicyID))
+		}
+		if moved[claimID] {
+			return NewValidationError("transferredClaims", fmt.Sprintf("claim %s is listed more than once", claimID))
+		}
+		moved[claimID] = true
+
+		charged := chargedAmount(claim)
+		source.ClaimedTotal -= charged
+		addSubLimitUsage(source, claim.CoverageType, -charged)
+		newPolicy.ClaimedTotal += charged
+		addSubLimitUsage(&newPolicy, claim.CoverageType, charged)
+
+		if err := moveClaim(ctx, claim, newPolicyID); err != nil {
+			return err
+		}
+	}
+
+	// each policy must still cover what is paid on it and what its open claims may cost
+	exposure := map[string]int{sourcePolicyID: source.ClaimedTotal, newPolicyID: newPolicy.ClaimedTotal}
+	for _, claim := range claims {
+		pending := 0
+		if isOpenClaimStatus(claim.Status) || claim.Status == ClaimStatusReimbursementPending {
+			pending = payableAmount(claim) - chargedAmount(claim)
+		}
+		exposure[claim.PolicyID] += pending
+	}
+	for _, policy := range []*Policy{source, &newPolicy} {
+		if exposure[policy.PolicyID] > policy.SumAssured {
+			return NewStateError(fmt.Sprintf("policy %s would be left with a sum assured of %d for %d of claims", policy.PolicyID, policy.SumAssured, exposure[policy.PolicyID]))
+		}
+		if err := validatePolicyInput(storedPolicyInput(policy)); err != nil {
+			return err
+		}
+	}
+	source.Version++
+
+	stats := newNetworkStats()
+	stats.trackPolicyChange(&previousSource, source)
+	stats.trackPolicyChange(nil, &newPolicy)
+	if err := applyNetworkStats(ctx, stats); err != nil {
+		return err
+	}
+
+	for _, policy := range []*Policy{source, &newPolicy} {
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return NewLedgerError("marshal policy", err)
+		}
+
+		if err := ctx.GetStub().PutState(policy.PolicyID, policyJSON); err != nil {
+			return NewLedgerError("store policy", err)
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	// medical conditions are not carried over, the new holder's are recorded separately
+	if err := putMedicalConditionsRecord(ctx, newPolicyID, &MedicalConditionsRecord{
+		Conditions:  []string{},
+		LastUpdated: now,
+	}); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+	actorRole, err := getClientRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := logAccessEvent(ctx, sourcePolicyID, fmt.Sprintf("split into policy %s at %s", newPolicyID, now), clientID, actorRole); err != nil {
+		return err
+	}
+
+	// the event names both policies, so it is built here rather than by setChaincodeEvent
+	eventJSON, err := json.Marshal(map[string]interface{}{
+		"sourcePolicyID":        sourcePolicyID,
+		"newPolicyID":           newPolicyID,
+		"transferredSumAssured": transferredSumAssured,
+		"transferredClaims":     transferredClaims,
+		"timestamp":             now,
+		"actorID":               clientID,
+	})
+	if err != nil {
+		return NewLedgerError("marshal event payload", err)
+	}
+
+	if err := ctx.GetStub().SetEvent("PolicySplit", eventJSON); err != nil {
+		return NewLedgerError("set event", err)
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////////////
+// THE TERMS OF A STORED POLICY, AS CHECKED WHEN ONE IS CREATED //
+// ////////////////////////////////////////////////////////////////
+func storedPolicyInput(policy *Policy) *PolicyInput {
+	return &PolicyInput{
+		PolicyID:               policy.PolicyID,
+		SumAssured:             policy.SumAssured,
+		PersonName:             policy.PersonName,
+		DateOfBirth:            policy.DateOfBirth,
+		Gender:                 policy.Gender,
+		StartDate:              policy.StartDate,
+		EndDate:                policy.EndDate,
+		CoPay:                  policy.CoPay,
+		PreAuthThreshold:       policy.PreAuthThreshold,
+		WaitingPeriodDays:      policy.WaitingPeriodDays,
+		PreExistingWaitingDays: policy.PreExistingWaitingDays,
+		Coverages:              policy.Coverages,
+		Benefits:               policy.Benefits,
+		Exclusions:             policy.Exclusions,
+		SubLimits:              policy.SubLimits,
+		RoomRentLimit:          policy.RoomRentLimit,
+		Deductible:             policy.Deductible,
+		CoInsurers:             policy.CoInsurers,
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// world state key holding the running network statistics
+const networkStatsKey = "__network_stats__"
+
+// STRUCTURE FOR AGGREGATE STATISTICS ACROSS ALL POLICIES, FOR REGULATORS
+type NetworkStats struct {
+	TotalPolicies    int            `json:"totalPolicies"` // every policy issued, whatever its status
+	TotalSumAssured  int            `json:"totalSumAssured"`
+	TotalClaimed     int            `json:"totalClaimed"` // claims paid in the current terms of the policies
+	PoliciesByStatus map[string]int `json:"policiesByStatus"`
+	PoliciesByGender map[string]int `json:"policiesByGender"`
+	LastUpdated      string         `json:"lastUpdated,omitempty"` // RFC3339, UTC
+}
+
+// ///////////////////////////////////////////////////////
+// RETRIEVE THE RUNNING STATISTICS ACROSS ALL POLICIES //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) GetNetworkStats(ctx contractapi.TransactionContextInterface) (*NetworkStats, error) {
+	if err := assertRegulator(ctx); err != nil {
+		return nil, err
+	}
+
+	return getNetworkStats(ctx)
+}
+
+// //////////////////////////////////////////////////////////
+// REBUILD THE NETWORK STATISTICS FROM A FULL POLICY SCAN //
+// //////////////////////////////////////////////////////////
+func (c *HealthInsurance) RecalculateNetworkStats(ctx contractapi.TransactionContextInterface) error {
+	if err := assertRegulator(ctx); err != nil {
+		return err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return NewLedgerError("read policies from world state", err)
+	}
+	defer iterator.Close()
+
+	stats := newNetworkStats()
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return NewLedgerError("iterate policies", err)
+		}
+
+		// other documents can share the key namespace, only keep policies
+		var policy Policy
+		if err := json.Unmarshal(result.Value, &policy); err != nil || policy.ObjectType != "policy" {
+			continue
+		}
+
+		stats.addPolicy(&policy, 1)
+	}
+
+	return putNetworkStats(ctx, stats)
+}
+
+// /////////////////////////////////////////////////////////////
+// ONLY AUDITORS OF THE REGULATOR MAY SEE NETWORK STATISTICS //
+// /////////////////////////////////////////////////////////////
+func assertRegulator(ctx contractapi.TransactionContextInterface) error {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := assertMSP(ctx, config.AllowedRegulatorMSP); err != nil {
+		return err
+	}
+
+	return assertRole(ctx, "auditor")
+}
+
+// //////////////////////////////////////////
+// EMPTY STATISTICS, READY TO BE ADDED TO //
+// //////////////////////////////////////////
+func newNetworkStats() *NetworkStats {
+	return &NetworkStats{
+		PoliciesByStatus: map[string]int{},
+		PoliciesByGender: map[string]int{},
+	}
+}
+
+// /////////////////////////////////////////////////////////////
+// ADD A POLICY TO THE STATISTICS, OR REMOVE IT WITH SIGN -1 //
+// /////////////////////////////////////////////////////////////
+func (s *NetworkStats) addPolicy(policy *Policy, sign int) {
+	s.TotalPolicies += sign
+	s.TotalSumAssured += sign * policy.SumAssured
+	s.TotalClaimed += sign * policy.ClaimedTotal
+	s.PoliciesByStatus[policy.Status] += sign
+	s.PoliciesByGender[policy.Gender] += sign
+}
+
+// /////////////////////////////////////////////////////////////
+// ADD THE EFFECT OF A POLICY CHANGE, NIL FOR NEW OR REMOVED //
+// /////////////////////////////////////////////////////////////
+func (s *NetworkStats) trackPolicyChange(before *Policy, after *Policy) {
+	if before != nil {
+		s.addPolicy(before, -1)
+	}
+	if after != nil {
+		s.addPolicy(after, 1)
+	}
+}
+
+// ///////////////////////////////////////////////////////////
+// READ THE RUNNING STATISTICS, EMPTY IF NONE ARE RECORDED //
+// ///////////////////////////////////////////////////////////
+func getNetworkStats(ctx contractapi.TransactionContextInterface) (*NetworkStats, error) {
+	statsJSON, err := ctx.GetStub().GetState(networkStatsKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+
+	stats := newNetworkStats()
+	if statsJSON == nil {
+		return stats, nil
+	}
+
+	if err := json.Unmarshal(statsJSON, stats); err != nil {
+		return nil, NewLedgerError("unmarshal network statistics", err)
+	}
+
+	// policies are read under their current status names, so the counts must be kept under them too
+	for legacy, status := range legacyPolicyStatuses {
+		if count, ok := stats.PoliciesByStatus[legacy]; ok {
+			stats.PoliciesByStatus[status] += count
+			delete(stats.PoliciesByStatus, legacy)
+		}
+	}
+
+	return stats, nil
+}
+
+// //////////////////////////////////////////////////////////////////
+// MERGE THE CHANGES OF A TRANSACTION INTO THE RUNNING STATISTICS //
+// //////////////////////////////////////////////////////////////////
+func applyNetworkStats(ctx contractapi.TransactionContextInterface, delta *NetworkStats) error {
+	// a transaction cannot read its own writes, so callers pass all their changes at once
+	stats, err := getNetworkStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats.TotalPolicies += delta.TotalPolicies
+	if stats.TotalSumAssured, err = addAmounts("totalSumAssured", stats.TotalSumAssured, delta.TotalSumAssured); err != nil {
+		return err
+	}
+	if stats.TotalClaimed, err = addAmounts("totalClaimed", stats.TotalClaimed, delta.TotalClaimed); err != nil {
+		return err
+	}
+	for status, count := range delta.PoliciesByStatus {
+		stats.PoliciesByStatus[status] += count
+	}
+	for gender, count := range delta.PoliciesByGender {
+		stats.PoliciesByGender[gender] += count
+	}
+
+	return putNetworkStats(ctx, stats)
+}
+
+// ///////////////////////////////////////////////////
+// STORE THE NETWORK STATISTICS IN THE WORLD STATE //
+// ///////////////////////////////////////////////////
+func putNetworkStats(ctx contractapi.TransactionContextInterface, stats *NetworkStats) error {
+	// drop buckets that have emptied, so the document only lists what exists
+	for status, count := range stats.PoliciesByStatus {
+		if count == 0 {
+			delete(stats.PoliciesByStatus, status)
+		}
+	}
+	for gender, count := range stats.PoliciesByGender {
+		if count == 0 {
+			delete(stats.PoliciesByGender, gender)
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	stats.LastUpdated = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	statsJSON, err := json.Marshal(stats)
+	if err != nil {
+		return NewLedgerError("marshal network statistics", err)
+	}
+
+	if err := ctx.GetStub().PutState(networkStatsKey, statsJSON); err != nil {
+		return NewLedgerError("store network statistics", err)
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////
+// RECORD A SINGLE POLICY CHANGE IN THE RUNNING STATISTICS //
+// ///////////////////////////////////////////////////////////
+func updateNetworkStats(ctx contractapi.TransactionContextInterface, before *Policy, after *Policy) error {
+	delta := newNetworkStats()
+	delta.trackPolicyChange(before, after)
+
+	return applyNetworkStats(ctx, delta)
+}
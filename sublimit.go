@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// STRUCTURE FOR A CAP ON THE AMOUNT PAYABLE FOR ONE COVERAGE TYPE
+type SubLimit struct {
+	CoverageType     string `json:"coverageType"`
+	Limit            int    `json:"limit"`                      // total insured amount payable for the coverage type per term, 0 for no cap
+	MaxClaimsPerYear int    `json:"maxClaimsPerYear,omitempty"` // claims allowed for the coverage type per policy year, 0 for no limit
+}
+
+// ////////////////////////////////////////////
+// PARSE A JSON-ENCODED ARRAY OF SUB-LIMITS //
+// ////////////////////////////////////////////
+func parseSubLimits(subLimitsJSON string) ([]SubLimit, error) {
+	subLimits := []SubLimit{}
+	if strings.TrimSpace(subLimitsJSON) == "" {
+		return subLimits, nil
+	}
+
+	if err := json.Unmarshal([]byte(subLimitsJSON), &subLimits); err != nil {
+		return nil, NewValidationError("subLimits", fmt.Sprintf("invalid sub-limits, expected a JSON array of {coverageType, limit, maxClaimsPerYear} objects: %v", err))
+	}
+
+	for i := range subLimits {
+		subLimits[i].CoverageType = strings.TrimSpace(subLimits[i].CoverageType)
+	}
+
+	if err := validateSubLimits(subLimits); err != nil {
+		return nil, err
+	}
+
+	return subLimits, nil
+}
+
+// /////////////////////////////////////////////////////
+// CHECK THAT EVERY SUB-LIMIT IS POSITIVE AND UNIQUE //
+// /////////////////////////////////////////////////////
+func validateSubLimits(subLimits []SubLimit) error {
+	seen := []string{}
+	for _, subLimit := range subLimits {
+		if subLimit.CoverageType == "" {
+			return NewValidationError("subLimits", "sub-limit coverage type must not be empty")
+		}
+		if subLimit.MaxClaimsPerYear < 0 {
+			return NewValidationError("subLimits", fmt.Sprintf("invalid claim limit %d for %q: must not be negative", subLimit.MaxClaimsPerYear, subLimit.CoverageType))
+		}
+		// a sub-limit may only cap the number of claims, but it must cap something
+		if err := checkAmount("subLimits", subLimit.Limit); err != nil {
+			return err
+		}
+		if subLimit.Limit == 0 && subLimit.MaxClaimsPerYear == 0 {
+			return NewValidationError("subLimits", fmt.Sprintf("invalid sub-limit %d for %q: must be greater than zero", subLimit.Limit, subLimit.CoverageType))
+		}
+		if containsFold(seen, subLimit.CoverageType) {
+			return NewValidationError("subLimits", fmt.Sprintf("coverage type %q has more than one sub-limit", subLimit.CoverageType))
+		}
+		seen = append(seen, subLimit.CoverageType)
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////
+// CHECK THE DAILY ROOM RENT LIMIT OF A POLICY //
+// ///////////////////////////////////////////////
+func validateRoomRentLimit(roomRentLimit int) error {
+	// the limit is a percentage of the sum assured
+	if roomRentLimit < 0 || roomRentLimit > 100 {
+		return NewValidationError("roomRentLimit", fmt.Sprintf("invalid room rent limit %d: must be between 0 and 100", roomRentLimit))
+	}
+
+	return nil
+}
+
+// ///////////////////////////////////////////////////////
+// FIND THE SUB-LIMIT FOR A COVERAGE TYPE, NIL IF NONE //
+// ///////////////////////////////////////////////////////
+func findSubLimit(policy *Policy, coverageType string) *SubLimit {
+	for i := range policy.SubLimits {
+		if strings.EqualFold(policy.SubLimits[i].CoverageType, coverageType) {
+			return &policy.SubLimits[i]
+		}
+	}
+
+	return nil
+}
+
+// //////////////////////////////////////////////////////////
+// CAP AN INSURED AMOUNT AT WHAT IS LEFT OF ITS SUB-LIMIT //
+// //////////////////////////////////////////////////////////
+func subLimitedAmount(policy *Policy, insuredAmount int, coverageType string) (int, error) {
+	subLimit := findSubLimit(policy, coverageType)
+	if subLimit == nil || subLimit.Limit == 0 {
+		return insuredAmount, nil
+	}
+
+	remaining := subLimit.Limit - policy.SubLimitUtilized[subLimit.CoverageType]
+	if remaining <= 0 {
+		return 0, NewStateError(fmt.Sprintf("sub-limit of %d for %q is exhausted", subLimit.Limit, subLimit.CoverageType))
+	}
+
+	if insuredAmount > remaining {
+		return remaining, nil
+	}
+
+	return insuredAmount, nil
+}
+
+// ////////////////////////////////////////////////////////////
+// ADJUST THE USED PART OF A SUB-LIMIT, IF THE TYPE HAS ONE //
+// ////////////////////////////////////////////////////////////
+func addSubLimitUsage(policy *Policy, coverageType string, amount int) {
+	subLimit := findSubLimit(policy, coverageType)
+	if subLimit == nil || subLimit.Limit == 0 {
+		return
+	}
+
+	if policy.SubLimitUtilized == nil {
+		policy.SubLimitUtilized = map[string]int{}
+	}
+	policy.SubLimitUtilized[subLimit.CoverageType] += amount
+}
+
+// //////////////////////////////////////////////////////////////////////////////////////
+// GROSS AMOUNT DEDUCTED FOR ROOM RENT ABOVE THE POLICY'S DAILY LIMIT, 0 IF WITHIN IT //
+// //////////////////////////////////////////////////////////////////////////////////////
+func roomRentDeduction(policy *Policy, claim *Claim) int {
+	if policy.RoomRentLimit == 0 {
+		return 0
+	}
+
+	room, proportional := 0, 0
+	for _, item := range claim.LineItems {
+		switch item.Category {
+		case "room":
+			room += item.PayableAmount
+		case "pharmacy":
+			// medicines cost the same whatever the room, so they are not deducted
+		default:
+			proportional += item.PayableAmount
+		}
+	}
+	if room == 0 {
+		return 0
+	}
+
+	admission, discharge, ok := claimStay(claim)
+	if !ok {
+		return 0
+	}
+	days := int(discharge.Sub(admission).Hours() / 24)
+	if days < 1 {
+		days = 1
+	}
+
+	// a limit too large to compute is above any bill
+	limit, err := multiplyAmount("roomRentLimit", policy.SumAssured*policy.RoomRentLimit, days)
+	if err != nil {
+		return 0
+	}
+	limit /= 100
+	if room <= limit {
+		return 0
+	}
+
+	// a costlier room makes every associated charge costlier, so they are cut by the same share as the rent
+	return (room - limit) + scaleAmount(proportional, room-limit, room)
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// RECORD THE ROOM-RENT DEDUCTION ON A CLAIM, RETURNING ITS EXPLANATION //
+// ////////////////////////////////////////////////////////////////////////
+func applyRoomRentLimit(policy *Policy, claim *Claim) string {
+	claim.RoomRentDeduction = 0
+
+	deduction := roomRentDeduction(policy, claim)
+	if deduction == 0 {
+		return ""
+	}
+
+	// the deduction is taken from the insured portion, after the co-pay
+	claim.RoomRentDeduction = insuredPortion(deduction, policy.CoPay)
+	if claim.RoomRentDeduction > claim.ClaimAmount {
+		claim.RoomRentDeduction = claim.ClaimAmount
+	}
+
+	return fmt.Sprintf("room rent above %d%% of the sum assured per day, %d deducted proportionately", policy.RoomRentLimit, claim.RoomRentDeduction)
+}
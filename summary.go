@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR AGGREGATE STATISTICS OF A POLICY
+type PolicySummary struct {
+	PolicyID        string  `json:"policyID"`
+	SumAssured      int     `json:"sumAssured"`
+	ClaimedTotal    int     `json:"claimedTotal"`
+	UtilizationPct  float64 `json:"utilizationPct"` // share of the sum assured claimed, two decimals
+	TotalClaims     int     `json:"totalClaims"`    // pre-authorizations are not counted
+	PendingClaims   int     `json:"pendingClaims"`
+	ApprovedClaims  int     `json:"approvedClaims"`
+	RejectedClaims  int     `json:"rejectedClaims"`
+	DisputedClaims  int     `json:"disputedClaims"`  // rejected claims with an open dispute
+	DaysUntilExpiry int     `json:"daysUntilExpiry"` // negative once the policy has expired
+}
+
+// //////////////////////////////////////////////
+// SUMMARISE THE CLAIMS AND USAGE OF A POLICY //
+// //////////////////////////////////////////////
+func (c *HealthInsurance) GetPolicySummary(ctx contractapi.TransactionContextInterface, policyID string) (*PolicySummary, error) {
+	role, err := getClientRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "insurer" && role != "patient" {
+		return nil, NewUnauthorizedError(fmt.Sprintf("unauthorized access: role %q is not permitted, requires one of insurer, patient", role))
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// patients can only see the summary of their own policy
+	if role == "patient" {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &PolicySummary{
+		PolicyID:     policyID,
+		SumAssured:   policy.SumAssured,
+		ClaimedTotal: policy.ClaimedTotal,
+	}
+
+	if policy.SumAssured > 0 {
+		utilization := float64(policy.ClaimedTotal) / float64(policy.SumAssured) * 100
+		summary.UtilizationPct = math.Round(utilization*100) / 100
+	}
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(claimCollection, "claim", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read claims from private data", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate claims", err)
+		}
+
+		var claim Claim
+		if err := json.Unmarshal(result.Value, &claim); err != nil {
+			return nil, NewLedgerError("unmarshal claim", err)
+		}
+
+		// pre-authorizations share the claim keys but are not claims themselves
+		if isPreAuthStatus(claim.Status) {
+			continue
+		}
+
+		summary.TotalClaims++
+		switch claim.Status {
+		case ClaimStatusSubmitted, ClaimStatusUnderReview, ClaimStatusDuplicateSuspect, ClaimStatusEscalated, ClaimStatusAppealPending:
+			summary.PendingClaims++
+		case ClaimStatusApproved, ClaimStatusPartiallyApproved, ClaimStatusSettled, ClaimStatusReimbursementPending, ClaimStatusReimbursed:
+			summary.ApprovedClaims++
+		case ClaimStatusRejected:
+			summary.RejectedClaims++
+
+			disputed, err := hasOpenDispute(ctx, claim.ClaimID)
+			if err != nil {
+				return nil, err
+			}
+			if disputed {
+				summary.DisputedClaims++
+			}
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, NewLedgerError("get transaction timestamp", err)
+	}
+
+	end, err := parsePolicyDate("end date", policy.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// both dates are at midnight UTC, so the difference is a whole number of days
+	now := txTimestamp.AsTime().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	summary.DaysUntilExpiry = int(end.Sub(today).Hours() / 24)
+
+	return summary, nil
+}
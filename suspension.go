@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A RECORD OF A POLICY SUSPENSION
+type SuspensionRecord struct {
+	PolicyID     string `json:"policyID"`
+	TxID         string `json:"txID"`
+	Reason       string `json:"reason"`
+	SuspendedAt  string `json:"suspendedAt"`            // RFC3339, UTC
+	SuspendedBy  string `json:"suspendedBy"`            // client ID of the insurer that suspended the policy
+	ReinstatedAt string `json:"reinstatedAt,omitempty"` // RFC3339, UTC, empty while the suspension is in effect
+}
+
+// /////////////////////////////////////////////////
+// SUSPEND A POLICY, FOR EXAMPLE FOR NON-PAYMENT //
+// /////////////////////////////////////////////////
+func (c *HealthInsurance) SuspendPolicy(ctx contractapi.TransactionContextInterface, policyID string, reason string) error {
+	// only insurers can suspend policies
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "suspension reason must not be empty")
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	if err := validatePolicyTransition(policy, PolicyStatusSuspended); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	record := &SuspensionRecord{
+		PolicyID:    policyID,
+		TxID:        ctx.GetStub().GetTxID(),
+		Reason:      reason,
+		SuspendedAt: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+		SuspendedBy: clientID,
+	}
+
+	if err := putSuspensionRecord(ctx, record); err != nil {
+		return err
+	}
+
+	// unlike a cancellation, a suspension keeps the policy reinstatable
+	previous := *policy
+	policy.Status = PolicyStatusSuspended
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return setChaincodeEvent(ctx, "PolicySuspended", policyID, "")
+}
+
+// ///////////////////////////////////////////////////////
+// REINSTATE A SUSPENDED POLICY ONCE PREMIUMS ARE PAID //
+// ///////////////////////////////////////////////////////
+func (c *HealthInsurance) ReinstatePolicy(ctx contractapi.TransactionContextInterface, policyID string) error {
+	// only insurers can reinstate policies
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	if policy.Status != PolicyStatusSuspended {
+		return NewStateError(fmt.Sprintf("cannot reinstate policy %s, current status is %q", policyID, policy.Status))
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// every premium that has fallen due must be paid
+	unpaid, err := getUnpaidPremiums(ctx, policyID, now)
+	if err != nil {
+		return err
+	}
+	if len(unpaid) > 0 {
+		return NewStateError(fmt.Sprintf("cannot reinstate policy %s, premiums due on %s are unpaid", policyID, strings.Join(unpaid, ", ")))
+	}
+
+	// close the suspension that is currently in effect
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("suspension", []string{policyID})
+	if err != nil {
+		return NewLedgerError("read suspension records from world state", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return NewLedgerError("iterate suspension records", err)
+		}
+
+		var record SuspensionRecord
+		if err := json.Unmarshal(result.Value, &record); err != nil {
+			return NewLedgerError("unmarshal suspension record", err)
+		}
+
+		if record.ReinstatedAt == "" {
+			record.ReinstatedAt = now.Format(time.RFC3339)
+			if err := putSuspensionRecord(ctx, &record); err != nil {
+				return err
+			}
+		}
+	}
+
+	previous := *policy
+	policy.Status = PolicyStatusActive
+	policy.Version++
+
+	if err := updateNetworkStats(ctx, &previous, policy); err != nil {
+		return err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	return setChaincodeEvent(ctx, "PolicyReinstated", policyID, "")
+}
+
+// ////////////////////////////////////////////////
+// STORE A SUSPENSION RECORD IN THE WORLD STATE //
+// ////////////////////////////////////////////////
+func putSuspensionRecord(ctx contractapi.TransactionContextInterface, record *SuspensionRecord) error {
+	recordKey, err := ctx.GetStub().CreateCompositeKey("suspension", []string{record.PolicyID, record.TxID})
+	if err != nil {
+		return NewLedgerError("create suspension key", err)
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return NewLedgerError("marshal suspension record", err)
+	}
+
+	if err := ctx.GetStub().PutState(recordKey, recordJSON); err != nil {
+		return NewLedgerError("store suspension record", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A PROCEDURE OR PACKAGE RATE AGREED WITH NETWORK HOSPITALS
+type Tariff struct {
+	ObjectType    string `json:"docType"`
+	ProcedureCode string `json:"procedureCode"` // CPT or package code, upper case
+	Description   string `json:"description"`
+	PackageRate   int    `json:"packageRate"` // most the insurer expects to be billed for the procedure
+}
+
+// STRUCTURE FOR A PROCEDURE BILLED ON A CLAIM
+type ProcedureCharge struct {
+	ProcedureCode    string `json:"procedureCode"`
+	Amount           int    `json:"amount"`                     // amount billed for the procedure
+	PackageRate      int    `json:"packageRate"`                // tariff in force when the claim was submitted
+	OverTariffAmount int    `json:"overTariffAmount,omitempty"` // amount billed above the package rate
+}
+
+// //////////////////////////////////////////////////
+// LOAD OR UPDATE ENTRIES IN THE PROCEDURE TARIFF //
+// //////////////////////////////////////////////////
+func (c *HealthInsurance) LoadTariffs(ctx contractapi.TransactionContextInterface, tariffsJSON string) (int, error) {
+	// package rates are agreed by the insurer organisation
+	config, err := getConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return 0, err
+	}
+
+	var tariffs []Tariff
+	if err := json.Unmarshal([]byte(tariffsJSON), &tariffs); err != nil {
+		return 0, NewValidationError("tariffs", fmt.Sprintf("invalid tariffs, expected a JSON array of {procedureCode, description, packageRate} objects: %v", err))
+	}
+	if len(tariffs) == 0 {
+		return 0, NewValidationError("tariffs", "at least one tariff is required")
+	}
+
+	// validate the whole batch before writing any of it
+	for i := range tariffs {
+		code := normalizeProcedureCode(tariffs[i].ProcedureCode)
+		if code == "" {
+			return 0, NewValidationError("procedureCode", "procedure code must not be empty")
+		}
+		if tariffs[i].PackageRate <= 0 {
+			return 0, NewValidationError("packageRate", fmt.Sprintf("package rate of procedure %s must be greater than zero", code))
+		}
+		if err := checkAmount("packageRate", tariffs[i].PackageRate); err != nil {
+			return 0, err
+		}
+
+		tariffs[i].ObjectType = "tariff"
+		tariffs[i].ProcedureCode = code
+		tariffs[i].Description = strings.TrimSpace(tariffs[i].Description)
+	}
+
+	// a new rate applies to claims submitted from now on, earlier claims keep the rate they were checked against
+	for i := range tariffs {
+		if err := putTariff(ctx, &tariffs[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(tariffs), nil
+}
+
+// ////////////////////////////////////////////
+// RETRIEVE THE AGREED RATE FOR A PROCEDURE //
+// ////////////////////////////////////////////
+func (c *HealthInsurance) GetTariff(ctx contractapi.TransactionContextInterface, procedureCode string) (*Tariff, error) {
+	code := normalizeProcedureCode(procedureCode)
+
+	tariff, err := getTariff(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if tariff == nil {
+		return nil, NewNotFoundError("tariff", code)
+	}
+
+	return tariff, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////
+// PARSE THE PROCEDURES OF A CLAIM AND PRICE EACH AGAINST THE TARIFF TABLE //
+// ///////////////////////////////////////////////////////////////////////////
+func parseProcedureCharges(ctx contractapi.TransactionContextInterface, proceduresJSON string, claimAmount int) ([]ProcedureCharge, int, error) {
+	charges := []ProcedureCharge{}
+	if strings.TrimSpace(proceduresJSON) == "" {
+		return charges, 0, nil
+	}
+
+	if err := json.Unmarshal([]byte(proceduresJSON), &charges); err != nil {
+		return nil, 0, NewValidationError("procedures", fmt.Sprintf("invalid procedures, expected a JSON array of {procedureCode, amount} objects: %v", err))
+	}
+
+	billed, overTariff := 0, 0
+	for i := range charges {
+		code := normalizeProcedureCode(charges[i].ProcedureCode)
+		if code == "" {
+			return nil, 0, NewValidationError("procedureCode", "procedure code must not be empty")
+		}
+		if charges[i].Amount <= 0 {
+			return nil, 0, NewValidationError("procedures", fmt.Sprintf("amount billed for procedure %s must be greater than zero", code))
+		}
+		if err := checkAmount("procedures", charges[i].Amount); err != nil {
+			return nil, 0, err
+		}
+
+		tariff, err := getTariff(ctx, code)
+		if err != nil {
+			return nil, 0, err
+		}
+		if tariff == nil {
+			return nil, 0, NewValidationError("procedureCode", fmt.Sprintf("procedure %s has no agreed tariff", code))
+		}
+
+		// the rates are stored with the claim, the procedure is flagged rather than cut to its rate
+		charges[i].ProcedureCode = code
+		charges[i].PackageRate = tariff.PackageRate
+		charges[i].OverTariffAmount = 0
+		if charges[i].Amount > tariff.PackageRate {
+			charges[i].OverTariffAmount = charges[i].Amount - tariff.PackageRate
+		}
+
+		if billed, err = addAmounts("procedures", billed, charges[i].Amount); err != nil {
+			return nil, 0, err
+		}
+		overTariff += charges[i].OverTariffAmount
+	}
+
+	if billed > claimAmount {
+		return nil, 0, NewValidationError("procedures", fmt.Sprintf("procedures bill %d, more than the claimed %d", billed, claimAmount))
+	}
+
+	return charges, overTariff, nil
+}
+
+// ////////////////////////////////////////
+// TRIM AND UPPER-CASE A PROCEDURE CODE //
+// ////////////////////////////////////////
+func normalizeProcedureCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// //////////////////////////////////////////////////////////
+// READ A TARIFF, NIL IF THE PROCEDURE HAS NO AGREED RATE //
+// //////////////////////////////////////////////////////////
+func getTariff(ctx contractapi.TransactionContextInterface, procedureCode string) (*Tariff, error) {
+	tariffKey, err := ctx.GetStub().CreateCompositeKey("tariff", []string{procedureCode})
+	if err != nil {
+		return nil, NewLedgerError("create tariff key", err)
+	}
+
+	tariffJSON, err := ctx.GetStub().GetState(tariffKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if tariffJSON == nil {
+		return nil, nil
+	}
+
+	var tariff Tariff
+	if err := json.Unmarshal(tariffJSON, &tariff); err != nil {
+		return nil, NewLedgerError("unmarshal tariff", err)
+	}
+
+	return &tariff, nil
+}
+
+// /////////////////////////////////////
+// STORE A TARIFF IN THE WORLD STATE //
+// /////////////////////////////////////
+func putTariff(ctx contractapi.TransactionContextInterface, tariff *Tariff) error {
+	tariffKey, err := ctx.GetStub().CreateCompositeKey("tariff", []string{tariff.ProcedureCode})
+	if err != nil {
+		return NewLedgerError("create tariff key", err)
+	}
+
+	tariffJSON, err := json.Marshal(tariff)
+	if err != nil {
+		return NewLedgerError("marshal tariff", err)
+	}
+
+	if err := ctx.GetStub().PutState(tariffKey, tariffJSON); err != nil {
+		return NewLedgerError("store tariff", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// a transaction hash on an EVM-style network, 0x and 32 bytes in hex
+var tokenTxHashPattern = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+
+// a token contract address on an EVM-style network, 0x and 20 bytes in hex
+var tokenAddressPattern = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+
+// STRUCTURE FOR A PREMIUM PAID IN A DIGITAL CURRENCY, SUCH AS A CBDC OR A STABLECOIN
+type TokenPayment struct {
+	TokenType            string  `json:"tokenType"`                      // token paid in, priced by the tokenRates configuration
+	TokenContractAddress string  `json:"tokenContractAddress,omitempty"` // contract of the token, empty for a native currency
+	TransactionHash      string  `json:"transactionHash"`                // transfer on the token network, stored in lower case
+	NetworkID            string  `json:"networkID"`                      // chain the transfer was made on
+	TokenAmount          float64 `json:"tokenAmount"`
+
+	// set by the chaincode when the payment is recorded
+	PolicyID        string `json:"policyID,omitempty"`
+	PremiumID       string `json:"premiumID,omitempty"`       // installment the payment is for
+	FiatAmount      int    `json:"fiatAmount,omitempty"`      // token amount in the network amount unit
+	Status          string `json:"status,omitempty"`          // pending/confirmed/rejected
+	RecordedBy      string `json:"recordedBy,omitempty"`      // client ID of the identity that recorded it
+	RecordedAt      string `json:"recordedAt,omitempty"`      // RFC3339, UTC
+	ReviewedBy      string `json:"reviewedBy,omitempty"`      // insurer that confirmed or rejected a policyholder's payment
+	ReviewedAt      string `json:"reviewedAt,omitempty"`      // RFC3339, UTC
+	RejectionReason string `json:"rejectionReason,omitempty"` // why the insurer did not accept the transfer
+}
+
+// token payment statuses
+const (
+	TokenPaymentPending   = "pending"
+	TokenPaymentConfirmed = "confirmed"
+	TokenPaymentRejected  = "rejected"
+)
+
+// /////////////////////////////////////////////////////////////////////
+// PAY A PREMIUM INSTALLMENT WITH A TRANSFER MADE ON A TOKEN NETWORK //
+// /////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RecordTokenPremiumPayment(ctx contractapi.TransactionContextInterface, policyID string, paymentID string, payment TokenPayment) error {
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	// the insurer records payments it received, a policyholder's own report waits for the insurer to confirm it
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	confirmed := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer") == nil
+	if !confirmed {
+		if err := assertPolicyOwner(ctx, policy); err != nil {
+			return err
+		}
+	}
+
+	payment.TransactionHash = strings.ToLower(strings.TrimSpace(payment.TransactionHash))
+	if !tokenTxHashPattern.MatchString(payment.TransactionHash) {
+		return NewValidationError("transactionHash", fmt.Sprintf("invalid transaction hash %q, expected 0x followed by 64 hex digits", payment.TransactionHash))
+	}
+	payment.TokenContractAddress = strings.ToLower(strings.TrimSpace(payment.TokenContractAddress))
+	if payment.TokenContractAddress != "" && !tokenAddressPattern.MatchString(payment.TokenContractAddress) {
+		return NewValidationError("tokenContractAddress", fmt.Sprintf("invalid token contract address %q, expected 0x followed by 40 hex digits", payment.TokenContractAddress))
+	}
+	payment.NetworkID = strings.TrimSpace(payment.NetworkID)
+	if payment.NetworkID == "" {
+		return NewValidationError("networkID", "network ID must not be empty")
+	}
+
+	// the premium is owed in the network currency, so only tokens with a configured rate are taken
+	payment.TokenType = strings.TrimSpace(payment.TokenType)
+	rate, ok := config.TokenRates[payment.TokenType]
+	if !ok {
+		return NewValidationError("tokenType", fmt.Sprintf("token %q has no configured rate", payment.TokenType))
+	}
+	fiatAmount, err := convertAmount("tokenAmount", payment.TokenAmount, rate)
+	if err != nil {
+		return err
+	}
+
+	premium, err := getPremium(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if premium.PolicyID != policyID {
+		return NewValidationError("paymentID", fmt.Sprintf("premium %s does not belong to policy %s", paymentID, policyID))
+	}
+	if premium.Status == "paid" {
+		return NewStateError(fmt.Sprintf("premium %s is already paid", paymentID))
+	}
+	if fiatAmount < premium.Amount {
+		return NewValidationError("tokenAmount", fmt.Sprintf("token amount %v %s is worth %d, premium %s is %d", payment.TokenAmount, payment.TokenType, fiatAmount, paymentID, premium.Amount))
+	}
+
+	// one payment per installment is awaiting confirmation at a time
+	existing, err := getTokenPayment(ctx, policyID, premium.DueDate)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status == TokenPaymentPending {
+		return NewConflictError(fmt.Sprintf("premium %s already has a token payment awaiting confirmation", paymentID))
+	}
+
+	// a transfer pays for one premium only
+	txHashKey, err := tokenTxKey(ctx, payment.NetworkID, payment.TransactionHash)
+	if err != nil {
+		return err
+	}
+	usedBy, err := ctx.GetStub().GetState(txHashKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+	if usedBy != nil {
+		return NewConflictError(fmt.Sprintf("transaction %s on network %s was already reported for premium %s", payment.TransactionHash, payment.NetworkID, string(usedBy)))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	// the fields set by the chaincode are never taken from the caller
+	payment.PolicyID = policyID
+	payment.PremiumID = paymentID
+	payment.FiatAmount = fiatAmount
+	payment.Status = TokenPaymentPending
+	payment.RecordedBy = clientID
+	payment.RecordedAt = now.Format(time.RFC3339)
+	payment.ReviewedBy = ""
+	payment.ReviewedAt = ""
+	payment.RejectionReason = ""
+	if confirmed {
+		payment.Status = TokenPaymentConfirmed
+	}
+
+	if err := putTokenPayment(ctx, premium.DueDate, &payment); err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(txHashKey, []byte(paymentID)); err != nil {
+		return NewLedgerError("store token transaction index", err)
+	}
+
+	if !confirmed {
+		return setClaimEvent(ctx, "TokenPaymentSubmitted", policyID, "", fiatAmount)
+	}
+
+	if err := markPremiumPaid(ctx, premium, fiatAmount); err != nil {
+		return err
+	}
+
+	return setClaimEvent(ctx, "PremiumPaid", policyID, "", fiatAmount)
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// CONFIRM A POLICYHOLDER'S TOKEN PAYMENT, PAYING THE PREMIUM IT COVERS //
+// ////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) ConfirmTokenPremiumPayment(ctx contractapi.TransactionContextInterface, policyID string, paymentID string) error {
+	premium, payment, err := reviewTokenPayment(ctx, policyID, paymentID)
+	if err != nil {
+		return err
+	}
+
+	// the installment may have been paid some other way in the meantime
+	if premium.Status == "paid" {
+		return NewStateError(fmt.Sprintf("premium %s is already paid, reject the token payment instead", paymentID))
+	}
+
+	payment.Status = TokenPaymentConfirmed
+	if err := putTokenPayment(ctx, premium.DueDate, payment); err != nil {
+		return err
+	}
+
+	if err := markPremiumPaid(ctx, premium, payment.FiatAmount); err != nil {
+		return err
+	}
+
+	return setClaimEvent(ctx, "PremiumPaid", policyID, "", payment.FiatAmount)
+}
+
+// /////////////////////////////////////////////////////////////////////
+// REJECT A POLICYHOLDER'S TOKEN PAYMENT THE INSURER DID NOT RECEIVE //
+// /////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) RejectTokenPremiumPayment(ctx contractapi.TransactionContextInterface, policyID string, paymentID string, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return NewValidationError("reason", "rejection reason must not be empty")
+	}
+
+	premium, payment, err := reviewTokenPayment(ctx, policyID, paymentID)
+	if err != nil {
+		return err
+	}
+
+	payment.Status = TokenPaymentRejected
+	payment.RejectionReason = reason
+	if err := putTokenPayment(ctx, premium.DueDate, payment); err != nil {
+		return err
+	}
+
+	// the transfer did not pay the premium, so it may still be reported against the right one
+	txHashKey, err := tokenTxKey(ctx, payment.NetworkID, payment.TransactionHash)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(txHashKey); err != nil {
+		return NewLedgerError("delete token transaction index", err)
+	}
+
+	return setClaimEvent(ctx, "TokenPaymentRejected", policyID, "", payment.FiatAmount)
+}
+
+// ///////////////////////////////////////////////////////////////////////////
+// READ A PENDING TOKEN PAYMENT FOR THE INSURER, STAMPED WITH ITS REVIEWER //
+// ///////////////////////////////////////////////////////////////////////////
+func reviewTokenPayment(ctx contractapi.TransactionContextInterface, policyID string, paymentID string) (*Premium, *TokenPayment, error) {
+	// only the insurer knows whether the transfer arrived
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, nil, err
+	}
+
+	premium, err := getPremium(ctx, paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if premium.PolicyID != policyID {
+		return nil, nil, NewValidationError("paymentID", fmt.Sprintf("premium %s does not belong to policy %s", paymentID, policyID))
+	}
+
+	payment, err := getTokenPayment(ctx, policyID, premium.DueDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment == nil {
+		return nil, nil, NewNotFoundError("token payment", paymentID)
+	}
+	if payment.Status != TokenPaymentPending {
+		return nil, nil, NewStateError(fmt.Sprintf("token payment for premium %s is %s, not pending", paymentID, payment.Status))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, nil, NewLedgerError("get client ID", err)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, nil, NewLedgerError("get transaction timestamp", err)
+	}
+
+	payment.ReviewedBy = clientID
+	payment.ReviewedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	return premium, payment, nil
+}
+
+// /////////////////////////////////////////////////////////////////
+// RETRIEVE THE TOKEN PAYMENTS ON A POLICY, OLDEST PREMIUM FIRST //
+// /////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) GetTokenPaymentHistory(ctx contractapi.TransactionContextInterface, policyID string) ([]TokenPayment, error) {
+	// payment records are kept by the insurer
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.GetPolicy(ctx, policyID); err != nil {
+		return nil, err
+	}
+
+	// keys end in the premium's due date, so they come back in date order
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("tokenpayment", []string{policyID})
+	if err != nil {
+		return nil, NewLedgerError("read token payments from world state", err)
+	}
+	defer iterator.Close()
+
+	payments := []TokenPayment{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, NewLedgerError("iterate token payments", err)
+		}
+
+		var payment TokenPayment
+		if err := json.Unmarshal(result.Value, &payment); err != nil {
+			return nil, NewLedgerError("unmarshal token payment", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// /////////////////////////////////////////////////////////////////////
+// READ THE TOKEN PAYMENT FOR AN INSTALLMENT, NIL WHEN THERE IS NONE //
+// /////////////////////////////////////////////////////////////////////
+func getTokenPayment(ctx contractapi.TransactionContextInterface, policyID string, dueDate string) (*TokenPayment, error) {
+	paymentKey, err := ctx.GetStub().CreateCompositeKey("tokenpayment", []string{policyID, dueDate})
+	if err != nil {
+		return nil, NewLedgerError("create token payment key", err)
+	}
+
+	paymentJSON, err := ctx.GetStub().GetState(paymentKey)
+	if err != nil {
+		return nil, NewLedgerError("read from world state", err)
+	}
+	if paymentJSON == nil {
+		return nil, nil
+	}
+
+	var payment TokenPayment
+	if err := json.Unmarshal(paymentJSON, &payment); err != nil {
+		return nil, NewLedgerError("unmarshal token payment", err)
+	}
+
+	return &payment, nil
+}
+
+// ///////////////////////////////////////////////////////////////////////////////
+// STORE A TOKEN PAYMENT NEXT TO THE INSTALLMENT IT IS FOR, UNDER ITS DUE DATE //
+// ///////////////////////////////////////////////////////////////////////////////
+func putTokenPayment(ctx contractapi.TransactionContextInterface, dueDate string, payment *TokenPayment) error {
+	paymentKey, err := ctx.GetStub().CreateCompositeKey("tokenpayment", []string{payment.PolicyID, dueDate})
+	if err != nil {
+		return NewLedgerError("create token payment key", err)
+	}
+
+	paymentJSON, err := json.Marshal(payment)
+	if err != nil {
+		return NewLedgerError("marshal token payment", err)
+	}
+
+	if err := ctx.GetStub().PutState(paymentKey, paymentJSON); err != nil {
+		return NewLedgerError("store token payment", err)
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////////////
+// KEY INDEXING A TOKEN TRANSFER BY THE PREMIUM IT WAS USED FOR //
+// ////////////////////////////////////////////////////////////////
+func tokenTxKey(ctx contractapi.TransactionContextInterface, networkID string, txHash string) (string, error) {
+	txHashKey, err := ctx.GetStub().CreateCompositeKey("tokentx", []string{networkID, txHash})
+	if err != nil {
+		return "", NewLedgerError("create token transaction key", err)
+	}
+
+	return txHashKey, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// STRUCTURE FOR A RECORD OF A POLICY OWNERSHIP TRANSFER
+type TransferHistory struct {
+	PolicyID            string `json:"policyID"`
+	TxID                string `json:"txID"`
+	PreviousPersonName  string `json:"previousPersonName"`
+	PreviousOwnerCertID string `json:"previousOwnerCertID"`
+	NewPersonName       string `json:"newPersonName"`
+	NewOwnerCertID      string `json:"newOwnerCertID"`
+	TransferredBy       string `json:"transferredBy"` // client ID of the identity that made the transfer
+	Timestamp           string `json:"timestamp"`     // RFC3339, UTC
+}
+
+// //////////////////////////////////////////////
+// TRANSFER A POLICY TO A NEW HOLDER IDENTITY //
+// //////////////////////////////////////////////
+func (c *HealthInsurance) TransferPolicy(ctx contractapi.TransactionContextInterface, policyID string, newPersonName string, newOwnerCertID string) error {
+	if strings.TrimSpace(newPersonName) == "" || strings.TrimSpace(newOwnerCertID) == "" {
+		return NewValidationError("newOwnerCertID", "new person name and owner certificate ID must not be empty")
+	}
+
+	policy, err := c.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	// only active policies can be changed
+	if policy.Status != PolicyStatusActive {
+		return NewStateError(fmt.Sprintf("cannot transfer policy %s, current status is %q", policyID, policy.Status))
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return NewLedgerError("get client ID", err)
+	}
+
+	// the current owner can transfer their own policy, anyone else must be an insurer
+	actorRole := "owner"
+	if policy.OwnerCertID != clientID {
+		config, err := getConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+			return err
+		}
+		actorRole = "insurer"
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return NewLedgerError("get transaction timestamp", err)
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	transfer := TransferHistory{
+		PolicyID:            policyID,
+		TxID:                txID,
+		PreviousPersonName:  policy.PersonName,
+		PreviousOwnerCertID: policy.OwnerCertID,
+		NewPersonName:       newPersonName,
+		NewOwnerCertID:      newOwnerCertID,
+		TransferredBy:       clientID,
+		Timestamp:           txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return NewLedgerError("marshal transfer history", err)
+	}
+
+	transferKey, err := ctx.GetStub().CreateCompositeKey("transfer", []string{policyID, txID})
+	if err != nil {
+		return NewLedgerError("create transfer key", err)
+	}
+
+	if err := ctx.GetStub().PutState(transferKey, transferJSON); err != nil {
+		return NewLedgerError("store transfer history", err)
+	}
+
+	// the medical conditions stay keyed by policy ID, so only the holder changes
+	policy.PersonName = newPersonName
+	policy.OwnerCertID = newOwnerCertID
+	policy.Version++
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return NewLedgerError("marshal updated policy", err)
+	}
+
+	if err := ctx.GetStub().PutState(policyID, policyJSON); err != nil {
+		return NewLedgerError("store updated policy", err)
+	}
+
+	// the new holder gains access to the medical data, so record who made the change
+	if err := logAccessEvent(ctx, policyID, "transferred policy", clientID, actorRole); err != nil {
+		return NewLedgerError("log access event", err)
+	}
+
+	return setChaincodeEvent(ctx, "PolicyTransferred", policyID, "")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// world state key holding the PEM-encoded public key of the claim witness
+const witnessPublicKeyKey = "witness-pubkey"
+
+// /////////////////////////////////////////////////////
+// REGISTER THE KEY THAT WITNESSES HIGH-VALUE CLAIMS //
+// /////////////////////////////////////////////////////
+func (c *HealthInsurance) RegisterWitnessPublicKey(ctx contractapi.TransactionContextInterface, pemEncodedKey string) error {
+	// only insurers can appoint the witness
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := requireOrgAndRole(ctx, config.AllowedInsuranceMSP, "insurer"); err != nil {
+		return err
+	}
+
+	// refuse a key that could never verify a signature
+	if _, err := parseWitnessPublicKey([]byte(pemEncodedKey)); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(witnessPublicKeyKey, []byte(pemEncodedKey)); err != nil {
+		return NewLedgerError("store witness public key", err)
+	}
+
+	return nil
+}
+
+// ////////////////////////////////////////////////////////////////////////
+// SUBMIT A CLAIM ABOVE THE HIGH-VALUE THRESHOLD, SIGNED BY THE WITNESS //
+// ////////////////////////////////////////////////////////////////////////
+func (c *HealthInsurance) SubmitHighValueClaim(ctx contractapi.TransactionContextInterface, policyID string, claimAmount int, diagnosisCodesJSON string, coverageType string, hospitalName string, dateOfAdmission string, dateOfDischarge string, treatmentDate string, documentsJSON string, preAuthID string, claimType string, paymentProofHash string, intimationID string, proceduresJSON string, lineItemsJSON string) (string, error) {
+	// the signature travels as transient data, so it is not written to the ledger
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", NewLedgerError("get transient data", err)
+	}
+
+	signatureB64, ok := transientMap["witness-signature"]
+	if !ok || len(signatureB64) == 0 {
+		return "", NewValidationError("witness-signature", "high-value claims require a witness signature in the \"witness-signature\" transient field")
+	}
+
+	if err := verifyWitnessSignature(ctx, string(signatureB64), policyID, claimAmount, treatmentDate); err != nil {
+		return "", err
+	}
+
+	return c.submitClaim(ctx, policyID, claimAmount, diagnosisCodesJSON, coverageType, hospitalName, dateOfAdmission, dateOfDischarge, treatmentDate, documentsJSON, preAuthID, claimType, paymentProofHash, intimationID, proceduresJSON, lineItemsJSON)
+}
+
+// //////////////////////////////////////////////////////////////////
+// CHECK THE WITNESS SIGNED THE POLICY, AMOUNT AND TREATMENT DATE //
+// //////////////////////////////////////////////////////////////////
+func verifyWitnessSignature(ctx contractapi.TransactionContextInterface, signatureB64 string, policyID string, claimAmount int, treatmentDate string) error {
+	keyPEM, err := ctx.GetStub().GetState(witnessPublicKeyKey)
+	if err != nil {
+		return NewLedgerError("read from world state", err)
+	}
+	if keyPEM == nil {
+		return NewStateError("no witness public key is registered, high-value claims cannot be submitted")
+	}
+
+	publicKey, err := parseWitnessPublicKey(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return NewValidationError("witness-signature", fmt.Sprintf("invalid witness signature, expected base64: %v", err))
+	}
+
+	// the witness signs the SHA-256 hash of policyID, amount and treatment date, concatenated
+	digest := sha256.Sum256([]byte(policyID + strconv.Itoa(claimAmount) + treatmentDate))
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return NewUnauthorizedError("witness signature does not verify for this claim")
+	}
+
+	return nil
+}
+
+// /////////////////////////////////////////
+// DECODE A PEM-ENCODED ECDSA PUBLIC KEY //
+// /////////////////////////////////////////
+func parseWitnessPublicKey(keyPEM []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, NewValidationError("pemEncodedKey", "invalid witness public key: no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, NewValidationError("pemEncodedKey", fmt.Sprintf("invalid witness public key: %v", err))
+	}
+
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, NewValidationError("pemEncodedKey", "invalid witness public key: not an ECDSA key")
+	}
+
+	return publicKey, nil
+}